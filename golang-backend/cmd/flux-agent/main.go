@@ -18,13 +18,17 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"debug/elf"
 	"github.com/gorilla/websocket"
+	"network-panel/golang-backend/internal/svchash"
 )
 
 var (
@@ -64,13 +68,15 @@ type QueryServicesReq struct {
 
 // Control message from server; Data varies by Type
 type Message struct {
-	Type string          `json:"type"`
-	Data json.RawMessage `json:"data"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	RequestID string          `json:"requestId,omitempty"`
 }
 
 type Message2 struct {
-	Type string                 `json:"type"`
-	Data map[string]interface{} `json:"data"`
+	Type      string                 `json:"type"`
+	Data      map[string]interface{} `json:"data"`
+	RequestID string                 `json:"requestId,omitempty"`
 }
 
 type SuggestPortsReq struct {
@@ -270,7 +276,7 @@ func runOnce(wsURL, addr, secret, scheme string) error {
 			log.Printf("{\"event\":\"message2\",\"ok\":%q}", m2.Type)
 			// convert Message2 to Message
 			b, _ := json.Marshal(m2.Data)
-			m = &Message{Type: m2.Type, Data: b}
+			m = &Message{Type: m2.Type, Data: b, RequestID: m2.RequestID}
 		} else {
 			log.Printf("{\"event\":\"message\",\"ok\":%q}", m.Type)
 		}
@@ -280,66 +286,20 @@ func runOnce(wsURL, addr, secret, scheme string) error {
 			_ = json.Unmarshal(m.Data, &d)
 			log.Printf("{\"event\":\"recv_diagnose\",\"data\":%s}", string(mustJSON(d)))
 			go handleDiagnose(c, &d)
-		case "AddService":
-			var services []map[string]any
-			if err := json.Unmarshal(m.Data, &services); err != nil {
-				log.Printf("{\"event\":\"svc_cmd_parse_err\",\"type\":%q,\"error\":%q}", m.Type, err.Error())
-				continue
-			}
-			if err := addOrUpdateServices(services, false); err != nil {
-				log.Printf("{\"event\":\"svc_cmd_apply_err\",\"type\":%q,\"error\":%q}", m.Type, err.Error())
-			} else {
-				log.Printf("{\"event\":\"svc_cmd_applied\",\"type\":%q,\"count\":%d}", m.Type, len(services))
-			}
-		case "UpdateService":
-			var services []map[string]any
-			if err := json.Unmarshal(m.Data, &services); err != nil {
-				log.Printf("{\"event\":\"svc_cmd_parse_err\",\"type\":%q,\"error\":%q}", m.Type, err.Error())
-				continue
-			}
-			if err := addOrUpdateServices(services, true); err != nil {
-				log.Printf("{\"event\":\"svc_cmd_apply_err\",\"type\":%q,\"error\":%q}", m.Type, err.Error())
-			} else {
-				log.Printf("{\"event\":\"svc_cmd_applied\",\"type\":%q,\"count\":%d}", m.Type, len(services))
-			}
-		case "DeleteService":
-			var req struct {
-				Services []string `json:"services"`
-			}
-			if err := json.Unmarshal(m.Data, &req); err != nil {
-				log.Printf("{\"event\":\"svc_cmd_parse_err\",\"type\":%q,\"error\":%q}", m.Type, err.Error())
-				continue
-			}
-			if err := deleteServices(req.Services); err != nil {
+		case "AddService", "UpdateService", "DeleteService", "PauseService", "ResumeService":
+			results, err := applyServiceCommand(m.Type, m.Data)
+			if err != nil {
 				log.Printf("{\"event\":\"svc_cmd_apply_err\",\"type\":%q,\"error\":%q}", m.Type, err.Error())
 			} else {
-				log.Printf("{\"event\":\"svc_cmd_applied\",\"type\":%q,\"count\":%d}", m.Type, len(req.Services))
-			}
-		case "PauseService":
-			var req struct {
-				Services []string `json:"services"`
-			}
-			if err := json.Unmarshal(m.Data, &req); err != nil {
-				log.Printf("{\"event\":\"svc_cmd_parse_err\",\"type\":%q,\"error\":%q}", m.Type, err.Error())
-				continue
-			}
-			if err := markServicesPaused(req.Services, true); err != nil {
-				log.Printf("{\"event\":\"svc_cmd_apply_err\",\"type\":%q,\"error\":%q}", m.Type, err.Error())
-			} else {
-				log.Printf("{\"event\":\"svc_cmd_applied\",\"type\":%q,\"count\":%d}", m.Type, len(req.Services))
-			}
-		case "ResumeService":
-			var req struct {
-				Services []string `json:"services"`
-			}
-			if err := json.Unmarshal(m.Data, &req); err != nil {
-				log.Printf("{\"event\":\"svc_cmd_parse_err\",\"type\":%q,\"error\":%q}", m.Type, err.Error())
-				continue
+				log.Printf("{\"event\":\"svc_cmd_applied\",\"type\":%q,\"count\":%d}", m.Type, len(results))
 			}
-			if err := markServicesPaused(req.Services, false); err != nil {
-				log.Printf("{\"event\":\"svc_cmd_apply_err\",\"type\":%q,\"error\":%q}", m.Type, err.Error())
-			} else {
-				log.Printf("{\"event\":\"svc_cmd_applied\",\"type\":%q,\"count\":%d}", m.Type, len(req.Services))
+			// panels that wait for the outcome send a requestId
+			if m.RequestID != "" {
+				out := map[string]any{"success": err == nil, "results": results}
+				if err != nil {
+					out["message"] = err.Error()
+				}
+				_ = c.WriteJSON(map[string]any{"type": "ServiceResult", "requestId": m.RequestID, "cmd": m.Type, "data": out})
 			}
 		case "QueryServices":
 			var q QueryServicesReq
@@ -364,7 +324,27 @@ func runOnce(wsURL, addr, secret, scheme string) error {
 		case "UpgradeAgent2":
 			go func() { _ = upgradeAgent2(addr, scheme, "") }()
 		case "RestartGost":
-			go func() { _ = restartGostService() }()
+			var req struct {
+				Services []string `json:"services"`
+			}
+			_ = json.Unmarshal(m.Data, &req)
+			reqID := m.RequestID
+			go func() {
+				err := restartGostService()
+				// panels that wait for the outcome send a requestId and the services to check
+				if reqID == "" {
+					return
+				}
+				var results []serviceResult
+				if err == nil {
+					results = checkServicesListening(req.Services)
+				}
+				out := map[string]any{"success": err == nil, "results": results}
+				if err != nil {
+					out["message"] = err.Error()
+				}
+				_ = c.WriteJSON(map[string]any{"type": "ServiceResult", "requestId": reqID, "cmd": "RestartGost", "data": out})
+			}()
 		case "UninstallAgent":
 			go func() {
 				_ = uninstallSelf()
@@ -515,26 +495,72 @@ func memUsagePercent() float64 {
 	return used
 }
 
-func netBytes() (rx, tx uint64) {
+// netIfCounter is the cumulative byte counters of one interface from /proc/net/dev.
+type netIfCounter struct {
+	Name string `json:"name"`
+	Rx   uint64 `json:"rx"`
+	Tx   uint64 `json:"tx"`
+}
+
+// interfaces matching NET_IFACE_EXCLUDE are skipped; when NET_IFACE_INCLUDE is set only
+// matching interfaces are reported. Both are regular expressions on the interface name.
+const defaultIfaceExclude = `^(lo|docker\d*|br-.*|veth.*|virbr.*|cni\d*|flannel\..*|kube-.*)$`
+
+var ifaceInclude, ifaceExclude = compileIfacePatterns()
+
+func compileIfacePatterns() (inc, exc *regexp.Regexp) {
+	if v := getenv("NET_IFACE_INCLUDE", ""); v != "" {
+		if re, err := regexp.Compile(v); err == nil {
+			inc = re
+		} else {
+			log.Printf("{\"event\":\"bad_iface_pattern\",\"env\":\"NET_IFACE_INCLUDE\",\"error\":%q}", err.Error())
+		}
+	}
+	pat := getenv("NET_IFACE_EXCLUDE", defaultIfaceExclude)
+	if re, err := regexp.Compile(pat); err == nil {
+		exc = re
+	} else {
+		log.Printf("{\"event\":\"bad_iface_pattern\",\"env\":\"NET_IFACE_EXCLUDE\",\"error\":%q}", err.Error())
+		exc = regexp.MustCompile(defaultIfaceExclude)
+	}
+	return
+}
+
+func ifaceWanted(name string) bool {
+	if ifaceInclude != nil && !ifaceInclude.MatchString(name) {
+		return false
+	}
+	return ifaceExclude == nil || !ifaceExclude.MatchString(name)
+}
+
+// netIfCounters reads per-interface counters, filtered by the interface patterns.
+func netIfCounters() []netIfCounter {
 	b, err := ioutil.ReadFile("/proc/net/dev")
 	if err != nil {
-		return 0, 0
+		return nil
 	}
 	lines := strings.Split(string(b), "\n")
+	if len(lines) < 2 {
+		return nil
+	}
+	out := []netIfCounter{}
 	for _, ln := range lines[2:] { // skip headers
-		parts := strings.Fields(strings.TrimSpace(ln))
-		if len(parts) < 17 {
+		// "iface: rx_bytes ..." (large counters may touch the colon)
+		name, rest, ok := strings.Cut(ln, ":")
+		if !ok {
 			continue
 		}
-		// parts[0]=iface: ; rx bytes=parts[1]; tx bytes=parts[9]
-		// strip trailing ':' in iface
-		// sum over all interfaces
-		rxb, _ := strconv.ParseUint(parts[1], 10, 64)
-		txb, _ := strconv.ParseUint(parts[9], 10, 64)
-		rx += rxb
-		tx += txb
+		name = strings.TrimSpace(name)
+		parts := strings.Fields(rest)
+		if len(parts) < 16 || !ifaceWanted(name) {
+			continue
+		}
+		// rx bytes=parts[0]; tx bytes=parts[8]
+		rxb, _ := strconv.ParseUint(parts[0], 10, 64)
+		txb, _ := strconv.ParseUint(parts[8], 10, 64)
+		out = append(out, netIfCounter{Name: name, Rx: rxb, Tx: txb})
 	}
-	return
+	return out
 }
 
 func uptimeSeconds() int64 {
@@ -550,11 +576,157 @@ func uptimeSeconds() int64 {
 	return int64(f)
 }
 
+// diskUsage is the usage of one mounted filesystem.
+type diskUsage struct {
+	Mount string  `json:"mount"`
+	FS    string  `json:"fs"`
+	Total uint64  `json:"total"`
+	Used  uint64  `json:"used"`
+	Usage float64 `json:"usage"` // percent
+}
+
+// real filesystems worth watching; pseudo/overlay/network mounts are skipped
+var diskFSTypes = map[string]bool{"ext2": true, "ext3": true, "ext4": true, "xfs": true, "btrfs": true, "zfs": true, "f2fs": true, "jfs": true, "reiserfs": true, "vfat": true}
+
+func diskUsages() []diskUsage {
+	b, err := ioutil.ReadFile("/proc/mounts")
+	if err != nil {
+		return nil
+	}
+	out := []diskUsage{}
+	seen := map[string]bool{}
+	for _, ln := range strings.Split(string(b), "\n") {
+		f := strings.Fields(ln)
+		if len(f) < 3 || !diskFSTypes[f[2]] || seen[f[0]] {
+			continue
+		}
+		// same device mounted twice (bind mounts) is reported once
+		seen[f[0]] = true
+		total, free, avail, ok := statfs(f[1])
+		if !ok || total == 0 {
+			continue
+		}
+		used := total - free
+		d := diskUsage{Mount: f[1], FS: f[2], Total: total, Used: used}
+		// like df: used / (used + available to unprivileged users)
+		if used+avail > 0 {
+			d.Usage = float64(used) * 100 / float64(used+avail)
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+func loadAvg() (l1, l5, l15 float64) {
+	b, err := ioutil.ReadFile("/proc/loadavg")
+	if err != nil {
+		return
+	}
+	f := strings.Fields(string(b))
+	if len(f) < 3 {
+		return
+	}
+	l1, _ = strconv.ParseFloat(f[0], 64)
+	l5, _ = strconv.ParseFloat(f[1], 64)
+	l15, _ = strconv.ParseFloat(f[2], 64)
+	return
+}
+
+// tcpStates returns established (Tcp CurrEstab in /proc/net/snmp, IPv4+IPv6) and
+// TIME_WAIT (tw in /proc/net/sockstat) counts without walking /proc/net/tcp.
+func tcpStates() (estab, timeWait int64) {
+	if b, err := ioutil.ReadFile("/proc/net/snmp"); err == nil {
+		var header []string
+		for _, ln := range strings.Split(string(b), "\n") {
+			if !strings.HasPrefix(ln, "Tcp:") {
+				continue
+			}
+			f := strings.Fields(ln)
+			if header == nil {
+				header = f
+				continue
+			}
+			for i := 1; i < len(f) && i < len(header); i++ {
+				if header[i] == "CurrEstab" {
+					estab, _ = strconv.ParseInt(f[i], 10, 64)
+				}
+			}
+			break
+		}
+	}
+	if b, err := ioutil.ReadFile("/proc/net/sockstat"); err == nil {
+		for _, ln := range strings.Split(string(b), "\n") {
+			if !strings.HasPrefix(ln, "TCP:") {
+				continue
+			}
+			f := strings.Fields(ln)
+			for i := 1; i+1 < len(f); i += 2 {
+				if f[i] == "tw" {
+					timeWait, _ = strconv.ParseInt(f[i+1], 10, 64)
+				}
+			}
+		}
+	}
+	return
+}
+
+// conntrackUsage returns nf_conntrack count/max; ok is false when conntrack is not loaded.
+func conntrackUsage() (count, max int64, ok bool) {
+	readInt := func(p string) (int64, bool) {
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return 0, false
+		}
+		v, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+		return v, err == nil
+	}
+	count, ok1 := readInt("/proc/sys/net/netfilter/nf_conntrack_count")
+	max, ok2 := readInt("/proc/sys/net/netfilter/nf_conntrack_max")
+	return count, max, ok1 && ok2
+}
+
+// gostAutoRestarts counts restarts done by periodicEnsureGost since the agent started.
+var gostAutoRestarts atomic.Int64
+
+var gostHealthCache struct {
+	sync.Mutex
+	at       time.Time
+	known    bool
+	running  bool
+	restarts int64
+}
+
+// gostHealth reports whether the gost service is running and how often it was
+// restarted (systemd NRestarts plus agent auto-restarts). Cached for 15s.
+func gostHealth() (running bool, restarts int64, known bool) {
+	gostHealthCache.Lock()
+	defer gostHealthCache.Unlock()
+	if time.Since(gostHealthCache.at) < 15*time.Second {
+		return gostHealthCache.running, gostHealthCache.restarts, gostHealthCache.known
+	}
+	var n int64
+	if _, err := exec.LookPath("systemctl"); err == nil {
+		if out, err := exec.Command("systemctl", "show", "gost", "-p", "NRestarts", "--value").Output(); err == nil {
+			n, _ = strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		}
+	}
+	running, known = isServiceActive("gost")
+	restarts = n + gostAutoRestarts.Load()
+	gostHealthCache.at, gostHealthCache.known, gostHealthCache.running, gostHealthCache.restarts = time.Now(), known, running, restarts
+	return
+}
+
 func periodicSystemInfo(c *websocket.Conn) {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 	for {
-		rx, tx := netBytes()
+		// sum over reported interfaces only
+		counters := netIfCounters()
+		var rx, tx uint64
+		for _, c := range counters {
+			rx += c.Rx
+			tx += c.Tx
+		}
 		// gather interface list (best-effort)
 		ifaces := getInterfaces()
 		payload := map[string]any{
@@ -564,6 +736,20 @@ func periodicSystemInfo(c *websocket.Conn) {
 		payload["BytesTransmitted"] = int64(tx)
 		payload["CPUUsage"] = cpuUsagePercent()
 		payload["MemoryUsage"] = memUsagePercent()
+		payload["Load1"], payload["Load5"], payload["Load15"] = loadAvg()
+		payload["TCPEstablished"], payload["TCPTimeWait"] = tcpStates()
+		if cnt, max, ok := conntrackUsage(); ok {
+			payload["ConntrackCount"], payload["ConntrackMax"] = cnt, max
+		}
+		if disks := diskUsages(); len(disks) > 0 {
+			payload["Disks"] = disks
+		}
+		if running, restarts, known := gostHealth(); known {
+			payload["GostRunning"], payload["GostRestarts"] = running, restarts
+		}
+		if len(counters) > 0 {
+			payload["NetInterfaces"] = counters
+		}
 		if len(ifaces) > 0 {
 			payload["Interfaces"] = ifaces
 		}
@@ -619,38 +805,33 @@ func periodicReconcile(addr, secret, scheme string) {
 	}
 }
 
+// reconcile compares the panel's desired services with gost.json by content
+// hash and reports the drift; the panel decides what to re-push or remove.
 func reconcile(addr, secret, scheme string) {
-	// read local gost.json service names and panel-managed flag
-	present := map[string]struct{}{}
+	// read local gost.json services (hashed with their chain/observer) and panel-managed flag
+	cfg := readGostConfig()
+	local := map[string]string{}
 	managed := map[string]bool{}
-	if b, err := os.ReadFile(resolveGostConfigPathForRead()); err == nil {
-		var m map[string]any
-		if json.Unmarshal(b, &m) == nil {
-			if arr, ok := m["services"].([]any); ok {
-				for _, it := range arr {
-					if obj, ok := it.(map[string]any); ok {
-						if n, ok := obj["name"].(string); ok && n != "" {
-							present[n] = struct{}{}
-							if meta, _ := obj["metadata"].(map[string]any); meta != nil {
-								if v, ok2 := meta["managedBy"].(string); ok2 && v == "network-panel" {
-									managed[n] = true
-								}
-							}
+	if arr, ok := cfg["services"].([]any); ok {
+		for _, it := range arr {
+			if obj, ok := it.(map[string]any); ok {
+				if n, ok := obj["name"].(string); ok && n != "" {
+					local[n] = svchash.Hash(svchash.WithRefs(obj, cfg))
+					if meta, _ := obj["metadata"].(map[string]any); meta != nil {
+						if v, ok2 := meta["managedBy"].(string); ok2 && v == "network-panel" {
+							managed[n] = true
 						}
 					}
 				}
 			}
 		}
 	}
-	//addr := getenv("ADDR", "")
-	//secret := getenv("SECRET", "")
-	//scheme := getenv("SCHEME", "ws")
 	proto := "http"
 	if scheme == "wss" {
 		proto = "https"
 	}
 	desiredURL := fmt.Sprintf("%s://%s/api/v1/agent/desired-services", proto, addr)
-	body, _ := json.Marshal(map[string]string{"secret": secret})
+	body, _ := json.Marshal(map[string]any{"secret": secret, "withHash": true})
 	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
 	defer cancel()
 	req, _ := http.NewRequestWithContext(ctx, "POST", desiredURL, strings.NewReader(string(body)))
@@ -662,66 +843,54 @@ func reconcile(addr, secret, scheme string) {
 	}
 	defer resp.Body.Close()
 	var res struct {
-		Code int              `json:"code"`
-		Data []map[string]any `json:"data"`
+		Code int `json:"code"`
+		Data struct {
+			Hashes map[string]string `json:"hashes"`
+		} `json:"data"`
 	}
 	_ = json.NewDecoder(resp.Body).Decode(&res)
 	if res.Code != 0 {
 		log.Printf("{\"event\":\"reconcile_error\",\"step\":\"desired\",\"code\":%d}", res.Code)
 		return
 	}
-	missing := make([]map[string]any, 0)
-	desiredNames := map[string]struct{}{}
-	for _, svc := range res.Data {
-		if n, ok := svc["name"].(string); ok {
-			desiredNames[n] = struct{}{}
-			if _, ok2 := present[n]; !ok2 {
-				missing = append(missing, svc)
-			}
+	missing, changed := make([]string, 0), make([]string, 0)
+	for n, want := range res.Data.Hashes {
+		have, ok := local[n]
+		switch {
+		case !ok:
+			missing = append(missing, n)
+		case have != want:
+			changed = append(changed, n)
 		}
 	}
-	// compute extras if STRICT_RECONCILE=true (only for panel-managed services)
+	// extras: panel-managed services the panel does not list; removed only under STRICT_RECONCILE
 	extras := make([]string, 0)
+	for n := range local {
+		if _, ok := res.Data.Hashes[n]; !ok && managed[n] {
+			extras = append(extras, n)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(changed)
+	sort.Strings(extras)
 	strict := false
 	if v := strings.ToLower(getenv("STRICT_RECONCILE", "false")); v == "true" || v == "1" {
 		strict = true
 	}
-	if strict {
-		for n := range present {
-			if _, ok := desiredNames[n]; !ok {
-				if managed[n] {
-					extras = append(extras, n)
-				}
-			}
-		}
-	}
-	if len(missing) == 0 && len(extras) == 0 {
-		log.Printf("{\"event\":\"reconcile_ok\",\"missing\":0,\"extras\":0}")
+	if len(missing) == 0 && len(changed) == 0 && len(extras) == 0 {
+		log.Printf("{\"event\":\"reconcile_ok\",\"desired\":%d}", len(res.Data.Hashes))
 		return
 	}
-	if len(missing) > 0 {
-		pushURL := fmt.Sprintf("%s://%s/api/v1/agent/push-services", proto, addr)
-		pb, _ := json.Marshal(map[string]any{"secret": secret, "services": missing})
-		req2, _ := http.NewRequestWithContext(ctx, "POST", pushURL, strings.NewReader(string(pb)))
-		req2.Header.Set("Content-Type", "application/json")
-		if resp2, err := http.DefaultClient.Do(req2); err != nil {
-			log.Printf("{\"event\":\"reconcile_error\",\"step\":\"push\",\"error\":%q}", err.Error())
-		} else {
-			resp2.Body.Close()
-			log.Printf("{\"event\":\"reconcile_push\",\"count\":%d}", len(missing))
-		}
-	}
-	if strict && len(extras) > 0 {
-		rmURL := fmt.Sprintf("%s://%s/api/v1/agent/remove-services", proto, addr)
-		rb, _ := json.Marshal(map[string]any{"secret": secret, "services": extras})
-		req3, _ := http.NewRequestWithContext(ctx, "POST", rmURL, strings.NewReader(string(rb)))
-		req3.Header.Set("Content-Type", "application/json")
-		if resp3, err := http.DefaultClient.Do(req3); err != nil {
-			log.Printf("{\"event\":\"reconcile_error\",\"step\":\"remove\",\"error\":%q}", err.Error())
-		} else {
-			resp3.Body.Close()
-			log.Printf("{\"event\":\"reconcile_remove\",\"count\":%d}", len(extras))
-		}
+	report := map[string]any{"secret": secret, "missing": missing, "changed": changed, "extra": extras, "strict": strict, "desired": len(res.Data.Hashes)}
+	log.Printf("{\"event\":\"reconcile_drift\",\"missing\":%d,\"changed\":%d,\"extras\":%d}", len(missing), len(changed), len(extras))
+	reportURL := fmt.Sprintf("%s://%s/api/v1/agent/report-drift", proto, addr)
+	rb, _ := json.Marshal(report)
+	req2, _ := http.NewRequestWithContext(ctx, "POST", reportURL, strings.NewReader(string(rb)))
+	req2.Header.Set("Content-Type", "application/json")
+	if resp2, err := http.DefaultClient.Do(req2); err != nil {
+		log.Printf("{\"event\":\"reconcile_error\",\"step\":\"report\",\"error\":%q}", err.Error())
+	} else {
+		resp2.Body.Close()
 	}
 }
 
@@ -1023,6 +1192,81 @@ func suggestPorts(base, count int) []int {
 	return out
 }
 
+// serviceResult is the outcome of a service command for one service.
+type serviceResult struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// applyServiceCommand applies AddService, UpdateService, DeleteService,
+// PauseService or ResumeService to gost.json and reports per service. A write
+// failure fails every service; deleting an absent service succeeds.
+func applyServiceCommand(typ string, raw json.RawMessage) ([]serviceResult, error) {
+	var names []string
+	var apply func() error
+	switch typ {
+	case "AddService", "UpdateService":
+		var services []map[string]any
+		if err := json.Unmarshal(raw, &services); err != nil {
+			return nil, err
+		}
+		for _, svc := range services {
+			n, _ := svc["name"].(string)
+			names = append(names, n)
+		}
+		apply = func() error { return addOrUpdateServices(services, typ == "UpdateService") }
+	default:
+		var req struct {
+			Services []string `json:"services"`
+		}
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, err
+		}
+		names = req.Services
+		switch typ {
+		case "DeleteService":
+			apply = func() error { return deleteServices(req.Services) }
+		default:
+			apply = func() error { return markServicesPaused(req.Services, typ == "PauseService") }
+		}
+	}
+	err := apply()
+	present := localServiceNames()
+	results := make([]serviceResult, 0, len(names))
+	for _, n := range names {
+		r := serviceResult{Name: n, OK: true}
+		switch {
+		case err != nil:
+			r.OK, r.Error = false, err.Error()
+		case n == "":
+			r.OK, r.Error = false, "missing service name"
+		case typ == "DeleteService":
+			if present[n] {
+				r.OK, r.Error = false, "service still present"
+			}
+		case !present[n]:
+			r.OK, r.Error = false, "service not found"
+		}
+		results = append(results, r)
+	}
+	return results, err
+}
+
+// localServiceNames returns the service names in gost.json.
+func localServiceNames() map[string]bool {
+	out := map[string]bool{}
+	arr, _ := readGostConfig()["services"].([]any)
+	for _, it := range arr {
+		if m, ok := it.(map[string]any); ok {
+			if n, _ := m["name"].(string); n != "" {
+				out[n] = true
+			}
+		}
+	}
+	return out
+}
+
 // addOrUpdateServices merges provided services into gost.json services array.
 // If updateOnly is true, only update existing by name; otherwise upsert (add if missing).
 func addOrUpdateServices(services []map[string]any, updateOnly bool) error {
@@ -1875,6 +2119,118 @@ func restartGostService() error {
 	return fmt.Errorf("restart gost failed")
 }
 
+// remoteListeners do not bind a local port; udpListeners bind a UDP port.
+var (
+	remoteListeners = map[string]bool{"rtcp": true, "rudp": true}
+	udpListeners    = map[string]bool{"udp": true, "kcp": true, "quic": true, "dtls": true, "h3": true, "wt": true}
+)
+
+// checkServicesListening reports, per named service, whether gost holds the
+// service's port after a restart; a port taken by another process fails the
+// service. Paused, remote and unknown services pass unchecked. Ports get a few
+// seconds to come up.
+func checkServicesListening(names []string) []serviceResult {
+	byName := map[string]map[string]any{}
+	arr, _ := readGostConfig()["services"].([]any)
+	for _, it := range arr {
+		if m, ok := it.(map[string]any); ok {
+			if n, _ := m["name"].(string); n != "" {
+				byName[n] = m
+			}
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	results := make([]serviceResult, 0, len(names))
+	for _, n := range names {
+		r := serviceResult{Name: n, OK: true}
+		m := byName[n]
+		addr, _ := m["addr"].(string)
+		meta, _ := m["metadata"].(map[string]any)
+		lst, _ := m["listener"].(map[string]any)
+		lt, _ := lst["type"].(string)
+		port := parsePort(addr)
+		if m == nil || port == 0 || meta["paused"] == true || remoteListeners[lt] {
+			results = append(results, r)
+			continue
+		}
+		udp := udpListeners[lt]
+		for !gostHoldsPort(port, udp) {
+			if time.Now().After(deadline) {
+				r.OK, r.Error = false, fmt.Sprintf("port %d not bound by gost", port)
+				break
+			}
+			time.Sleep(200 * time.Millisecond)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// gostHoldsPort reports whether the running gost process has the port open. When
+// gost's sockets can't be read (no systemd, no /proc) it falls back to a TCP dial,
+// and UDP ports count as held.
+func gostHoldsPort(port int, udp bool) bool {
+	tcpPorts, udpPorts, ok := gostPorts()
+	switch {
+	case !ok && udp:
+		return true
+	case !ok:
+		return portListening(port)
+	case udp:
+		return udpPorts[port]
+	}
+	return tcpPorts[port]
+}
+
+// gostPorts returns the TCP listen ports and UDP ports of the gost service's
+// main process, matched by socket inode in /proc; ok is false when unknown.
+func gostPorts() (tcpPorts, udpPorts map[int]bool, ok bool) {
+	out, err := exec.Command("systemctl", "show", "-p", "MainPID", "--value", "gost").Output()
+	if err != nil {
+		return nil, nil, false
+	}
+	pid := strings.TrimSpace(string(out))
+	if pid == "" || pid == "0" {
+		return nil, nil, false
+	}
+	fds, err := os.ReadDir("/proc/" + pid + "/fd")
+	if err != nil {
+		return nil, nil, false
+	}
+	inodes := map[string]bool{}
+	for _, fd := range fds {
+		if l, err := os.Readlink("/proc/" + pid + "/fd/" + fd.Name()); err == nil && strings.HasPrefix(l, "socket:[") {
+			inodes[strings.TrimSuffix(strings.TrimPrefix(l, "socket:["), "]")] = true
+		}
+	}
+	tcpPorts, udpPorts = map[int]bool{}, map[int]bool{}
+	readProcNetPorts("/proc/net/tcp", "0A", inodes, tcpPorts) // 0A = LISTEN
+	readProcNetPorts("/proc/net/tcp6", "0A", inodes, tcpPorts)
+	readProcNetPorts("/proc/net/udp", "", inodes, udpPorts)
+	readProcNetPorts("/proc/net/udp6", "", inodes, udpPorts)
+	return tcpPorts, udpPorts, true
+}
+
+// readProcNetPorts adds the local ports of the sockets in a /proc/net table that
+// belong to inodes and are in state (any state when empty).
+func readProcNetPorts(path, state string, inodes map[string]bool, ports map[int]bool) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		f := strings.Fields(line)
+		if len(f) < 10 || (state != "" && f[3] != state) || !inodes[f[9]] {
+			continue
+		}
+		if i := strings.LastIndexByte(f[1], ':'); i >= 0 {
+			if p, err := strconv.ParseInt(f[1][i+1:], 16, 32); err == nil {
+				ports[int(p)] = true
+			}
+		}
+	}
+}
+
 // --- ensure gost.service stays running ---
 func periodicEnsureGost() {
 	ticker := time.NewTicker(10 * time.Second)
@@ -1890,6 +2246,7 @@ func periodicEnsureGost() {
 		}
 		// try restart when inactive
 		if tryRestartService("gost") {
+			gostAutoRestarts.Add(1)
 			log.Printf("{\"event\":\"gost_autorestart\",\"status\":\"restarted\"}")
 		} else {
 			log.Printf("{\"event\":\"gost_autorestart\",\"status\":\"failed\"}")
@@ -0,0 +1,15 @@
+//go:build linux
+
+package main
+
+import "syscall"
+
+// statfs returns total, free and unprivileged-available bytes of the filesystem at path.
+func statfs(path string) (total, free, avail uint64, ok bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, 0, false
+	}
+	bs := uint64(st.Bsize)
+	return st.Blocks * bs, st.Bfree * bs, st.Bavail * bs, true
+}
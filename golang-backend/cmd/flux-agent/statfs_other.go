@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// statfs is only implemented on Linux; disk usage is not reported elsewhere.
+func statfs(path string) (total, free, avail uint64, ok bool) {
+	return 0, 0, 0, false
+}
@@ -1,37 +1,185 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	app "network-panel/golang-backend/internal/app"
+	"network-panel/golang-backend/internal/app/controller"
+	"network-panel/golang-backend/internal/app/middleware"
 	"network-panel/golang-backend/internal/app/scheduler"
 	"network-panel/golang-backend/internal/app/util"
 	appver "network-panel/golang-backend/internal/app/version"
+	"network-panel/golang-backend/internal/config"
 	dbpkg "network-panel/golang-backend/internal/db"
+	"network-panel/golang-backend/internal/logging"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	migrateOnly := flag.Bool("migrate", false, "apply pending schema migrations and exit")
+	migrateStatus := flag.Bool("migrate-status", false, "print schema migration status and exit")
+	configFile := flag.String("config", "", "JSON config file, also CONFIG_FILE (default panel.json if present)")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	flag.Parse()
 	// load .env if present
 	util.LoadEnv()
+	path := *configFile
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	required := path != ""
+	if path == "" {
+		path = "panel.json"
+	}
+	cfg, err := config.Parse(path, required)
+	if err == nil {
+		if *printConfig {
+			// printed before validating so a broken configuration can be inspected
+			fmt.Println(cfg.Redacted())
+		}
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	if *printConfig {
+		return
+	}
+	if err := logging.Init(cfg.Log.Logging()); err != nil {
+		log.Fatalf("logging init error: %v", err)
+	}
+	if *migrateOnly || *migrateStatus {
+		cfg.Export()
+		runMigrateCmd(*migrateOnly)
+		return
+	}
+	if err := cfg.EnsureJWTSecret(); err != nil {
+		log.Fatalf("jwt secret: %v", err)
+	}
+	cfg.Export()
 	if err := dbpkg.Init(); err != nil {
 		log.Fatalf("db init error: %v", err)
 	}
 	// start schedulerRs
 	scheduler.Start()
 
-	r := gin.Default()
-	gin.SetMode(gin.DebugMode)
+	gin.SetMode(cfg.GinMode)
+	r := gin.New()
+	r.Use(middleware.RequestLogger(), gin.Recovery())
 	app.RegisterRoutes(r)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "6365"
+	newServer := func(addr string, h http.Handler) *http.Server {
+		return &http.Server{
+			Addr:              addr,
+			Handler:           h,
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       config.Seconds(cfg.HTTP.ReadTimeout),
+			// diagnose endpoints wait for agent replies; websockets are hijacked and not affected
+			WriteTimeout: config.Seconds(cfg.HTTP.WriteTimeout),
+			IdleTimeout:  config.Seconds(cfg.HTTP.IdleTimeout),
+		}
+	}
+	srv := newServer(":"+cfg.Port, r)
+	ts, err := newTLSSetup(cfg.TLS)
+	if err != nil {
+		log.Fatalf("tls config error: %v", err)
+	}
+	var plain []*http.Server
+	if ts != nil {
+		srv.TLSConfig = ts.config
+		plain = ts.plainServers(r, newServer)
+		controller.SetPanelTLS(true)
+	}
+	ctx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	errCh := make(chan error, 1+len(plain))
+	go func() {
+		if ts != nil {
+			log.Printf("network-panel server version %s listening on %s (https)", appver.Get(), srv.Addr)
+			errCh <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		log.Printf("network-panel server version %s listening on %s", appver.Get(), srv.Addr)
+		errCh <- srv.ListenAndServe()
+	}()
+	for _, ps := range plain {
+		go func(ps *http.Server) {
+			log.Printf("plain http listening on %s", ps.Addr)
+			errCh <- ps.ListenAndServe()
+		}(ps)
+	}
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server error: %v", err)
+		}
+	case <-ctx.Done():
+	}
+	shutdown(append([]*http.Server{srv}, plain...), config.Seconds(cfg.HTTP.ShutdownTimeout))
+}
+
+// shutdown closes the agent and admin websockets first, releasing requests that
+// wait on agent replies, then stops accepting requests and lets in-flight ones
+// (flow uploads, API calls) finish, and finally stops the scheduler and closes
+// the database.
+func shutdown(servers []*http.Server, timeout time.Duration) {
+	log.Printf("shutting down (timeout %s)", timeout)
+	controller.BeginShutdown()
+	controller.CloseConnections()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				log.Printf("http shutdown %s: %v", srv.Addr, err)
+			}
+		}(srv)
+	}
+	wg.Wait()
+	scheduler.Stop()
+	if err := dbpkg.Close(); err != nil {
+		log.Printf("db close: %v", err)
+	}
+	log.Printf("shutdown complete")
+}
+
+// runMigrateCmd handles the -migrate / -migrate-status flags.
+func runMigrateCmd(apply bool) {
+	if apply {
+		if err := dbpkg.Open(); err != nil {
+			log.Fatalf("db open error: %v", err)
+		}
+		if err := dbpkg.Migrate(); err != nil {
+			log.Fatalf("migrate error: %v", err)
+		}
+	} else if err := dbpkg.Connect(); err != nil {
+		// status only reads: no database creation, AutoMigrate or migrations
+		log.Fatalf("db open error: %v", err)
+	}
+	list, err := dbpkg.MigrationStatus()
+	if err != nil {
+		log.Fatalf("migrate status error: %v", err)
 	}
-	log.Printf("network-panel server version %s", appver.Get())
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("server error: %v", err)
+	for _, m := range list {
+		state := "pending"
+		if m.AppliedAt > 0 {
+			state = "applied " + time.UnixMilli(m.AppliedAt).Format(time.RFC3339)
+		}
+		fmt.Printf("%4d  %-32s %s\n", m.Version, m.Name, state)
 	}
 }
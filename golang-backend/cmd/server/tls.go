@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/tls"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"network-panel/golang-backend/internal/config"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsSetup describes how the panel terminates TLS: cert files (reloaded when
+// they change) or ACME with the HTTP-01 challenge. HTTP_PORT optionally keeps a
+// plain HTTP listener serving the panel next to the TLS one.
+type tlsSetup struct {
+	config *tls.Config
+	// challenge handles ACME HTTP-01 requests; other requests go to next, or are
+	// redirected to https when next is nil
+	challenge func(next http.Handler) http.Handler
+	acmePort  string
+	httpPort  string
+}
+
+// newTLSSetup returns nil when TLS is not configured; the settings were validated
+// by the config loader.
+func newTLSSetup(c config.TLSConfig) (*tlsSetup, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+	ts := &tlsSetup{httpPort: c.HTTPPort}
+	if c.CertFile != "" {
+		cr := &certReloader{certFile: c.CertFile, keyFile: c.KeyFile}
+		if err := cr.load(); err != nil {
+			return nil, err
+		}
+		ts.config = &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: cr.getCertificate}
+		return ts, nil
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(c.ACMECacheDir),
+		HostPolicy: autocert.HostWhitelist(c.Domains()...),
+		Email:      c.ACMEEmail,
+	}
+	if c.ACMEDirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: c.ACMEDirectoryURL}
+	}
+	ts.config = m.TLSConfig()
+	ts.config.MinVersion = tls.VersionTLS12
+	ts.challenge = m.HTTPHandler
+	ts.acmePort = c.ACMEHTTPPort
+	return ts, nil
+}
+
+// plainServers returns the plain HTTP listeners that run next to the TLS one:
+// the optional HTTP_PORT panel listener and the ACME challenge listener. When
+// both use the same port a single listener answers challenges and serves the panel.
+func (ts *tlsSetup) plainServers(app http.Handler, newServer func(addr string, h http.Handler) *http.Server) []*http.Server {
+	var out []*http.Server
+	if ts.challenge == nil {
+		if ts.httpPort != "" {
+			out = append(out, newServer(":"+ts.httpPort, app))
+		}
+		return out
+	}
+	if ts.httpPort == ts.acmePort {
+		return append(out, newServer(":"+ts.acmePort, ts.challenge(app)))
+	}
+	// challenge listener redirects everything else to https
+	out = append(out, newServer(":"+ts.acmePort, ts.challenge(nil)))
+	if ts.httpPort != "" {
+		out = append(out, newServer(":"+ts.httpPort, app))
+	}
+	return out
+}
+
+// certReloader serves a certificate from files and reloads it when they change,
+// so renewals by an external client (certbot, acme.sh) need no restart.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func (cr *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return err
+	}
+	cr.cert = &cert
+	cr.modTime = cr.lastModified()
+	return nil
+}
+
+func (cr *certReloader) lastModified() time.Time {
+	var t time.Time
+	for _, f := range []string{cr.certFile, cr.keyFile} {
+		if st, err := os.Stat(f); err == nil && st.ModTime().After(t) {
+			t = st.ModTime()
+		}
+	}
+	return t
+}
+
+func (cr *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if time.Since(cr.checked) >= time.Minute {
+		cr.checked = time.Now()
+		if cr.lastModified().After(cr.modTime) {
+			// keep serving the previous certificate if the new pair is incomplete
+			if err := cr.load(); err != nil {
+				log.Printf("tls certificate reload: %v", err)
+			} else {
+				log.Printf("tls certificate reloaded from %s", cr.certFile)
+			}
+		}
+	}
+	return cr.cert, nil
+}
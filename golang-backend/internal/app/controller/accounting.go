@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"math"
+	"time"
+
+	"network-panel/golang-backend/internal/app/model"
+	dbpkg "network-panel/golang-backend/internal/db"
+
+	"gorm.io/gorm"
+)
+
+// Billing modes, stored in Tunnel.Flow. Upload is what the client sends (gost
+// inputBytes, in_flow), download what it receives (outputBytes, out_flow).
+const (
+	billingMax      = 1 // the larger direction of each report ("单向", the historical default)
+	billingSum      = 2 // upload + download ("双向")
+	billingUpload   = 3 // upload only
+	billingDownload = 4 // download only
+)
+
+// validBillingMode reports whether m is a known billing mode.
+func validBillingMode(m int) bool { return m >= billingMax && m <= billingDownload }
+
+// tunnelBilling is how transfers over a tunnel are charged.
+type tunnelBilling struct {
+	Mode  int
+	Ratio float64
+}
+
+// billingOf returns the tunnel's billing: unknown modes count as sum, an unset or
+// negative traffic ratio as 1; a ratio of 0 makes the tunnel free.
+func billingOf(t model.Tunnel) tunnelBilling {
+	b := tunnelBilling{Mode: t.Flow, Ratio: 1}
+	if !validBillingMode(b.Mode) {
+		b.Mode = billingSum
+	}
+	if t.TrafficRatio != nil && *t.TrafficRatio >= 0 {
+		b.Ratio = *t.TrafficRatio
+	}
+	return b
+}
+
+// charge converts raw upload/download bytes into the billed upload/download
+// added to user and user_tunnel counters. The direction rule is applied per
+// increment, so in_flow + out_flow of those counters is always the billed usage,
+// whatever mix of tunnels a user has.
+func (b tunnelBilling) charge(in, out int64) (int64, int64) {
+	switch b.Mode {
+	case billingMax:
+		if in >= out {
+			out = 0
+		} else {
+			in = 0
+		}
+	case billingUpload:
+		out = 0
+	case billingDownload:
+		in = 0
+	}
+	return scaleBytes(in, b.Ratio), scaleBytes(out, b.Ratio)
+}
+
+// raw is the transfer counted by the mode without the ratio.
+func (b tunnelBilling) raw(in, out int64) int64 {
+	i, o := tunnelBilling{Mode: b.Mode, Ratio: 1}.charge(in, out)
+	return i + o
+}
+
+func scaleBytes(n int64, ratio float64) int64 {
+	if ratio == 1 {
+		return n
+	}
+	return int64(math.Round(float64(n) * ratio))
+}
+
+// billedUsed is the usage counted against a flow quota.
+func billedUsed(in, out int64) int64 { return in + out }
+
+// overQuota reports whether billed usage exceeds a quota in GiB (0 = unlimited).
+func overQuota(flowGiB, in, out int64) bool {
+	limit := flowGiB * 1024 * 1024 * 1024
+	return limit > 0 && billedUsed(in, out) > limit
+}
+
+// chargeTransfer records a transfer on a forward: raw bytes on the forward,
+// billed bytes on the user and user_tunnel (utID 0 = none) and in the hourly
+// statistics.
+func chargeTransfer(fwdID, userID, utID int64, tun model.Tunnel, in, out int64) {
+	b := billingOf(tun)
+	billIn, billOut := b.charge(in, out)
+	now := time.Now().UnixMilli()
+	if fwdID > 0 {
+		dbpkg.DB.Model(&model.Forward{}).Where("id = ?", fwdID).
+			Updates(map[string]any{"in_flow": gorm.Expr("in_flow + ?", in), "out_flow": gorm.Expr("out_flow + ?", out), "updated_time": now})
+	}
+	dbpkg.DB.Model(&model.User{}).Where("id = ?", userID).
+		Updates(map[string]any{"in_flow": gorm.Expr("in_flow + ?", billIn), "out_flow": gorm.Expr("out_flow + ?", billOut), "updated_time": now})
+	if utID > 0 {
+		dbpkg.DB.Model(&model.UserTunnel{}).Where("id = ?", utID).
+			Updates(map[string]any{"in_flow": gorm.Expr("in_flow + ?", billIn), "out_flow": gorm.Expr("out_flow + ?", billOut)})
+	}
+	recordFlowStat(userID, b.raw(in, out), billedUsed(billIn, billOut))
+}
+
+// recordFlowStat adds to the user's hourly statistics bucket (HH:00, UTC+8):
+// billed bytes in flow/total_flow, raw bytes in raw_flow.
+func recordFlowStat(userID int64, raw, billed int64) {
+	cst := time.FixedZone("UTC+8", 8*3600)
+	now := time.Now().In(cst)
+	hourKey := now.Format("15:00")
+	var rec model.StatisticsFlow
+	if err := dbpkg.DB.Where("user_id = ? AND time = ?", userID, hourKey).First(&rec).Error; err == nil && rec.ID > 0 {
+		dbpkg.DB.Model(&model.StatisticsFlow{}).Where("id = ?", rec.ID).
+			Updates(map[string]any{"flow": gorm.Expr("flow + ?", billed), "total_flow": gorm.Expr("total_flow + ?", billed), "raw_flow": gorm.Expr("raw_flow + ?", raw)})
+	} else {
+		rec = model.StatisticsFlow{UserID: userID, Flow: billed, TotalFlow: billed, RawFlow: raw, Time: hourKey, CreatedTime: now.UnixMilli()}
+		_ = dbpkg.DB.Create(&rec).Error
+	}
+}
+
+// enforceQuota pauses the user's forwards when the user is over quota, expired or
+// disabled, and the user_tunnel's forwards likewise (utID 0 = none).
+func enforceQuota(userID, utID int64) {
+	var user model.User
+	if err := dbpkg.DB.First(&user, userID).Error; err == nil {
+		if overUserLimit(user) || expired(user.ExpTime) || (user.Status != nil && *user.Status != 1) {
+			pauseAllUserForwards(user.ID)
+			s := 0
+			user.Status = &s
+			_ = dbpkg.DB.Save(&user).Error
+		}
+	}
+	if utID == 0 {
+		return
+	}
+	var ut model.UserTunnel
+	if err := dbpkg.DB.First(&ut, utID).Error; err == nil {
+		if overUTunnelLimit(ut) || expired(ut.ExpTime) || ut.Status != 1 {
+			pauseUserTunnelForwards(ut.UserID, ut.TunnelID)
+			ut.Status = 0
+			_ = dbpkg.DB.Save(&ut).Error
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"testing"
+
+	"network-panel/golang-backend/internal/app/model"
+)
+
+func ratioPtr(r float64) *float64 { return &r }
+
+func TestBillingOf(t *testing.T) {
+	cases := []struct {
+		name  string
+		flow  int
+		ratio *float64
+		want  tunnelBilling
+	}{
+		{"max", billingMax, nil, tunnelBilling{billingMax, 1}},
+		{"sum", billingSum, nil, tunnelBilling{billingSum, 1}},
+		{"upload", billingUpload, nil, tunnelBilling{billingUpload, 1}},
+		{"download", billingDownload, nil, tunnelBilling{billingDownload, 1}},
+		{"unknown mode 0", 0, nil, tunnelBilling{billingSum, 1}},
+		{"unknown mode 9", 9, nil, tunnelBilling{billingSum, 1}},
+		{"nil ratio", billingMax, nil, tunnelBilling{billingMax, 1}},
+		{"negative ratio", billingMax, ratioPtr(-1), tunnelBilling{billingMax, 1}},
+		{"zero ratio", billingMax, ratioPtr(0), tunnelBilling{billingMax, 0}},
+		{"ratio 1.5", billingSum, ratioPtr(1.5), tunnelBilling{billingSum, 1.5}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := billingOf(model.Tunnel{Flow: tc.flow, TrafficRatio: tc.ratio})
+			if got != tc.want {
+				t.Errorf("billingOf = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTunnelBillingCharge(t *testing.T) {
+	cases := []struct {
+		name            string
+		mode            int
+		ratio           float64
+		in, out         int64
+		wantIn, wantOut int64
+		wantRaw         int64
+	}{
+		{"max upload larger", billingMax, 1, 100, 40, 100, 0, 100},
+		{"max download larger", billingMax, 1, 40, 100, 0, 100, 100},
+		{"max equal", billingMax, 1, 50, 50, 50, 0, 50},
+		{"sum", billingSum, 1, 100, 40, 100, 40, 140},
+		{"upload", billingUpload, 1, 100, 40, 100, 0, 100},
+		{"download", billingDownload, 1, 100, 40, 0, 40, 40},
+		{"max ratio 2", billingMax, 2, 100, 40, 200, 0, 100},
+		{"sum ratio 0.5", billingSum, 0.5, 100, 40, 50, 20, 140},
+		{"upload ratio 0.5", billingUpload, 0.5, 100, 40, 50, 0, 100},
+		{"download ratio 3", billingDownload, 3, 100, 40, 0, 120, 40},
+		{"zero ratio is free", billingSum, 0, 100, 40, 0, 0, 140},
+		{"rounding", billingSum, 0.5, 3, 5, 2, 3, 8},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := tunnelBilling{Mode: tc.mode, Ratio: tc.ratio}
+			in, out := b.charge(tc.in, tc.out)
+			if in != tc.wantIn || out != tc.wantOut {
+				t.Errorf("charge(%d, %d) = %d, %d, want %d, %d", tc.in, tc.out, in, out, tc.wantIn, tc.wantOut)
+			}
+			if raw := b.raw(tc.in, tc.out); raw != tc.wantRaw {
+				t.Errorf("raw(%d, %d) = %d, want %d", tc.in, tc.out, raw, tc.wantRaw)
+			}
+		})
+	}
+}
+
+func TestScaleBytes(t *testing.T) {
+	cases := []struct {
+		n     int64
+		ratio float64
+		want  int64
+	}{
+		{1000, 1, 1000},
+		{1000, 0, 0},
+		{1000, 0.5, 500},
+		{1000, 2, 2000},
+		{3, 0.5, 2},   // 1.5 rounds half away from zero
+		{5, 0.5, 3},   // 2.5
+		{1, 0.4, 0},   // 0.4
+		{1, 0.6, 1},   // 0.6
+		{10, 0.33, 3}, // 3.3
+		{0, 2, 0},
+	}
+	for _, tc := range cases {
+		if got := scaleBytes(tc.n, tc.ratio); got != tc.want {
+			t.Errorf("scaleBytes(%d, %v) = %d, want %d", tc.n, tc.ratio, got, tc.want)
+		}
+	}
+}
+
+func TestOverQuota(t *testing.T) {
+	const gib = int64(1024 * 1024 * 1024)
+	cases := []struct {
+		name    string
+		flowGiB int64
+		in, out int64
+		want    bool
+	}{
+		{"unlimited", 0, 100 * gib, 100 * gib, false},
+		{"below limit", 1, gib / 2, gib/2 - 1, false},
+		{"at limit", 1, gib / 2, gib / 2, false},
+		{"one byte over", 1, gib / 2, gib/2 + 1, true},
+		{"upload only over", 2, 2*gib + 1, 0, true},
+		{"download only at limit", 2, 0, 2 * gib, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := overQuota(tc.flowGiB, tc.in, tc.out); got != tc.want {
+				t.Errorf("overQuota(%d, %d, %d) = %v, want %v", tc.flowGiB, tc.in, tc.out, got, tc.want)
+			}
+		})
+	}
+}
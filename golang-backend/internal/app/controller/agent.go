@@ -8,13 +8,16 @@ import (
 	"network-panel/golang-backend/internal/app/model"
 	"network-panel/golang-backend/internal/app/response"
 	dbpkg "network-panel/golang-backend/internal/db"
+	"network-panel/golang-backend/internal/svchash"
 )
 
-// POST /api/v1/agent/desired-services {secret}
-// Returns desired gost services for the node resolved by secret
+// POST /api/v1/agent/desired-services {secret, withHash?}
+// Returns desired gost services for the node resolved by secret; withHash
+// returns {services, hashes} instead of the bare list.
 func AgentDesiredServices(c *gin.Context) {
 	var p struct {
-		Secret string `json:"secret" binding:"required"`
+		Secret   string `json:"secret" binding:"required"`
+		WithHash bool   `json:"withHash"`
 	}
 	if err := c.ShouldBindJSON(&p); err != nil {
 		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
@@ -25,7 +28,11 @@ func AgentDesiredServices(c *gin.Context) {
 		c.JSON(http.StatusOK, response.ErrMsg("节点不存在"))
 		return
 	}
-	services := desiredServices(node.ID)
+	services, hashes := desiredServices(node.ID)
+	if p.WithHash {
+		c.JSON(http.StatusOK, response.Ok(map[string]any{"services": services, "hashes": hashes}))
+		return
+	}
 	c.JSON(http.StatusOK, response.Ok(services))
 }
 
@@ -70,45 +77,60 @@ func AgentReconcile(c *gin.Context) {
 		c.JSON(http.StatusOK, response.ErrMsg("节点不存在"))
 		return
 	}
-	services := desiredServices(node.ID)
+	services, _ := desiredServices(node.ID)
 	if len(services) > 0 {
 		_ = sendWSCommand(node.ID, "AddService", services)
 	}
 	c.JSON(http.StatusOK, response.Ok(map[string]any{"pushed": len(services)}))
 }
 
-// compute desired services for a node id from forwards+tunnels
-func desiredServices(nodeID int64) []map[string]any {
-	var rows []struct {
-		model.Forward
-		TType      int     `gorm:"column:t_type"`
-		InNodeID   int64   `gorm:"column:in_node_id"`
-		OutNodeID  *int64  `gorm:"column:out_node_id"`
-		OutIP      *string `gorm:"column:out_ip"`
-		TInterface *string `gorm:"column:t_interface"`
-	}
-	dbpkg.DB.Table("forward f").
-		Select("f.*, t.type as t_type, t.in_node_id, t.out_node_id, t.out_ip, t.interface_name as t_interface").
-		Joins("left join tunnel t on t.id = f.tunnel_id").Scan(&rows)
+// desiredServices renders every service the node should run, whatever its
+// role: forward entries, tunnel mid hops and exit relays, port-forward hops and
+// the exit SS service, with paused forwards paused. It also returns the svchash
+// content hash of each service by name. It only reads the database: forwards on
+// a relay path whose hop ports were never saved are left out until
+// BackfillForwardHops stores them.
+func desiredServices(nodeID int64) ([]map[string]any, map[string]string) {
+	var tunnels []model.Tunnel
+	dbpkg.DB.Order("id").Find(&tunnels)
 	services := make([]map[string]any, 0)
-	for _, r := range rows {
-		name := buildServiceName(r.ID, r.UserID, r.TunnelID)
-		iface := preferIface(r.InterfaceName, r.TInterface)
-		if r.TType == 2 {
-			// Do not compute desired services for tunnel-forward; these are managed at create/update time
+	for _, tun := range tunnels {
+		path := getTunnelPathNodes(tun.ID)
+		onTunnel := tun.InNodeID == nodeID || (tun.Type == 2 && outNodeIDOr0(tun) == nodeID)
+		for _, nid := range path {
+			onTunnel = onTunnel || nid == nodeID
+		}
+		if !onTunnel {
 			continue
-		} else {
-            if r.InNodeID == nodeID {
-                svc := buildServiceConfig(name, r.InPort, r.RemoteAddr, iface)
-                if obsName, spec := buildObserverPluginSpec(nodeID, name); obsName != "" && spec != nil {
-                    svc["observer"] = obsName
-                    svc["_observers"] = []any{spec}
-                }
-                services = append(services, svc)
-            }
+		}
+		var forwards []model.Forward
+		dbpkg.DB.Where("tunnel_id = ?", tun.ID).Order("id").Find(&forwards)
+		for _, f := range forwards {
+			var ports []int
+			if len(path) > 0 {
+				var ok bool
+				if ports, ok = loadForwardHops(f.ID, path); !ok {
+					continue
+				}
+			}
+			for _, ns := range renderForward(f, tun, path, ports) {
+				if ns.NodeID == nodeID {
+					services = append(services, ns.Services...)
+				}
+			}
+		}
+	}
+	var es model.ExitSetting
+	if dbpkg.DB.Where("node_id = ?", nodeID).First(&es).Error == nil {
+		services = append(services, exitSettingService(es))
+	}
+	hashes := make(map[string]string, len(services))
+	for _, svc := range services {
+		if n, _ := svc["name"].(string); n != "" {
+			hashes[n] = svchash.Hash(svc)
 		}
 	}
-	return services
+	return services, hashes
 }
 
 // POST /api/v1/agent/remove-services {secret, services:[name...]}
@@ -149,7 +171,7 @@ func AgentReconcileNode(c *gin.Context) {
 		c.JSON(http.StatusOK, response.ErrMsg("节点不存在"))
 		return
 	}
-	services := desiredServices(node.ID)
+	services, _ := desiredServices(node.ID)
 	if len(services) > 0 {
 		_ = sendWSCommand(node.ID, "AddService", services)
 	}
@@ -0,0 +1,646 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"network-panel/golang-backend/internal/app/model"
+	"network-panel/golang-backend/internal/app/response"
+	dbpkg "network-panel/golang-backend/internal/db"
+)
+
+// Threshold alert rules. The scheduler calls EvaluateAlertRules periodically; every
+// rule is evaluated per subject (node, node/target, forward, user) and tracked in
+// alert_rule_state so one episode creates one alert row and one notification
+// (optionally repeated every RepeatS). A firing rule clears only once the current
+// value is back past the recover level, and notifications inside a silence window
+// are postponed until the window ends.
+
+var alertMetrics = map[string]struct {
+	label string
+	unit  string
+}{
+	"cpu":          {"CPU 使用率", "%"},
+	"mem":          {"内存使用率", "%"},
+	"disk":         {"磁盘使用率", "%"},
+	"load1":        {"1 分钟负载", ""},
+	"conntrack":    {"conntrack 使用率", "%"},
+	"probe_loss":   {"探测丢包率", "%"},
+	"offline":      {"离线时长", "分钟"},
+	"forward_idle": {"转发无流量时长", "小时"},
+	"user_quota":   {"用户流量使用率", "%"},
+}
+
+var alertSeverities = map[string]bool{"info": true, "warning": true, "critical": true}
+
+// alertReading is the value of a rule's metric for one subject. Value decides whether
+// the rule is breached (for sysinfo metrics the least extreme value over the ForS
+// window), Current decides whether a firing rule has recovered.
+type alertReading struct {
+	Subject  string
+	NodeID   *int64
+	Name     string
+	Value    float64
+	Current  float64
+	Windowed bool // Value already covers ForS
+}
+
+var alertEvalMu sync.Mutex
+
+// EvaluateAlertRules evaluates every enabled rule once.
+func EvaluateAlertRules() {
+	alertEvalMu.Lock()
+	defer alertEvalMu.Unlock()
+	var rules []model.AlertRule
+	dbpkg.DB.Where("status = 1").Order("id asc").Find(&rules)
+	now := time.Now()
+	for _, r := range rules {
+		evaluateAlertRule(r, now)
+	}
+}
+
+func evaluateAlertRule(r model.AlertRule, now time.Time) {
+	nowMs := now.UnixMilli()
+	var states []model.AlertRuleState
+	dbpkg.DB.Where("rule_id = ?", r.ID).Find(&states)
+	bySubject := make(map[string]*model.AlertRuleState, len(states))
+	for i := range states {
+		bySubject[states[i].Subject] = &states[i]
+	}
+	readings := alertReadings(r, nowMs, bySubject)
+	seen := map[string]bool{}
+	silenced := alertSilenced(r, now)
+	for _, rd := range readings {
+		seen[rd.Subject] = true
+		st := bySubject[rd.Subject]
+		if st == nil {
+			st = &model.AlertRuleState{RuleID: r.ID, Subject: rd.Subject}
+			bySubject[rd.Subject] = st
+		}
+		st.NodeID = rd.NodeID
+		st.LastValue = rd.Value
+		if st.Firing == 1 {
+			if !alertCompare(r.Op, rd.Current, alertRecoverLevel(r)) {
+				resolveAlertState(r, st, rd, nowMs)
+			} else if !silenced && (st.LastNotifyMs == 0 || (r.RepeatS > 0 && nowMs-st.LastNotifyMs >= int64(r.RepeatS)*1000)) {
+				notifyAlertState(r, st, rd, "alert_firing", nowMs)
+			}
+		} else if alertCompare(r.Op, rd.Value, r.Threshold) {
+			if st.PendingSinceMs == 0 {
+				st.PendingSinceMs = nowMs
+			}
+			if rd.Windowed || nowMs-st.PendingSinceMs >= int64(r.ForS)*1000 {
+				fireAlertState(r, st, rd, nowMs, silenced)
+			}
+		} else {
+			st.PendingSinceMs = 0
+		}
+		st.UpdatedTime = nowMs
+		_ = dbpkg.DB.Save(st).Error
+	}
+	// subjects that disappeared (node deleted, forward paused, rule scope changed);
+	// an offline node sends no samples, so its episodes stay open until it reports again
+	var offline []int64
+	dbpkg.DB.Model(&model.Node{}).Where("status = 0").Pluck("id", &offline)
+	isOffline := make(map[int64]bool, len(offline))
+	for _, id := range offline {
+		isOffline[id] = true
+	}
+	for subject, st := range bySubject {
+		if seen[subject] {
+			continue
+		}
+		if st.NodeID != nil && isOffline[*st.NodeID] {
+			continue
+		}
+		if st.Firing == 1 {
+			resolveAlertState(r, st, alertReading{Subject: subject, NodeID: st.NodeID, Name: subject, Current: st.LastValue}, nowMs)
+		}
+		if st.ID > 0 {
+			_ = dbpkg.DB.Delete(&model.AlertRuleState{}, st.ID).Error
+		}
+	}
+}
+
+func fireAlertState(r model.AlertRule, st *model.AlertRuleState, rd alertReading, nowMs int64, silenced bool) {
+	st.Firing = 1
+	st.FiredAtMs = nowMs
+	st.LastNotifyMs = 0
+	a := model.Alert{TimeMs: nowMs, Type: "rule", NodeID: rd.NodeID, Message: alertMessage(r, rd, false), Severity: r.Severity, RuleID: &r.ID, State: "open"}
+	if rd.Name != "" {
+		a.NodeName = &rd.Name
+	}
+	_ = dbpkg.DB.Create(&a).Error
+	st.AlertID = a.ID
+	jlog(map[string]interface{}{"event": "alert_firing", "ruleId": r.ID, "subject": rd.Subject, "value": rd.Value, "threshold": r.Threshold})
+	if !silenced {
+		notifyAlertState(r, st, rd, "alert_firing", nowMs)
+	}
+}
+
+func resolveAlertState(r model.AlertRule, st *model.AlertRuleState, rd alertReading, nowMs int64) {
+	notified := st.LastNotifyMs > 0
+	st.Firing = 0
+	st.PendingSinceMs = 0
+	if st.AlertID > 0 {
+		autoResolveAlerts("id = ?", st.AlertID)
+	}
+	auto := "auto"
+	a := model.Alert{TimeMs: nowMs, Type: "rule_resolved", NodeID: rd.NodeID, Message: alertMessage(r, rd, true), Severity: r.Severity, RuleID: &r.ID,
+		State: "resolved", ResolvedAtMs: &nowMs, ResolvedBy: &auto}
+	if rd.Name != "" {
+		a.NodeName = &rd.Name
+	}
+	_ = dbpkg.DB.Create(&a).Error
+	jlog(map[string]interface{}{"event": "alert_resolved", "ruleId": r.ID, "subject": rd.Subject, "value": rd.Current})
+	// a resolution is only worth sending if the firing was
+	if notified {
+		notifyAlertState(r, st, rd, "alert_resolved", nowMs)
+	}
+}
+
+func notifyAlertState(r model.AlertRule, st *model.AlertRuleState, rd alertReading, event string, nowMs int64) {
+	st.LastNotifyMs = nowMs
+	node := model.Node{Name: rd.Name}
+	if rd.NodeID != nil {
+		node.ID = *rd.NodeID
+	}
+	value := rd.Value
+	if event == "alert_resolved" {
+		value = rd.Current
+	}
+	go notifyCallback(event, node, map[string]any{
+		"ruleId":    r.ID,
+		"rule":      r.Name,
+		"metric":    r.Metric,
+		"severity":  r.Severity,
+		"subject":   rd.Subject,
+		"value":     value,
+		"threshold": r.Threshold,
+		"alertId":   st.AlertID,
+		"firedAtMs": st.FiredAtMs,
+		"message":   alertMessage(r, rd, event == "alert_resolved"),
+	})
+}
+
+func alertMessage(r model.AlertRule, rd alertReading, resolved bool) string {
+	m := alertMetrics[r.Metric]
+	subject := rd.Name
+	if subject == "" {
+		subject = rd.Subject
+	}
+	if resolved {
+		return fmt.Sprintf("[%s] %s 已恢复：%s %.2f%s", r.Name, subject, m.label, rd.Current, m.unit)
+	}
+	return fmt.Sprintf("[%s] %s %s %.2f%s %s %.2f%s", r.Name, subject, m.label, rd.Value, m.unit, r.Op, r.Threshold, m.unit)
+}
+
+func alertCompare(op string, v, threshold float64) bool {
+	if op == "<" {
+		return v < threshold
+	}
+	return v > threshold
+}
+
+func alertRecoverLevel(r model.AlertRule) float64 {
+	if r.Recover != nil {
+		return *r.Recover
+	}
+	return r.Threshold
+}
+
+// alertSilenced reports whether notifications are muted right now (daily window in
+// server local time, or a one-off mute).
+func alertSilenced(r model.AlertRule, now time.Time) bool {
+	if r.MutedUntilMs != nil && now.UnixMilli() < *r.MutedUntilMs {
+		return true
+	}
+	from, ok1 := parseClock(r.SilenceFrom)
+	to, ok2 := parseClock(r.SilenceTo)
+	if !ok1 || !ok2 || from == to {
+		return false
+	}
+	cur := now.Hour()*60 + now.Minute()
+	if from < to {
+		return cur >= from && cur < to
+	}
+	return cur >= from || cur < to
+}
+
+// parseClock parses "HH:MM" into minutes of the day.
+func parseClock(s string) (int, bool) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// alertNodeScope returns the node ids a rule is limited to (nil = all).
+func alertNodeScope(r model.AlertRule) []int64 {
+	if r.NodeIDs == nil || *r.NodeIDs == "" {
+		return nil
+	}
+	var ids []int64
+	_ = json.Unmarshal([]byte(*r.NodeIDs), &ids)
+	return ids
+}
+
+func alertReadings(r model.AlertRule, nowMs int64, states map[string]*model.AlertRuleState) []alertReading {
+	scope := alertNodeScope(r)
+	var nodes []model.Node
+	q := dbpkg.DB.Model(&model.Node{})
+	if len(scope) > 0 {
+		q = q.Where("id IN ?", scope)
+	}
+	q.Find(&nodes)
+	nodeName := make(map[int64]string, len(nodes))
+	nodeIDs := make([]int64, 0, len(nodes))
+	for _, n := range nodes {
+		nodeName[n.ID] = n.Name
+		nodeIDs = append(nodeIDs, n.ID)
+	}
+	switch r.Metric {
+	case "cpu", "mem", "disk", "load1", "conntrack":
+		return sysAlertReadings(r, nowMs, nodeIDs, nodeName)
+	case "probe_loss":
+		return probeAlertReadings(r, nowMs, nodeIDs, nodeName)
+	case "offline":
+		out := make([]alertReading, 0)
+		for _, n := range nodes {
+			nid := n.ID
+			rd := alertReading{Subject: fmt.Sprintf("node:%d", n.ID), NodeID: &nid, Name: n.Name}
+			var lg model.NodeDisconnectLog
+			if n.Status != nil && *n.Status == 0 &&
+				dbpkg.DB.Where("node_id = ? AND up_at_ms IS NULL", n.ID).Order("down_at_ms desc").First(&lg).Error == nil {
+				rd.Value = float64(nowMs-lg.DownAtMs) / 60000
+				rd.Current = rd.Value
+			}
+			rd.Windowed = true
+			out = append(out, rd)
+		}
+		return out
+	case "forward_idle":
+		return forwardIdleReadings(r, nowMs, nodeName, states)
+	case "user_quota":
+		var users []model.User
+		dbpkg.DB.Where("flow > 0").Find(&users)
+		out := make([]alertReading, 0, len(users))
+		for _, u := range users {
+			pct := float64(billedUsed(u.InFlow, u.OutFlow)) * 100 / (float64(u.Flow) * 1024 * 1024 * 1024)
+			out = append(out, alertReading{Subject: fmt.Sprintf("user:%d", u.ID), Name: u.User, Value: pct, Current: pct, Windowed: true})
+		}
+		return out
+	}
+	return nil
+}
+
+var sysAlertExpr = map[string]string{
+	"cpu":       "cpu",
+	"mem":       "mem",
+	"disk":      "disk_usage",
+	"load1":     "load1",
+	"conntrack": "CASE WHEN conntrack_max > 0 THEN conntrack_count * 100.0 / conntrack_max ELSE 0 END",
+}
+
+// sysAlertReadings checks the ForS window of raw sysinfo samples: a ">" rule is
+// breached when even the window minimum is above the threshold. Nodes whose samples
+// do not cover the window yet (just connected, offline) are not breached.
+func sysAlertReadings(r model.AlertRule, nowMs int64, nodeIDs []int64, nodeName map[int64]string) []alertReading {
+	if len(nodeIDs) == 0 {
+		return nil
+	}
+	forMs := int64(max(r.ForS, 60)) * 1000
+	expr := sysAlertExpr[r.Metric]
+	agg := "MIN"
+	if r.Op == "<" {
+		agg = "MAX"
+	}
+	var rows []struct {
+		NodeID  int64
+		Value   float64
+		Current float64
+		First   int64
+	}
+	dbpkg.DB.Model(&model.NodeSysInfo{}).
+		Select(fmt.Sprintf("node_id, %[1]s(%[2]s) AS value, COALESCE(AVG(CASE WHEN time_ms >= ? THEN %[2]s END), %[1]s(%[2]s)) AS current, MIN(time_ms) AS first", agg, expr), nowMs-60000).
+		Where("node_id IN ? AND time_ms >= ? AND time_ms <= ?", nodeIDs, nowMs-forMs, nowMs).
+		Group("node_id").Scan(&rows)
+	out := make([]alertReading, 0, len(rows))
+	for _, row := range rows {
+		nid := row.NodeID
+		rd := alertReading{Subject: fmt.Sprintf("node:%d", nid), NodeID: &nid, Name: nodeName[nid], Value: row.Value, Current: row.Current, Windowed: true}
+		// samples arrive every few seconds; require the window to be covered
+		if row.First > nowMs-forMs+30000 {
+			rd.Value = row.Current
+			rd.Windowed = false
+		}
+		out = append(out, rd)
+	}
+	return out
+}
+
+// probeAlertReadings computes the loss percentage per node and target over
+// max(ForS, 5 min).
+func probeAlertReadings(r model.AlertRule, nowMs int64, nodeIDs []int64, nodeName map[int64]string) []alertReading {
+	if len(nodeIDs) == 0 {
+		return nil
+	}
+	winMs := int64(max(r.ForS, 300)) * 1000
+	q := dbpkg.DB.Model(&model.NodeProbeResult{}).
+		Select("node_id, target_id, COUNT(*) AS samples, SUM(ok) AS ok_count").
+		Where("node_id IN ? AND time_ms >= ?", nodeIDs, nowMs-winMs)
+	if r.TargetID != nil && *r.TargetID > 0 {
+		q = q.Where("target_id = ?", *r.TargetID)
+	}
+	var rows []struct {
+		NodeID   int64
+		TargetID int64
+		Samples  int
+		OKCount  int
+	}
+	q.Group("node_id, target_id").Scan(&rows)
+	var targets []model.ProbeTarget
+	dbpkg.DB.Find(&targets)
+	targetName := make(map[int64]string, len(targets))
+	for _, t := range targets {
+		targetName[t.ID] = t.Name
+	}
+	out := make([]alertReading, 0, len(rows))
+	for _, row := range rows {
+		nid := row.NodeID
+		loss := lossPct(row.Samples, row.OKCount)
+		out = append(out, alertReading{
+			Subject:  fmt.Sprintf("node:%d/target:%d", nid, row.TargetID),
+			NodeID:   &nid,
+			Name:     fmt.Sprintf("%s → %s", nodeName[nid], targetName[row.TargetID]),
+			Value:    loss,
+			Current:  loss,
+			Windowed: true,
+		})
+	}
+	return out
+}
+
+// forwardIdleReadings reports how many hours an active forward's cumulative traffic
+// has not changed. The change time is tracked in the rule state, so a forward is
+// first considered idle from the moment the rule starts watching it.
+func forwardIdleReadings(r model.AlertRule, nowMs int64, nodeName map[int64]string, states map[string]*model.AlertRuleState) []alertReading {
+	var forwards []model.Forward
+	dbpkg.DB.Where("status IS NULL OR status = 1").Find(&forwards)
+	var tunnels []model.Tunnel
+	dbpkg.DB.Find(&tunnels)
+	inNode := make(map[int64]int64, len(tunnels))
+	for _, t := range tunnels {
+		inNode[t.ID] = t.InNodeID
+	}
+	scoped := alertNodeScope(r) != nil
+	out := make([]alertReading, 0, len(forwards))
+	for _, f := range forwards {
+		nid := inNode[f.TunnelID]
+		if _, ok := nodeName[nid]; scoped && !ok {
+			continue
+		}
+		subject := fmt.Sprintf("forward:%d", f.ID)
+		st := states[subject]
+		if st == nil {
+			st = &model.AlertRuleState{RuleID: r.ID, Subject: subject}
+			states[subject] = st
+		}
+		total := f.InFlow + f.OutFlow
+		if st.CounterSinceMs == 0 || total != st.Counter {
+			st.Counter = total
+			st.CounterSinceMs = nowMs
+		}
+		hours := float64(nowMs-st.CounterSinceMs) / 3600000
+		name := f.Name
+		if n := nodeName[nid]; n != "" {
+			name = n + " / " + f.Name
+		}
+		rd := alertReading{Subject: subject, Name: name, Value: hours, Current: hours, Windowed: true}
+		if nid > 0 {
+			rd.NodeID = &nid
+		}
+		out = append(out, rd)
+	}
+	return out
+}
+
+// ---- Admin CRUD ----
+
+type alertRuleParams struct {
+	Name         string   `json:"name"`
+	Metric       string   `json:"metric"`
+	Op           string   `json:"op"`
+	Threshold    *float64 `json:"threshold"`
+	Recover      *float64 `json:"recover"`
+	ForS         *int     `json:"forS"`
+	Severity     string   `json:"severity"`
+	NodeIDs      []int64  `json:"nodeIds"`
+	TargetID     *int64   `json:"targetId"`
+	SilenceFrom  *string  `json:"silenceFrom"`
+	SilenceTo    *string  `json:"silenceTo"`
+	MutedUntilMs *int64   `json:"mutedUntilMs"`
+	RepeatS      *int     `json:"repeatS"`
+	Status       *int     `json:"status"`
+}
+
+// apply copies the given fields onto the rule and validates the result.
+func (p alertRuleParams) apply(r *model.AlertRule) string {
+	if p.Name != "" {
+		r.Name = p.Name
+	}
+	if p.Metric != "" {
+		r.Metric = p.Metric
+	}
+	if p.Op != "" {
+		r.Op = p.Op
+	}
+	if p.Threshold != nil {
+		r.Threshold = *p.Threshold
+	}
+	if p.Recover != nil {
+		r.Recover = p.Recover
+	}
+	if p.ForS != nil {
+		r.ForS = *p.ForS
+	}
+	if p.Severity != "" {
+		r.Severity = p.Severity
+	}
+	if p.NodeIDs != nil {
+		if len(p.NodeIDs) == 0 {
+			r.NodeIDs = nil
+		} else {
+			b, _ := json.Marshal(p.NodeIDs)
+			s := string(b)
+			r.NodeIDs = &s
+		}
+	}
+	if p.TargetID != nil {
+		r.TargetID = p.TargetID
+		if *p.TargetID == 0 {
+			r.TargetID = nil
+		}
+	}
+	if p.SilenceFrom != nil {
+		r.SilenceFrom = *p.SilenceFrom
+	}
+	if p.SilenceTo != nil {
+		r.SilenceTo = *p.SilenceTo
+	}
+	if p.MutedUntilMs != nil {
+		r.MutedUntilMs = p.MutedUntilMs
+		if *p.MutedUntilMs == 0 {
+			r.MutedUntilMs = nil
+		}
+	}
+	if p.RepeatS != nil {
+		r.RepeatS = *p.RepeatS
+	}
+	if p.Status != nil {
+		r.Status = *p.Status
+	}
+	if r.Op == "" {
+		r.Op = ">"
+	}
+	if r.Severity == "" {
+		r.Severity = "warning"
+	}
+	switch {
+	case r.Name == "":
+		return "规则名称不能为空"
+	case alertMetrics[r.Metric].label == "":
+		return "不支持的指标"
+	case r.Op != ">" && r.Op != "<":
+		return "比较符仅支持 > 或 <"
+	case !alertSeverities[r.Severity]:
+		return "级别仅支持 info/warning/critical"
+	case r.ForS < 0 || r.RepeatS < 0:
+		return "时长不能为负数"
+	case r.Recover != nil && alertCompare(r.Op, *r.Recover, r.Threshold):
+		return "恢复阈值不能越过告警阈值"
+	}
+	if r.SilenceFrom != "" || r.SilenceTo != "" {
+		if _, ok := parseClock(r.SilenceFrom); !ok {
+			return "静默时段格式应为 HH:MM"
+		}
+		if _, ok := parseClock(r.SilenceTo); !ok {
+			return "静默时段格式应为 HH:MM"
+		}
+	}
+	return ""
+}
+
+// POST /api/v1/alert-rule/list
+func AlertRuleList(c *gin.Context) {
+	var list []model.AlertRule
+	dbpkg.DB.Order("id desc").Find(&list)
+	c.JSON(http.StatusOK, response.Ok(list))
+}
+
+// POST /api/v1/alert-rule/create {name, metric, op?, threshold, recover?, forS?, severity?, nodeIds?, targetId?, silenceFrom?, silenceTo?, repeatS?}
+func AlertRuleCreate(c *gin.Context) {
+	var p alertRuleParams
+	if err := c.ShouldBindJSON(&p); err != nil || p.Threshold == nil {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	now := time.Now().UnixMilli()
+	rec := model.AlertRule{CreatedTime: now, UpdatedTime: now, Status: 1}
+	if msg := p.apply(&rec); msg != "" {
+		c.JSON(http.StatusOK, response.ErrMsg(msg))
+		return
+	}
+	if err := dbpkg.DB.Create(&rec).Error; err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("保存失败"))
+		return
+	}
+	c.JSON(http.StatusOK, response.Ok(rec))
+}
+
+// POST /api/v1/alert-rule/update {id, ...same as create, status?}
+func AlertRuleUpdate(c *gin.Context) {
+	var p struct {
+		ID int64 `json:"id" binding:"required"`
+		alertRuleParams
+	}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	var rec model.AlertRule
+	if err := dbpkg.DB.First(&rec, p.ID).Error; err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("不存在"))
+		return
+	}
+	metric := rec.Metric
+	if msg := p.apply(&rec); msg != "" {
+		c.JSON(http.StatusOK, response.ErrMsg(msg))
+		return
+	}
+	rec.UpdatedTime = time.Now().UnixMilli()
+	alertEvalMu.Lock()
+	defer alertEvalMu.Unlock()
+	if err := dbpkg.DB.Save(&rec).Error; err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("保存失败"))
+		return
+	}
+	// subjects of another metric are unrelated; start over
+	if rec.Metric != metric || rec.Status != 1 {
+		clearRuleStates(rec.ID)
+	}
+	c.JSON(http.StatusOK, response.Ok(rec))
+}
+
+// POST /api/v1/alert-rule/delete {id}
+func AlertRuleDelete(c *gin.Context) {
+	var p struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	alertEvalMu.Lock()
+	defer alertEvalMu.Unlock()
+	_ = dbpkg.DB.Delete(&model.AlertRule{}, p.ID).Error
+	clearRuleStates(p.ID)
+	c.JSON(http.StatusOK, response.OkNoData())
+}
+
+// clearRuleStates drops the rule's evaluation states and resolves its open
+// alerts, which no evaluation would resolve any more.
+func clearRuleStates(ruleID int64) {
+	autoResolveAlerts("rule_id = ?", ruleID)
+	dbpkg.DB.Where("rule_id = ?", ruleID).Delete(&model.AlertRuleState{})
+}
+
+// POST /api/v1/alert-rule/states {ruleId?, firing?}
+// Current evaluation state per rule and subject.
+func AlertRuleStates(c *gin.Context) {
+	var p struct {
+		RuleID int64 `json:"ruleId"`
+		Firing *bool `json:"firing"`
+	}
+	_ = c.ShouldBindJSON(&p)
+	q := dbpkg.DB.Model(&model.AlertRuleState{})
+	if p.RuleID > 0 {
+		q = q.Where("rule_id = ?", p.RuleID)
+	}
+	if p.Firing != nil {
+		if *p.Firing {
+			q = q.Where("firing = 1")
+		} else {
+			q = q.Where("firing = 0")
+		}
+	}
+	var list []model.AlertRuleState
+	q.Order("rule_id asc, subject asc").Find(&list)
+	c.JSON(http.StatusOK, response.Ok(list))
+}
@@ -6,8 +6,33 @@ import (
 	"network-panel/golang-backend/internal/app/model"
 	"network-panel/golang-backend/internal/app/response"
 	dbpkg "network-panel/golang-backend/internal/db"
+	"strings"
+	"time"
 )
 
+// Alert states: open -> acked -> resolved. Offline alerts are resolved when the
+// node comes back, rule alerts when their episode clears; anything else (due
+// reminders) stays until resolved by hand.
+var activeAlertStates = []string{"open", "acked"}
+
+// autoResolveAlerts resolves the open/acked alerts matching the condition.
+func autoResolveAlerts(query string, args ...interface{}) {
+	now := time.Now().UnixMilli()
+	dbpkg.DB.Model(&model.Alert{}).Where("state IN ?", activeAlertStates).Where(query, args...).
+		Updates(map[string]any{"state": "resolved", "resolved_at_ms": now, "resolved_by": "auto"})
+}
+
+// alertActor returns the name of the current user for ack/resolve records.
+func alertActor(c *gin.Context) string {
+	uidInf, _ := c.Get("user_id")
+	uid, _ := uidInf.(int64)
+	var u model.User
+	if uid > 0 && dbpkg.DB.First(&u, uid).Error == nil {
+		return u.User
+	}
+	return "admin"
+}
+
 // POST /api/v1/alerts/recent {limit?}
 func AlertsRecent(c *gin.Context) {
 	var p struct {
@@ -21,3 +46,145 @@ func AlertsRecent(c *gin.Context) {
 	dbpkg.DB.Order("time_ms desc").Limit(p.Limit).Find(&list)
 	c.JSON(http.StatusOK, response.Ok(list))
 }
+
+// POST /api/v1/alerts/list {nodeId?, type?|types?, state?|states?, severity?, from?, to?, page?, size?}
+// type/state accept a single value or several separated by commas.
+func AlertList(c *gin.Context) {
+	var p struct {
+		NodeID   int64    `json:"nodeId"`
+		Types    []string `json:"types"`
+		Type     string   `json:"type"`
+		States   []string `json:"states"`
+		State    string   `json:"state"`
+		Severity string   `json:"severity"`
+		From     int64    `json:"from"`
+		To       int64    `json:"to"`
+		Page     int      `json:"page"`
+		Size     int      `json:"size"`
+	}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	if p.Page <= 0 {
+		p.Page = 1
+	}
+	if p.Size <= 0 || p.Size > 200 {
+		p.Size = 50
+	}
+	q := dbpkg.DB.Model(&model.Alert{})
+	if p.NodeID > 0 {
+		q = q.Where("node_id = ?", p.NodeID)
+	}
+	if types := splitList(p.Types, p.Type); len(types) > 0 {
+		q = q.Where("type IN ?", types)
+	}
+	if states := splitList(p.States, p.State); len(states) > 0 {
+		q = q.Where("state IN ?", states)
+	}
+	if p.Severity != "" {
+		q = q.Where("severity = ?", p.Severity)
+	}
+	if p.From > 0 {
+		q = q.Where("time_ms >= ?", p.From)
+	}
+	if p.To > 0 {
+		q = q.Where("time_ms < ?", p.To)
+	}
+	var total int64
+	q.Count(&total)
+	var list []model.Alert
+	q.Order("time_ms desc, id desc").Offset((p.Page - 1) * p.Size).Limit(p.Size).Find(&list)
+	c.JSON(http.StatusOK, response.Ok(map[string]any{"list": list, "total": total}))
+}
+
+// POST /api/v1/alerts/ack {ids, comment?}
+// Acknowledges open alerts; acked alerts still resolve automatically.
+func AlertAck(c *gin.Context) {
+	var p struct {
+		IDs     []int64 `json:"ids" binding:"required"`
+		Comment string  `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&p); err != nil || len(p.IDs) == 0 {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	upd := map[string]any{"state": "acked", "ack_by": alertActor(c), "ack_at_ms": time.Now().UnixMilli()}
+	if p.Comment != "" {
+		upd["ack_comment"] = p.Comment
+	}
+	res := dbpkg.DB.Model(&model.Alert{}).Where("id IN ? AND state = ?", p.IDs, "open").Updates(upd)
+	c.JSON(http.StatusOK, response.Ok(map[string]any{"updated": res.RowsAffected}))
+}
+
+// POST /api/v1/alerts/resolve {ids, comment?}
+func AlertResolve(c *gin.Context) {
+	var p struct {
+		IDs     []int64 `json:"ids" binding:"required"`
+		Comment string  `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&p); err != nil || len(p.IDs) == 0 {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	upd := map[string]any{"state": "resolved", "resolved_by": alertActor(c), "resolved_at_ms": time.Now().UnixMilli()}
+	if p.Comment != "" {
+		upd["resolve_comment"] = p.Comment
+	}
+	res := dbpkg.DB.Model(&model.Alert{}).Where("id IN ? AND state IN ?", p.IDs, activeAlertStates).Updates(upd)
+	c.JSON(http.StatusOK, response.Ok(map[string]any{"updated": res.RowsAffected}))
+}
+
+// POST /api/v1/alerts/summary {nodeId?}
+// Counts of unresolved alerts for a dashboard badge.
+func AlertSummary(c *gin.Context) {
+	var p struct {
+		NodeID int64 `json:"nodeId"`
+	}
+	_ = c.ShouldBindJSON(&p)
+	q := dbpkg.DB.Model(&model.Alert{}).Where("state IN ?", activeAlertStates)
+	if p.NodeID > 0 {
+		q = q.Where("node_id = ?", p.NodeID)
+	}
+	var rows []struct {
+		State    string
+		Type     string
+		Severity string
+		N        int64
+	}
+	q.Select("state, type, COALESCE(severity, '') AS severity, COUNT(*) AS n").Group("state, type, severity").Scan(&rows)
+	var open, acked int64
+	byType := map[string]int64{}
+	bySeverity := map[string]int64{}
+	for _, r := range rows {
+		if r.State == "open" {
+			open += r.N
+			sev := r.Severity
+			if sev == "" {
+				sev = "warning"
+			}
+			bySeverity[sev] += r.N
+		} else {
+			acked += r.N
+		}
+		byType[r.Type] += r.N
+	}
+	out := map[string]any{"open": open, "acked": acked, "total": open + acked, "byType": byType, "openBySeverity": bySeverity}
+	c.JSON(http.StatusOK, response.Ok(out))
+}
+
+// splitList merges a list parameter with its comma separated single-value form.
+func splitList(list []string, csv string) []string {
+	out := make([]string, 0, len(list))
+	for _, s := range list {
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	for _, s := range strings.Split(csv, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
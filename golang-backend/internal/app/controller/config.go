@@ -2,6 +2,7 @@ package controller
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"network-panel/golang-backend/internal/app/model"
@@ -12,12 +13,16 @@ import (
 )
 
 // POST /api/v1/config/list
+// Anonymous and non-admin callers only get public settings (see settingDefs).
 func ConfigList(c *gin.Context) {
 	var items []model.ViteConfig
 	dbpkg.DB.Find(&items)
+	admin := isAdminCaller(c)
 	m := map[string]string{}
 	for _, it := range items {
-		m[it.Name] = it.Value
+		if settingVisible(it.Name, admin) {
+			m[it.Name] = it.Value
+		}
 	}
 	c.JSON(http.StatusOK, response.Ok(m))
 }
@@ -31,6 +36,10 @@ func ConfigGet(c *gin.Context) {
 		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
 		return
 	}
+	if !settingVisible(p.Name, isAdminCaller(c)) {
+		c.JSON(http.StatusOK, response.Ok(""))
+		return
+	}
 	var it model.ViteConfig
 	if err := dbpkg.DB.Where("name = ?", p.Name).First(&it).Error; err != nil {
 		c.JSON(http.StatusOK, response.Ok(""))
@@ -39,21 +48,40 @@ func ConfigGet(c *gin.Context) {
 	c.JSON(http.StatusOK, response.Ok(it.Value))
 }
 
+// POST /api/v1/config/schema
+// Known settings with type, default, visibility and current value.
+func ConfigSchema(c *gin.Context) {
+	stored := storedSettings()
+	out := make([]map[string]any, 0, len(settingDefs))
+	for _, d := range settingDefs {
+		item := map[string]any{"def": d}
+		if v, ok := stored[d.Key]; ok {
+			item["value"] = v
+		}
+		out = append(out, item)
+	}
+	c.JSON(http.StatusOK, response.Ok(out))
+}
+
 // POST /api/v1/config/update {"k":"v"...}
+// Keys must be registered settings with valid values; the whole batch is
+// rejected on the first error.
 func ConfigUpdate(c *gin.Context) {
 	var m map[string]string
 	if err := c.ShouldBindJSON(&m); err != nil {
 		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
 		return
 	}
+	stored := storedSettings()
+	if msg := validateSettingUpdate(m, stored); msg != "" {
+		c.JSON(http.StatusOK, response.ErrMsg(msg))
+		return
+	}
 	for k, v := range m {
-		var it model.ViteConfig
-		if err := dbpkg.DB.Where("name = ?", k).First(&it).Error; err != nil {
-			it.Name, it.Value, it.Time = k, v, timeNow()
-			dbpkg.DB.Create(&it)
-		} else {
-			dbpkg.DB.Model(&it).Updates(map[string]any{"value": v, "time": timeNow()})
+		if old, ok := stored[k]; ok && old == v {
+			continue
 		}
+		writeSetting(k, strings.TrimSpace(v))
 	}
 	c.JSON(http.StatusOK, response.OkNoData())
 }
@@ -65,14 +93,38 @@ func ConfigUpdateSingle(c *gin.Context) {
 		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
 		return
 	}
+	if msg := validateSettingUpdate(map[string]string{p.Name: p.Value}, storedSettings()); msg != "" {
+		c.JSON(http.StatusOK, response.ErrMsg(msg))
+		return
+	}
+	writeSetting(p.Name, strings.TrimSpace(p.Value))
+	c.JSON(http.StatusOK, response.OkNoData())
+}
+
+func storedSettings() map[string]string {
+	var items []model.ViteConfig
+	dbpkg.DB.Find(&items)
+	m := make(map[string]string, len(items))
+	for _, it := range items {
+		m[it.Name] = it.Value
+	}
+	return m
+}
+
+// writeSetting stores a value; an empty value deletes the row so readers fall
+// back to the registered default.
+func writeSetting(name, value string) {
+	if value == "" {
+		dbpkg.DB.Where("name = ?", name).Delete(&model.ViteConfig{})
+		return
+	}
 	var it model.ViteConfig
-	if err := dbpkg.DB.Where("name = ?", p.Name).First(&it).Error; err != nil {
-		it.Name, it.Value, it.Time = p.Name, p.Value, timeNow()
+	if err := dbpkg.DB.Where("name = ?", name).First(&it).Error; err != nil {
+		it.Name, it.Value, it.Time = name, value, timeNow()
 		dbpkg.DB.Create(&it)
 	} else {
-		dbpkg.DB.Model(&it).Updates(map[string]any{"value": p.Value, "time": timeNow()})
+		dbpkg.DB.Model(&it).Updates(map[string]any{"value": value, "time": timeNow()})
 	}
-	c.JSON(http.StatusOK, response.OkNoData())
 }
 
 func timeNow() int64 { return time.Now().UnixMilli() }
@@ -8,11 +8,12 @@ import (
     "network-panel/golang-backend/internal/app/model"
 )
 
-// getConfigString returns configuration value from vite_config by name, empty if missing.
+// getConfigString returns configuration value from vite_config by name, or the
+// registered default (settingDefs) if missing or empty.
 func getConfigString(name string) string {
     var it model.ViteConfig
-    if err := dbpkg.DB.Where("name = ?", name).First(&it).Error; err != nil {
-        return ""
+    if err := dbpkg.DB.Where("name = ?", name).First(&it).Error; err != nil || strings.TrimSpace(it.Value) == "" {
+        return settingDefault(name)
     }
     return strings.TrimSpace(it.Value)
 }
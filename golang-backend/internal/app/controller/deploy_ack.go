@@ -0,0 +1,340 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"network-panel/golang-backend/internal/app/model"
+	dbpkg "network-panel/golang-backend/internal/db"
+)
+
+// serviceAck is a node's ServiceResult reply to a service command sent with a
+// requestId.
+type serviceAck struct {
+	Success bool               `json:"success"`
+	Message string             `json:"message"`
+	Results []serviceAckResult `json:"results"`
+
+	shutdown bool // released by CloseConnections, the outcome is unknown
+}
+
+type serviceAckResult struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+var (
+	errNodeOffline = errors.New("not connected")
+	errNoAck       = errors.New("no acknowledgement")
+	// the server shut down while waiting; the node may or may not have applied
+	// the command, so it is neither rolled back nor retried as failed
+	errShutdown = errors.New("server shutting down")
+)
+
+var (
+	svcAckMu      sync.Mutex
+	svcAckWaiters = map[string]chan serviceAck{}
+)
+
+// deliverServiceAck hands a ServiceResult to the waiting sender; false when
+// nobody waits any more (timed out).
+func deliverServiceAck(reqID string, data interface{}) bool {
+	svcAckMu.Lock()
+	ch := svcAckWaiters[reqID]
+	delete(svcAckWaiters, reqID)
+	svcAckMu.Unlock()
+	if ch == nil {
+		return false
+	}
+	var ack serviceAck
+	b, _ := json.Marshal(data)
+	_ = json.Unmarshal(b, &ack)
+	ch <- ack
+	return true
+}
+
+// releaseServiceAcks answers every sender still waiting for an acknowledgement
+// with a shutdown result.
+func releaseServiceAcks() int {
+	svcAckMu.Lock()
+	defer svcAckMu.Unlock()
+	n := 0
+	for id, ch := range svcAckWaiters {
+		select {
+		case ch <- serviceAck{Message: errShutdown.Error(), shutdown: true}:
+		default:
+		}
+		delete(svcAckWaiters, id)
+		n++
+	}
+	return n
+}
+
+// sendServiceCommand sends a service command (AddService, UpdateService,
+// DeleteService, PauseService, ResumeService, or RestartGost with the services to
+// check) and waits for the node to report the per-service results. The error covers not connected, no reply within
+// deploy_ack_timeout_sec and any service the node failed to apply.
+func sendServiceCommand(nodeID int64, cmdType string, data interface{}) (ack serviceAck, err error) {
+	defer func() { recordServicePush(nodeID, cmdType, data, err) }()
+	reqID := RandUUID()
+	ch := make(chan serviceAck, 1)
+	svcAckMu.Lock()
+	svcAckWaiters[reqID] = ch
+	svcAckMu.Unlock()
+	defer func() {
+		svcAckMu.Lock()
+		delete(svcAckWaiters, reqID)
+		svcAckMu.Unlock()
+	}()
+	if err = writeWSCommand(nodeID, cmdType, data, reqID); err != nil {
+		return ack, err
+	}
+	timeout := time.Duration(getConfigInt("deploy_ack_timeout_sec", 10)) * time.Second
+	select {
+	case ack = <-ch:
+		if ack.shutdown {
+			return ack, fmt.Errorf("node %d %s: %w", nodeID, cmdType, errShutdown)
+		}
+	case <-time.After(timeout):
+		return ack, fmt.Errorf("node %d %s: %w within %s", nodeID, cmdType, errNoAck, timeout)
+	}
+	var failed []string
+	for _, r := range ack.Results {
+		if !r.OK {
+			failed = append(failed, r.Name+": "+r.Error)
+		}
+	}
+	switch {
+	case len(failed) > 0:
+		return ack, errors.New(strings.Join(failed, "; "))
+	case !ack.Success:
+		return ack, errors.New(ack.Message)
+	}
+	return ack, nil
+}
+
+// pushRecorder collects the outcome of the commands sent to each node during a
+// deployment. An ack recorder waits for service commands to be acknowledged and
+// remembers the services each node added, so a failed deployment can be rolled
+// back; a nil recorder just sends.
+type pushRecorder struct {
+	mu    sync.Mutex
+	ack   bool
+	nodes map[int64]*nodeDeployResult
+	added map[int64][]string
+	errs  []nodeError
+	// batch defers gost restarts to flushRestarts, so a job deploying many
+	// forwards restarts each node once
+	batch   bool
+	restart []int64
+}
+
+type nodeDeployResult struct {
+	NodeID   int64  `json:"nodeId"`
+	NodeName string `json:"nodeName"`
+	Sent     int    `json:"sent"`
+	Failed   int    `json:"failed"`
+	Queued   int    `json:"queued"` // waiting in the node's command queue
+	Error    string `json:"error,omitempty"`
+}
+
+type nodeError struct {
+	nodeID int64
+	err    error
+}
+
+func newPushRecorder() *pushRecorder {
+	return &pushRecorder{nodes: map[int64]*nodeDeployResult{}, added: map[int64][]string{}}
+}
+
+func newAckRecorder() *pushRecorder {
+	r := newPushRecorder()
+	r.ack = true
+	return r
+}
+
+// send delivers a command. Service commands a nil or plain recorder sends are
+// queued for an offline node; an ack recorder queues only queueableCmds and
+// fails the rest.
+func (r *pushRecorder) send(nodeID int64, cmd string, data interface{}) error {
+	if !servicePushCmds[cmd] {
+		return r.record(nodeID, cmd, data, sendWSCommand(nodeID, cmd, data), false)
+	}
+	if r == nil || !r.ack {
+		return r.record(nodeID, cmd, data, sendOrQueue(nodeID, cmd, data), false)
+	}
+	if queueableCmds[cmd] && hasQueuedCommands(nodeID) {
+		err := queueServiceCommand(nodeID, cmd, data)
+		go replayNodeQueue(nodeID)
+		return r.record(nodeID, cmd, data, err, err == nil)
+	}
+	if hasQueuedCommands(nodeID) {
+		dropSuperseded(nodeID, cmd, data)
+	}
+	ack, err := sendServiceCommand(nodeID, cmd, data)
+	if errors.Is(err, errNodeOffline) && queueableCmds[cmd] {
+		err = queueServiceCommand(nodeID, cmd, data)
+		return r.record(nodeID, cmd, data, err, err == nil)
+	}
+	if err != nil && cmd == "AddService" {
+		// services the node applied before others failed still need rolling back
+		r.mu.Lock()
+		for _, res := range ack.Results {
+			if res.OK {
+				r.added[nodeID] = append(r.added[nodeID], res.Name)
+			}
+		}
+		r.mu.Unlock()
+	}
+	return r.record(nodeID, cmd, data, err, false)
+}
+
+func (r *pushRecorder) record(nodeID int64, cmd string, data interface{}, err error, queued bool) error {
+	if r == nil || nodeID <= 0 {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.nodes[nodeID]
+	if n == nil {
+		n = &nodeDeployResult{NodeID: nodeID}
+		r.nodes[nodeID] = n
+	}
+	n.Sent++
+	if queued {
+		n.Queued++
+	}
+	if err != nil {
+		n.Failed++
+		n.Error = err.Error()
+		r.errs = append(r.errs, nodeError{nodeID, err})
+	} else if cmd == "AddService" && !queued {
+		b, _ := json.Marshal(data)
+		r.added[nodeID] = append(r.added[nodeID], pushServiceNames(b)...)
+	}
+	return err
+}
+
+// results returns the per-node results ordered by node id.
+func (r *pushRecorder) results() []nodeDeployResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]nodeDeployResult, 0, len(r.nodes))
+	for _, n := range r.nodes {
+		out = append(out, *n)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].NodeID < out[j].NodeID })
+	return out
+}
+
+// interrupted reports whether a command was cut short by the server shutting
+// down, so the deployment must not be rolled back.
+func (r *pushRecorder) interrupted() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.errs {
+		if errors.Is(e.err, errShutdown) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *pushRecorder) errCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.errs)
+}
+
+// failure describes the first failed command, "" when all succeeded.
+func (r *pushRecorder) failure() string { return r.failureSince(0) }
+
+// failureSince describes the first command that failed after the first skip
+// failures, "" when none did.
+func (r *pushRecorder) failureSince(skip int) string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.errs) <= skip {
+		return ""
+	}
+	e := r.errs[skip]
+	name := fmt.Sprintf("#%d", e.nodeID)
+	var n model.Node
+	if dbpkg.DB.Select("name").First(&n, e.nodeID).Error == nil && n.Name != "" {
+		name = n.Name
+	}
+	return fmt.Sprintf("节点 %s 下发失败: %v", name, e.err)
+}
+
+// restartGost restarts gost on a node so service changes take effect; a batch
+// recorder only notes the node for flushRestarts. An ack recorder waits for the
+// node to restart gost and confirm that the services it added hold their ports,
+// so a port taken by another process fails (and rolls back) the deployment.
+func (r *pushRecorder) restartGost(nodeID int64, opId string) {
+	if nodeID <= 0 {
+		return
+	}
+	if r != nil && r.batch {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for _, nid := range r.restart {
+			if nid == nodeID {
+				return
+			}
+		}
+		r.restart = append(r.restart, nodeID)
+		return
+	}
+	if r == nil || !r.ack {
+		_ = r.send(nodeID, "RestartGost", map[string]any{"reason": "forward_update"})
+		_ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: nodeID, Cmd: "ForwardRestartGost", RequestID: opId, Success: 1, Message: "restart gost"}).Error
+		return
+	}
+	r.mu.Lock()
+	names := append([]string(nil), r.added[nodeID]...)
+	r.mu.Unlock()
+	_, err := sendServiceCommand(nodeID, "RestartGost", map[string]any{"reason": "forward_update", "services": names})
+	_ = r.record(nodeID, "RestartGost", nil, err, false)
+	op := model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: nodeID, Cmd: "ForwardRestartGost", RequestID: opId, Success: 1, Message: "restart gost"}
+	if err != nil {
+		op.Success, op.Message = 0, "restart gost: "+err.Error()
+	}
+	_ = dbpkg.DB.Create(&op).Error
+}
+
+// flushRestarts restarts every node a batch recorder noted, once each.
+func (r *pushRecorder) flushRestarts(opId string) {
+	r.mu.Lock()
+	nodes := r.restart
+	r.restart, r.batch = nil, false
+	r.mu.Unlock()
+	for _, nid := range nodes {
+		r.restartGost(nid, opId)
+	}
+}
+
+// rollback removes the services the deployment added on the nodes that
+// acknowledged them.
+func (r *pushRecorder) rollback() {
+	r.mu.Lock()
+	added := r.added
+	r.added = map[int64][]string{}
+	r.mu.Unlock()
+	for nid, names := range added {
+		if err := sendOrQueue(nid, "DeleteService", map[string]any{"services": names}); err != nil {
+			jlog(map[string]interface{}{"event": "deploy_rollback_failed", "nodeId": nid, "services": names, "error": err.Error()})
+		}
+	}
+}
@@ -2,7 +2,6 @@ package controller
 
 import (
 	"fmt"
-	"log"
 	"net"
 	"time"
 )
@@ -31,7 +30,7 @@ func diagnoseFromNodeCtx(nodeID int64, host string, port int, count int, timeout
 	if ctx != nil {
 		payload["ctx"] = ctx
 	}
-	log.Printf("%s", fmt.Sprintf("{\"event\":\"diagnose_begin\",\"mode\":\"tcp\",\"nodeId\":%d,\"reqId\":\"%s\",\"host\":\"%s\",\"port\":%d,\"count\":%d,\"timeoutMs\":%d,\"ctx\":%v}", nodeID, rid, host, port, count, timeoutMs, ctx))
+	jlog(map[string]interface{}{"event": "diagnose_begin", "mode": "tcp", "nodeId": nodeID, "reqId": rid, "host": host, "port": port, "count": count, "timeoutMs": timeoutMs, "ctx": ctx})
 	if err := sendWSCommand(nodeID, "Diagnose", payload); err != nil {
 		return 0, 100, false, "节点未在线或密钥不匹配", rid
 	}
@@ -105,7 +104,7 @@ func diagnosePingFromNodeCtx(nodeID int64, host string, count int, timeoutMs int
 	if ctx != nil {
 		payload["ctx"] = ctx
 	}
-	log.Printf("%s", fmt.Sprintf("{\"event\":\"diagnose_begin\",\"mode\":\"icmp\",\"nodeId\":%d,\"reqId\":\"%s\",\"host\":\"%s\",\"count\":%d,\"timeoutMs\":%d,\"ctx\":%v}", nodeID, rid, host, count, timeoutMs, ctx))
+	jlog(map[string]interface{}{"event": "diagnose_begin", "mode": "icmp", "nodeId": nodeID, "reqId": rid, "host": host, "count": count, "timeoutMs": timeoutMs, "ctx": ctx})
 	if err := sendWSCommand(nodeID, "Diagnose", payload); err != nil {
 		return 0, 100, false, "节点未在线或密钥不匹配", rid
 	}
@@ -0,0 +1,245 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"network-panel/golang-backend/internal/app/model"
+	"network-panel/golang-backend/internal/app/response"
+	dbpkg "network-panel/golang-backend/internal/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Drift: the agent compares the svchash of each desired service with the hash
+// of its gost.json entry and reports what is missing, changed or not desired.
+// The panel keeps the latest report per node and re-pushes the desired config
+// of the drifted services, waiting for the node's acknowledgement. Automatic
+// fixes are skipped while the node has queued commands and when the drift is
+// larger than drift_fix_max; extras are only removed for agents running with
+// STRICT_RECONCILE or on request.
+
+var (
+	driftMu     sync.Mutex
+	driftFixing = map[int64]bool{}
+)
+
+// POST /api/v1/agent/report-drift {secret, missing, changed, extra, strict, desired}
+func AgentReportDrift(c *gin.Context) {
+	var p struct {
+		Secret  string   `json:"secret" binding:"required"`
+		Missing []string `json:"missing"`
+		Changed []string `json:"changed"`
+		Extra   []string `json:"extra"`
+		Strict  bool     `json:"strict"`
+		Desired int      `json:"desired"`
+	}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	var node model.Node
+	if err := dbpkg.DB.Where("secret = ?", p.Secret).First(&node).Error; err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("节点不存在"))
+		return
+	}
+	var d model.NodeDrift
+	dbpkg.DB.Where("node_id = ?", node.ID).First(&d)
+	d.NodeID = node.ID
+	d.Missing = strings.Join(p.Missing, ",")
+	d.Changed = strings.Join(p.Changed, ",")
+	d.Extra = strings.Join(p.Extra, ",")
+	d.Strict = p.Strict
+	d.DesiredCount = p.Desired
+	d.CheckedTime = time.Now().UnixMilli()
+	if err := dbpkg.DB.Save(&d).Error; err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("保存失败"))
+		return
+	}
+	drifted := len(p.Missing) + len(p.Changed)
+	if p.Strict {
+		drifted += len(p.Extra)
+	}
+	if drifted == 0 {
+		c.JSON(http.StatusOK, response.Ok(map[string]any{"fix": "none"}))
+		return
+	}
+	jlog(map[string]interface{}{"event": "node_drift_reported", "nodeId": node.ID, "missing": p.Missing, "changed": p.Changed, "extra": p.Extra})
+	if getConfigString("drift_auto_fix") == "false" {
+		c.JSON(http.StatusOK, response.Ok(map[string]any{"fix": "manual"}))
+		return
+	}
+	go fixNodeDrift(node.ID, p.Strict, false)
+	c.JSON(http.StatusOK, response.Ok(map[string]any{"fix": "scheduled"}))
+}
+
+// fixNodeDrift re-pushes the desired config of the node's missing and changed
+// services and, with removeExtras, deletes its extras. An automatic fix (manual
+// false) gives up when the drift exceeds drift_fix_max. The outcome is stored
+// in the report.
+func fixNodeDrift(nodeID int64, removeExtras, manual bool) (string, error) {
+	driftMu.Lock()
+	if driftFixing[nodeID] {
+		driftMu.Unlock()
+		return "", fmt.Errorf("修复进行中")
+	}
+	driftFixing[nodeID] = true
+	driftMu.Unlock()
+	defer func() {
+		driftMu.Lock()
+		delete(driftFixing, nodeID)
+		driftMu.Unlock()
+	}()
+
+	var d model.NodeDrift
+	if err := dbpkg.DB.Where("node_id = ?", nodeID).First(&d).Error; err != nil {
+		return "", fmt.Errorf("暂无漂移报告")
+	}
+	result, err := applyDriftFix(d, removeExtras, manual)
+	upd := map[string]any{"fixed_time": time.Now().UnixMilli(), "fix_result": result}
+	if err != nil {
+		upd["fix_result"] = err.Error()
+	}
+	dbpkg.DB.Model(&model.NodeDrift{}).Where("id = ?", d.ID).Updates(upd)
+	jlog(map[string]interface{}{"event": "node_drift_fix", "nodeId": nodeID, "manual": manual, "result": upd["fix_result"]})
+	return result, err
+}
+
+func applyDriftFix(d model.NodeDrift, removeExtras, manual bool) (string, error) {
+	want := map[string]bool{}
+	for _, n := range append(splitNames(d.Missing), splitNames(d.Changed)...) {
+		want[n] = true
+	}
+	extras := []string{}
+	if removeExtras {
+		extras = splitNames(d.Extra)
+	}
+	if len(want)+len(extras) == 0 {
+		return "无需修复", nil
+	}
+	if hasQueuedCommands(d.NodeID) {
+		// the queue replay changes these services anyway; fix on the next report
+		return "", fmt.Errorf("节点有待下发命令，重放完成后再修复")
+	}
+	if limit := getConfigInt("drift_fix_max", 20); !manual && len(want)+len(extras) > limit {
+		return "", fmt.Errorf("漂移服务数 %d 超过自动修复上限 %d，需手动修复", len(want)+len(extras), limit)
+	}
+
+	services, _ := desiredServices(d.NodeID)
+	desired := map[string]bool{}
+	push := make([]map[string]any, 0, len(want))
+	for _, svc := range services {
+		n, _ := svc["name"].(string)
+		desired[n] = true
+		if want[n] {
+			push = append(push, svc)
+		}
+	}
+	var remove []string
+	for _, n := range extras {
+		if !desired[n] && !driftOwnedService(n) {
+			remove = append(remove, n)
+		}
+	}
+	var errs []string
+	pushed, removed := 0, 0
+	if len(push) > 0 {
+		if _, err := sendServiceCommand(d.NodeID, "AddService", push); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			pushed = len(push)
+			dbpkg.DB.Model(&model.NodeDrift{}).Where("id = ?", d.ID).Updates(map[string]any{"missing": "", "changed": ""})
+		}
+	}
+	if len(remove) > 0 {
+		if _, err := sendServiceCommand(d.NodeID, "DeleteService", map[string]any{"services": remove}); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			removed = len(remove)
+			dbpkg.DB.Model(&model.NodeDrift{}).Where("id = ?", d.ID).Update("extra", "")
+		}
+	}
+	result := fmt.Sprintf("已重新下发 %d 个服务，删除 %d 个服务", pushed, removed)
+	if len(errs) > 0 {
+		return result, fmt.Errorf("%s；失败: %s", result, strings.Join(errs, "; "))
+	}
+	return result, nil
+}
+
+// driftOwnedService reports whether a service the desired state does not list
+// still belongs to an existing forward, e.g. one being redeployed; only services
+// of deleted forwards are removed as extras.
+func driftOwnedService(name string) bool {
+	head, _, ok := strings.Cut(name, "_")
+	if !ok {
+		return false
+	}
+	fid, err := strconv.ParseInt(head, 10, 64)
+	if err != nil {
+		return false
+	}
+	var n int64
+	dbpkg.DB.Model(&model.Forward{}).Where("id = ?", fid).Count(&n)
+	return n > 0
+}
+
+func splitNames(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// POST /api/v1/node/drift/list {nodeId?}
+func NodeDriftList(c *gin.Context) {
+	var p struct {
+		NodeID int64 `json:"nodeId"`
+	}
+	_ = c.ShouldBindJSON(&p)
+	q := dbpkg.DB.Model(&model.NodeDrift{}).Order("node_id")
+	if p.NodeID > 0 {
+		q = q.Where("node_id = ?", p.NodeID)
+	}
+	var rows []model.NodeDrift
+	q.Find(&rows)
+	type item struct {
+		model.NodeDrift
+		NodeName string `json:"nodeName"`
+		Online   bool   `json:"online"`
+	}
+	out := make([]item, 0, len(rows))
+	for _, r := range rows {
+		it := item{NodeDrift: r}
+		var n model.Node
+		if dbpkg.DB.Select("name").First(&n, r.NodeID).Error == nil {
+			it.NodeName = n.Name
+		}
+		nodeConnMu.RLock()
+		it.Online = len(nodeConns[r.NodeID]) > 0
+		nodeConnMu.RUnlock()
+		out = append(out, it)
+	}
+	c.JSON(http.StatusOK, response.Ok(out))
+}
+
+// POST /api/v1/node/drift/fix {nodeId, removeExtras?}
+func NodeDriftFix(c *gin.Context) {
+	var p struct {
+		NodeID       int64 `json:"nodeId" binding:"required"`
+		RemoveExtras bool  `json:"removeExtras"`
+	}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	result, err := fixNodeDrift(p.NodeID, p.RemoveExtras, true)
+	if err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.OkMsg(result))
+}
@@ -249,7 +249,7 @@ func EasyTierJoin(c *gin.Context) {
 		// compute server base url
 		host := getCfg("ip")
 		if host != "" && !strings.HasPrefix(host, "http") {
-			host = "http://" + host
+			host = panelScheme() + "://" + host
 		}
 		if host == "" {
 			host = "/"
@@ -314,7 +314,7 @@ func ensureMasterJoined(nodeID int64, ip string, port int) {
 	payload := map[string]any{"requestId": RandUUID(), "timeoutSec": 300}
 	host := getCfg("ip")
 	if host != "" && !strings.HasPrefix(host, "http") {
-		host = "http://" + host
+		host = panelScheme() + "://" + host
 	}
 	if host == "" {
 		host = "/"
@@ -683,13 +683,13 @@ func EasyTierRedeployMaster(c *gin.Context) {
 	c.JSON(http.StatusOK, response.OkMsg("已重新部署主控"))
 }
 
-// helpers to get/set ViteConfig
+// helpers to get/set ViteConfig; a missing or empty row reads as the registered default
 func getCfg(name string) string {
 	var v model.ViteConfig
-	if err := dbpkg.DB.Where("name = ?", name).First(&v).Error; err == nil {
+	if err := dbpkg.DB.Where("name = ?", name).First(&v).Error; err == nil && strings.TrimSpace(v.Value) != "" {
 		return v.Value
 	}
-	return ""
+	return settingDefault(name)
 }
 func setCfg(name, value string) {
 	now := time.Now().UnixMilli()
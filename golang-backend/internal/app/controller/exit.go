@@ -54,8 +54,9 @@ func NodeSetExit(c *gin.Context) {
 		"rlimiter": p.RLimiter,
 		"metadata": p.Metadata,
 	})
-	if err := sendWSCommand(p.NodeID, "AddService", []map[string]any{svc}); err != nil {
-		c.JSON(http.StatusOK, response.ErrMsg("发送到节点失败: "+err.Error()))
+	// settings are saved only once the node confirmed the service
+	if _, err := sendServiceCommand(p.NodeID, "AddService", []map[string]any{svc}); err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("下发到节点失败: "+err.Error()))
 		return
 	}
 
@@ -98,6 +99,21 @@ func NodeSetExit(c *gin.Context) {
 	c.JSON(http.StatusOK, response.OkMsg("出口节点服务已创建/更新"))
 }
 
+// exitSettingService renders the SS service of saved exit settings, as
+// NodeSetExit pushed it.
+func exitSettingService(es model.ExitSetting) map[string]any {
+	var meta map[string]any
+	if es.Metadata != nil && *es.Metadata != "" {
+		_ = json.Unmarshal([]byte(*es.Metadata), &meta)
+	}
+	return buildSSService(fmt.Sprintf("exit_ss_%d", es.Port), es.Port, es.Password, es.Method, map[string]any{
+		"observer": deref(es.Observer),
+		"limiter":  deref(es.Limiter),
+		"rlimiter": deref(es.RLimiter),
+		"metadata": meta,
+	})
+}
+
 // POST /api/v1/node/get-exit {nodeId}
 // Returns last saved SS exit settings for node if any
 func NodeGetExit(c *gin.Context) {
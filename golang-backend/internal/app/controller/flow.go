@@ -13,7 +13,6 @@ import (
 	dbpkg "network-panel/golang-backend/internal/db"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 func FlowConfig(c *gin.Context) { c.String(http.StatusOK, "ok") }
@@ -99,68 +98,10 @@ func FlowUpload(c *gin.Context) {
 		var tun model.Tunnel
 		_ = dbpkg.DB.First(&tun, fwd.TunnelID).Error
 
-		inInc, outInc := inBytes, outBytes
-		// 配额判断：按单向（取本次入/出中较大的值）
-		quotaInc := inInc
-		if outInc > quotaInc {
-			quotaInc = outInc
-		}
-
-		// apply increments (forward, user, user_tunnel)
-		dbpkg.DB.Model(&model.Forward{}).Where("id = ?", fwdID).
-			Updates(map[string]any{"in_flow": gorm.Expr("in_flow + ?", inInc), "out_flow": gorm.Expr("out_flow + ?", outInc), "updated_time": time.Now().UnixMilli()})
-		dbpkg.DB.Model(&model.User{}).Where("id = ?", fwd.UserID).
-			Updates(map[string]any{"in_flow": gorm.Expr("in_flow + ?", inInc), "out_flow": gorm.Expr("out_flow + ?", outInc), "updated_time": time.Now().UnixMilli()})
-		// user_tunnel
 		var ut model.UserTunnel
-		if err := dbpkg.DB.Where("user_id=? and tunnel_id=?", fwd.UserID, fwd.TunnelID).First(&ut).Error; err == nil && ut.ID > 0 {
-			dbpkg.DB.Model(&model.UserTunnel{}).Where("id = ?", ut.ID).
-				Updates(map[string]any{"in_flow": gorm.Expr("in_flow + ?", inInc), "out_flow": gorm.Expr("out_flow + ?", outInc)})
-		}
-		// 24h statistics (per user, bucket by hour HH:00). For single-flow tunnel, count max(in,out); else sum.
-		func() {
-			calc := inInc + outInc
-			if tun.Flow == 1 {
-				if outInc > inInc {
-					calc = outInc
-				} else {
-					calc = inInc
-				}
-			}
-			// bucket key: HH:00 (UTC+8)
-			cst := time.FixedZone("UTC+8", 8*3600)
-			now := time.Now().In(cst)
-			hourKey := now.Format("15:00")
-			var rec model.StatisticsFlow
-			if err := dbpkg.DB.Where("user_id = ? AND time = ?", fwd.UserID, hourKey).First(&rec).Error; err == nil && rec.ID > 0 {
-				dbpkg.DB.Model(&model.StatisticsFlow{}).Where("id = ?", rec.ID).
-					Updates(map[string]any{"flow": gorm.Expr("flow + ?", calc), "total_flow": gorm.Expr("total_flow + ?", calc)})
-			} else {
-				rec = model.StatisticsFlow{UserID: fwd.UserID, Flow: calc, TotalFlow: calc, Time: hourKey, CreatedTime: now.UnixMilli()}
-				_ = dbpkg.DB.Create(&rec).Error
-			}
-		}()
-
-		// limits：仅在配额判断时使用单向增量估算
-		var user model.User
-		if err := dbpkg.DB.First(&user, fwd.UserID).Error; err == nil {
-			limit := user.Flow * 1024 * 1024 * 1024
-			used := user.InFlow + user.OutFlow
-			projected := used + quotaInc - (inInc + outInc)
-			if (limit > 0 && projected > limit) || expired(user.ExpTime) || (user.Status != nil && *user.Status != 1) {
-				pauseAllUserForwards(user.ID)
-				s := 0
-				user.Status = &s
-				_ = dbpkg.DB.Save(&user).Error
-			}
-		}
-		if ut.ID != 0 {
-			if overUTunnelLimit(ut) || expired(ut.ExpTime) || ut.Status != 1 {
-				pauseUserTunnelForwards(ut.UserID, ut.TunnelID)
-				ut.Status = 0
-				_ = dbpkg.DB.Save(&ut).Error
-			}
-		}
+		_ = dbpkg.DB.Where("user_id=? and tunnel_id=?", fwd.UserID, fwd.TunnelID).First(&ut).Error
+		chargeTransfer(fwdID, fwd.UserID, ut.ID, tun, inBytes, outBytes)
+		enforceQuota(fwd.UserID, ut.ID)
 		c.String(http.StatusOK, "ok")
 		return
 	}
@@ -190,72 +131,15 @@ func FlowUpload(c *gin.Context) {
 	}
 	var tun model.Tunnel
 	_ = dbpkg.DB.First(&tun, fwd.TunnelID).Error
-	inInc, outInc := payload.U, payload.D
-	quotaInc := inInc
-	if outInc > quotaInc {
-		quotaInc = outInc
-	}
-	dbpkg.DB.Model(&model.Forward{}).Where("id = ?", fwdID).Updates(map[string]any{"in_flow": gorm.Expr("in_flow + ?", inInc), "out_flow": gorm.Expr("out_flow + ?", outInc), "updated_time": time.Now().UnixMilli()})
-	dbpkg.DB.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]any{"in_flow": gorm.Expr("in_flow + ?", inInc), "out_flow": gorm.Expr("out_flow + ?", outInc), "updated_time": time.Now().UnixMilli()})
-	if utID != 0 {
-		dbpkg.DB.Model(&model.UserTunnel{}).Where("id = ?", utID).Updates(map[string]any{"in_flow": gorm.Expr("in_flow + ?", inInc), "out_flow": gorm.Expr("out_flow + ?", outInc)})
-	}
-	// 24h statistics bucket update (same rule as above)
-	func() {
-		calc := inInc + outInc
-		if tun.Flow == 1 {
-			if outInc > inInc {
-				calc = outInc
-			} else {
-				calc = inInc
-			}
-		}
-		cst := time.FixedZone("UTC+8", 8*3600)
-		now := time.Now().In(cst)
-		hourKey := now.Format("15:00")
-		var rec model.StatisticsFlow
-		if err := dbpkg.DB.Where("user_id = ? AND time = ?", userID, hourKey).First(&rec).Error; err == nil && rec.ID > 0 {
-			dbpkg.DB.Model(&model.StatisticsFlow{}).Where("id = ?", rec.ID).
-				Updates(map[string]any{"flow": gorm.Expr("flow + ?", calc), "total_flow": gorm.Expr("total_flow + ?", calc)})
-		} else {
-			rec = model.StatisticsFlow{UserID: userID, Flow: calc, TotalFlow: calc, Time: hourKey, CreatedTime: now.UnixMilli()}
-			_ = dbpkg.DB.Create(&rec).Error
-		}
-	}()
-
-	var user model.User
-	if err := dbpkg.DB.First(&user, userID).Error; err == nil {
-		limit := user.Flow * 1024 * 1024 * 1024
-		used := user.InFlow + user.OutFlow
-		projected := used + quotaInc - (inInc + outInc)
-		if (limit > 0 && projected > limit) || expired(user.ExpTime) || (user.Status != nil && *user.Status != 1) {
-			pauseAllUserForwards(user.ID)
-			s := 0
-			user.Status = &s
-			_ = dbpkg.DB.Save(&user).Error
-		}
-	}
-	if utID != 0 {
-		var ut model.UserTunnel
-		if err := dbpkg.DB.First(&ut, utID).Error; err == nil {
-			if overUTunnelLimit(ut) || expired(ut.ExpTime) || ut.Status != 1 {
-				pauseUserTunnelForwards(ut.UserID, ut.TunnelID)
-				ut.Status = 0
-				_ = dbpkg.DB.Save(&ut).Error
-			}
-		}
-	}
+	chargeTransfer(fwdID, userID, utID, tun, payload.U, payload.D)
+	enforceQuota(userID, utID)
 	c.String(http.StatusOK, "ok")
 }
 
-// Over user limit if flow(GiB) <= in + out
-func overUserLimit(u model.User) bool {
-	limit := u.Flow * 1024 * 1024 * 1024
-	return limit > 0 && (u.InFlow+u.OutFlow) > limit
-}
+// Over limit when billed usage exceeds the quota (see accounting.go)
+func overUserLimit(u model.User) bool { return overQuota(u.Flow, u.InFlow, u.OutFlow) }
 func overUTunnelLimit(ut model.UserTunnel) bool {
-	limit := ut.Flow * 1024 * 1024 * 1024
-	return limit > 0 && (ut.InFlow+ut.OutFlow) > limit
+	return overQuota(ut.Flow, ut.InFlow, ut.OutFlow)
 }
 func expired(ts *int64) bool { return ts != nil && *ts > 0 && *ts <= time.Now().UnixMilli() }
 
@@ -266,11 +150,7 @@ func pauseAllUserForwards(userID int64) {
 		dbpkg.DB.Model(&model.Forward{}).Where("id = ?", f.ID).Update("status", 0)
 		var t model.Tunnel
 		if err := dbpkg.DB.First(&t, f.TunnelID).Error; err == nil {
-			name := buildServiceName(f.ID, f.UserID, f.TunnelID)
-			_ = sendWSCommand(t.InNodeID, "PauseService", map[string]interface{}{"services": []string{name}})
-			if t.Type == 2 {
-				_ = sendWSCommand(outNodeIDOr0(t), "PauseService", map[string]interface{}{"services": []string{name}})
-			}
+			pushForwardPaused(nil, t, f, true)
 		}
 	}
 }
@@ -281,11 +161,7 @@ func pauseUserTunnelForwards(userID, tunnelID int64) {
 		dbpkg.DB.Model(&model.Forward{}).Where("id = ?", f.ID).Update("status", 0)
 		var t model.Tunnel
 		if err := dbpkg.DB.First(&t, f.TunnelID).Error; err == nil {
-			name := buildServiceName(f.ID, f.UserID, f.TunnelID)
-			_ = sendWSCommand(t.InNodeID, "PauseService", map[string]interface{}{"services": []string{name}})
-			if t.Type == 2 {
-				_ = sendWSCommand(outNodeIDOr0(t), "PauseService", map[string]interface{}{"services": []string{name}})
-			}
+			pushForwardPaused(nil, t, f, true)
 		}
 	}
 }
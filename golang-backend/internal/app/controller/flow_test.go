@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"network-panel/golang-backend/internal/app/model"
+	dbpkg "network-panel/golang-backend/internal/db"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useTestDB points dbpkg.DB at a fresh SQLite database with the given tables.
+func useTestDB(t *testing.T, models ...any) {
+	t.Helper()
+	db, err := dbpkg.OpenSQLite(filepath.Join(t.TempDir(), "test.db"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Skipf("sqlite unavailable: %v", err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	prev := dbpkg.DB
+	dbpkg.DB = db
+	t.Cleanup(func() {
+		dbpkg.DB = prev
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+}
+
+func TestFlowUploadTrafficRatio(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestDB(t, &model.Node{}, &model.Tunnel{}, &model.Forward{}, &model.User{}, &model.UserTunnel{}, &model.StatisticsFlow{})
+	if err := dbpkg.DB.Create(&model.Node{Name: "n", Secret: "s"}).Error; err != nil {
+		t.Fatalf("create node: %v", err)
+	}
+	const in, out = 1000, 300
+	cases := []struct {
+		name              string
+		flow              int
+		ratio             float64
+		billIn, billOut   int64
+		rawFlow, statFlow int64
+	}{
+		// single direction: only the larger direction is billed
+		{"single ratio 0", billingMax, 0, 0, 0, 1000, 0},
+		{"single ratio 0.5", billingMax, 0.5, 500, 0, 1000, 500},
+		{"single ratio 1", billingMax, 1, 1000, 0, 1000, 1000},
+		{"single ratio 2", billingMax, 2, 2000, 0, 1000, 2000},
+		// double direction: both directions are billed
+		{"double ratio 0", billingSum, 0, 0, 0, 1300, 0},
+		{"double ratio 0.5", billingSum, 0.5, 500, 150, 1300, 650},
+		{"double ratio 1", billingSum, 1, 1000, 300, 1300, 1300},
+		{"double ratio 2", billingSum, 2, 2000, 600, 1300, 2600},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ratio := tc.ratio
+			tun := model.Tunnel{Name: tc.name, Flow: tc.flow, TrafficRatio: &ratio}
+			if err := dbpkg.DB.Create(&tun).Error; err != nil {
+				t.Fatalf("create tunnel: %v", err)
+			}
+			user := model.User{User: tc.name}
+			if err := dbpkg.DB.Create(&user).Error; err != nil {
+				t.Fatalf("create user: %v", err)
+			}
+			ut := model.UserTunnel{UserID: user.ID, TunnelID: tun.ID, Status: 1}
+			if err := dbpkg.DB.Create(&ut).Error; err != nil {
+				t.Fatalf("create user_tunnel: %v", err)
+			}
+			fwd := model.Forward{UserID: user.ID, TunnelID: tun.ID, Name: tc.name}
+			if err := dbpkg.DB.Create(&fwd).Error; err != nil {
+				t.Fatalf("create forward: %v", err)
+			}
+
+			body := fmt.Sprintf(`{"events":[{"kind":"service","service":"%d_%d_%d","type":"stats","stats":{"inputBytes":%d,"outputBytes":%d}}]}`, fwd.ID, user.ID, ut.ID, in, out)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/flow/upload?secret=s", strings.NewReader(body))
+			FlowUpload(c)
+
+			dbpkg.DB.First(&fwd, fwd.ID)
+			if fwd.InFlow != in || fwd.OutFlow != out {
+				t.Errorf("forward = %d/%d, want raw %d/%d", fwd.InFlow, fwd.OutFlow, in, out)
+			}
+			dbpkg.DB.First(&user, user.ID)
+			if user.InFlow != tc.billIn || user.OutFlow != tc.billOut {
+				t.Errorf("user = %d/%d, want %d/%d", user.InFlow, user.OutFlow, tc.billIn, tc.billOut)
+			}
+			dbpkg.DB.First(&ut, ut.ID)
+			if ut.InFlow != tc.billIn || ut.OutFlow != tc.billOut {
+				t.Errorf("user_tunnel = %d/%d, want %d/%d", ut.InFlow, ut.OutFlow, tc.billIn, tc.billOut)
+			}
+			var stat model.StatisticsFlow
+			if err := dbpkg.DB.Where("user_id = ?", user.ID).First(&stat).Error; err != nil {
+				t.Fatalf("statistics_flow: %v", err)
+			}
+			if stat.RawFlow != tc.rawFlow || stat.Flow != tc.statFlow || stat.TotalFlow != tc.statFlow {
+				t.Errorf("statistics raw/flow/total = %d/%d/%d, want %d/%d/%d", stat.RawFlow, stat.Flow, stat.TotalFlow, tc.rawFlow, tc.statFlow, tc.statFlow)
+			}
+		})
+	}
+}
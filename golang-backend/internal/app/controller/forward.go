@@ -6,7 +6,6 @@ import (
 	"log"
 	"net"
 	"net/http"
-	"network-panel/golang-backend/internal/app/util"
 	"strconv"
 	"strings"
 	"time"
@@ -17,7 +16,6 @@ import (
 	dbpkg "network-panel/golang-backend/internal/db"
 
 	"github.com/gin-gonic/gin"
-    "gorm.io/gorm"
 )
 
 // POST /api/v1/forward/create
@@ -27,6 +25,26 @@ func ForwardCreate(c *gin.Context) {
 		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
 		return
 	}
+	remote, msg := normalizeTargets(req.RemoteAddr, req.Targets)
+	if msg != "" {
+		c.JSON(http.StatusOK, response.ErrMsg(msg))
+		return
+	}
+	if remote == "" {
+		c.JSON(http.StatusOK, response.ErrMsg("远程地址不能为空"))
+		return
+	}
+	strategy, ok := normalizeStrategy(req.Strategy)
+	if !ok {
+		c.JSON(http.StatusOK, response.ErrMsg("负载策略无效"))
+		return
+	}
+	proto, ok := normalizeForwardProtocol(req.Protocol)
+	if !ok {
+		c.JSON(http.StatusOK, response.ErrMsg("转发协议无效"))
+		return
+	}
+	req.RemoteAddr, req.Strategy, req.Protocol = remote, strategy, proto
 	uidInf, _ := c.Get("user_id")
 	uid := uidInf.(int64)
 	var tun model.Tunnel
@@ -71,7 +89,7 @@ func ForwardCreate(c *gin.Context) {
 		return
 	}
 	now := time.Now().UnixMilli()
-	f := model.Forward{BaseEntity: model.BaseEntity{CreatedTime: now, UpdatedTime: now}, UserID: uid, Name: req.Name, TunnelID: req.TunnelID, InPort: inPort, RemoteAddr: req.RemoteAddr, InterfaceName: req.InterfaceName, Strategy: req.Strategy}
+	f := model.Forward{BaseEntity: model.BaseEntity{CreatedTime: now, UpdatedTime: now}, UserID: uid, Name: req.Name, TunnelID: req.TunnelID, InPort: inPort, RemoteAddr: req.RemoteAddr, InterfaceName: req.InterfaceName, Strategy: req.Strategy, Protocol: req.Protocol}
 	// allocate outPort for tunnel-forward
 	if tun.Type == 2 {
 		if op := firstFreePortOut(tun, 0); op != 0 {
@@ -102,326 +120,26 @@ func ForwardCreate(c *gin.Context) {
 		c.JSON(http.StatusOK, response.ErrMsg("端口转发创建失败"))
 		return
 	}
-	// push to node(s)
+	// push to node(s); every hop must acknowledge, otherwise the forward is rolled back
 	opId := RandUUID()
-	name := buildServiceName(f.ID, f.UserID, f.TunnelID)
-	if tun.Type == 2 && f.OutPort != nil {
-		// gRPC HTTP 隧道（出口=relay+grpc，入口=http+chain(dialer=grpc, connector=relay)）
-		user := fmt.Sprintf("u-%d", f.ID)
-		pass := util.MD5(fmt.Sprintf("%d:%d", f.ID, f.CreatedTime))[:16]
-        outSvc := map[string]any{
-            "name":     name,
-            "addr":     fmt.Sprintf(":%d", *f.OutPort),
-            "listener": map[string]any{"type": "grpc"},
-            // 出口不再配置 chain，仅作为 relay 服务端
-            "handler":  map[string]any{"type": "relay", "auth": map[string]any{"username": user, "password": pass}},
-            "metadata": map[string]any{"managedBy": "network-panel", "enableStats": true, "observer.period": "5s", "observer.resetTraffic": false},
-        }
-		_ = sendWSCommand(outNodeIDOr0(tun), "AddService", []map[string]any{outSvc})
-
-		// 计算出口地址：优先使用出口节点的监听IP(在隧道编辑里配置的 inIp/bind)，否则使用隧道/节点出口IP
-		outIP := getOutNodeIP(tun)
-		bindMap := getTunnelBindMap(tun.ID)
-		if v, ok := bindMap[outNodeIDOr0(tun)]; ok && v != "" {
-			outIP = v
-		}
-		exitAddr := safeHostPort(outIP, *f.OutPort)
-
-		// Multi-level path support for tunnel-forward:
-		// - exit: relay over gRPC (server)
-		// - mids: plain TCP forward (listen on port, forward to next hop addr:port)
-		// - entry: HTTP handler with chain(connector=relay, dialer=grpc) targeting FIRST MID addr:port
-		path := getTunnelPathNodes(tun.ID)
-		if len(path) > 0 {
-			// Pre-allocate TCP ports on mids (avoid conflicts using agent query)
-			// 优化：当“上一跳出口IP”和“下一跳入口IP”均为 10.126.126.* 组网内网时，端口不受节点端口范围限制，仅需 >=1000 且未被占用
-			midPorts := make([]int, len(path))
-			ifaceMap := getTunnelIfaceMap(tun.ID) // 出站(接口)IP
-			bindMap := getTunnelBindMap(tun.ID)   // 入站(监听)IP
-			for i := range path {
-				// 计算上一跳出口IP：entry 使用 inNode 的接口IP；中间节点使用前一个 mid 的接口IP
-				var prevID int64
-				if i == 0 {
-					prevID = tun.InNodeID
-				} else {
-					prevID = path[i-1]
-				}
-				prevOut := ifaceMap[prevID]
-				nextIn := bindMap[path[i]]
-				overlay := isOverlayIP(prevOut) && isOverlayIP(nextIn)
-				var n model.Node
-				_ = dbpkg.DB.First(&n, path[i]).Error
-				// 分配端口：
-				// - overlay 相邻：范围固定 10000-65535，按最小可用优先
-				// - 否则：遵循节点端口范围
-				if overlay {
-					midPorts[i] = findFreePortOnNodeAny(path[i], 10000, 10000)
-				} else {
-					// 受节点端口范围限制
-					minP := 10000
-					maxP := 65535
-					if n.PortSta > 0 {
-						minP = n.PortSta
-					}
-					if n.PortEnd > 0 {
-						maxP = n.PortEnd
-					}
-					midPorts[i] = findFreePortOnNode(path[i], minP, minP, maxP)
-				}
-				if midPorts[i] == 0 {
-					if overlay {
-						midPorts[i] = 10000
-					} else {
-						minP := 10000
-						if n.PortSta > 0 {
-							minP = n.PortSta
-						}
-						midPorts[i] = minP
-					}
-				}
-			}
-			// Deploy simple TCP forward on each mid to the next hop
-			for i := 0; i < len(path); i++ {
-				nid := path[i]
-				thisPort := midPorts[i]
-				var target string
-				if i < len(path)-1 {
-					var nx model.Node
-					if err := dbpkg.DB.First(&nx, path[i+1]).Error; err != nil {
-						continue
-					}
-					// 优先使用下一跳的入站绑定IP（用户在隧道编辑中选的入口IP），否则回退到节点信息（preferIPv4）
-                nextBind := bindMap[path[i+1]]
-                host := nextBind
-                if host == "" { host = preferIPv4(nx) }
-                if host == "" { host = firstIPAny(nx) }
-                target = safeHostPort(host, midPorts[i+1])
-				} else {
-					// last mid forwards to exit relay (gRPC) address directly
-					target = exitAddr
-				}
-				midName := fmt.Sprintf("%s_mid_%d", name, i)
-				var iface *string
-				if ip, ok := ifaceMap[nid]; ok && ip != "" {
-					tmp := ip
-					iface = &tmp
-				}
-				// bind IP (in IP) for mids and exit (nid != entry)
-				addrStr := fmt.Sprintf(":%d", thisPort)
-				/*if bindIP, ok := bindMap[nid]; ok && bindIP != "" {
-					addrStr = safeHostPort(bindIP, thisPort)
-				}*/
-                svc := map[string]any{
-                    "name":      midName,
-                    "addr":      addrStr,
-                    "listener":  map[string]any{"type": "tcp"},
-                    "handler":   map[string]any{"type": "forward"},
-                    "forwarder": map[string]any{"nodes": []map[string]any{{"name": "target", "addr": target}}},
-                    "metadata":  map[string]any{"managedBy": "network-panel", "enableStats": true, "observer.period": "5s", "observer.resetTraffic": false},
-                }
-				if iface != nil && *iface != "" {
-					svc["metadata"].(map[string]any)["interface"] = *iface
-				}
-				_ = sendWSCommand(nid, "AddService", []map[string]any{svc})
-				if b, err := json.Marshal(svc); err == nil {
-					s := string(b)
-					_ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: nid, Cmd: "ForwardAddService", RequestID: opId, Success: 1, Message: fmt.Sprintf("create mid svc %s port=%d", midName, thisPort), Stdout: &s}).Error
-				}
-			}
-			// Entry node: forward handler + chain to first mid address using dialer=grpc（负载将被各中间节点逐跳TCP转发）
-			var first model.Node
-			if err := dbpkg.DB.First(&first, path[0]).Error; err == nil {
-            inSvc := map[string]any{
-                "name":     name,
-                "addr":     fmt.Sprintf(":%d", f.InPort),
-                "listener": map[string]any{"type": "tcp"},
-                "handler":  map[string]any{"type": "forward", "chain": "chain_" + name},
-                "metadata": map[string]any{"managedBy": "network-panel", "enableStats": true, "observer.period": "5s", "observer.resetTraffic": false},
-            }
-            if obsName, spec := buildObserverPluginSpec(tun.InNodeID, name); obsName != "" && spec != nil {
-                inSvc["observer"] = obsName
-                inSvc["_observers"] = []any{spec}
-            }
-			// attach interface for entry if configured
-			if ip, ok := ifaceMap[tun.InNodeID]; ok && ip != "" {
-				if meta, ok2 := inSvc["metadata"].(map[string]any); ok2 {
-					meta["interface"] = ip
-				}
-			}
-				chainName := "chain_" + name
-				hopName := "hop_" + name
-				node := map[string]any{
-					"name": "node-" + name,
-					// Important: dial to FIRST MID address; prefer该节点的入站绑定IP（用户选择的入口IP）
-					"addr": safeHostPort(func() string {
-						if v := bindMap[path[0]]; v != "" {
-							return v
-						}
-						return preferIPv4(first)
-					}(), midPorts[0]),
-					"connector": map[string]any{"type": "relay", "auth": map[string]any{"username": user, "password": pass}},
-					"dialer":    map[string]any{"type": "grpc"},
-				}
-        inSvc["_chains"] = []any{map[string]any{"name": chainName, "metadata": map[string]any{"managedBy": "network-panel", "enableStats": true, "observer.period": "5s", "observer.resetTraffic": false}, "hops": []any{map[string]any{"name": hopName, "nodes": []any{node}}}}}
-				// forwarder 目标为远程地址（取第一项）
-				inSvc["forwarder"] = map[string]any{"nodes": []map[string]any{{"name": "target", "addr": firstTargetHost(f.RemoteAddr)}}}
-				_ = sendWSCommand(tun.InNodeID, "AddService", []map[string]any{inSvc})
-				if b, err := json.Marshal(inSvc); err == nil {
-					s := string(b)
-					_ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: tun.InNodeID, Cmd: "ForwardAddService", RequestID: opId, Success: 1, Message: "create entry svc", Stdout: &s}).Error
-				}
-			}
-		} else {
-			// Original single-hop: in -> exit relay
-            inSvc := map[string]any{
-                "name":     name,
-                "addr":     fmt.Sprintf(":%d", f.InPort),
-                "listener": map[string]any{"type": "tcp"},
-                "handler":  map[string]any{"type": "forward", "chain": "chain_" + name},
-                "metadata": map[string]any{"managedBy": "network-panel", "enableStats": true, "observer.period": "5s", "observer.resetTraffic": false},
-            }
-            if obsName, spec := buildObserverPluginSpec(tun.InNodeID, name); obsName != "" && spec != nil {
-                inSvc["observer"] = obsName
-                inSvc["_observers"] = []any{spec}
-            }
-			chainName := "chain_" + name
-			hopName := "hop_" + name
-			node := map[string]any{
-				"name": "node-" + name,
-				// 出口优先使用监听IP（组网场景用 10.126.126.X），否则回退到节点/隧道出口IP
-				"addr":      exitAddr,
-				"connector": map[string]any{"type": "relay", "auth": map[string]any{"username": user, "password": pass}},
-				"dialer":    map[string]any{"type": "grpc"},
-			}
-            inSvc["_chains"] = []any{map[string]any{"name": chainName, "metadata": map[string]any{"managedBy": "network-panel", "enableStats": true, "observer.period": "5s", "observer.resetTraffic": false}, "hops": []any{map[string]any{"name": hopName, "nodes": []any{node}}}}}
-			// forwarder 目标为远程地址（取第一项）
-			inSvc["forwarder"] = map[string]any{"nodes": []map[string]any{{"name": "target", "addr": firstTargetHost(f.RemoteAddr)}}}
-			_ = sendWSCommand(tun.InNodeID, "AddService", []map[string]any{inSvc})
-			if b, err := json.Marshal(inSvc); err == nil {
-				s := string(b)
-				_ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: tun.InNodeID, Cmd: "ForwardAddService", RequestID: opId, Success: 1, Message: "create entry svc", Stdout: &s}).Error
-			}
-			// restart in & out to ensure effect
-			_ = sendWSCommand(tun.InNodeID, "RestartGost", map[string]any{"reason": "forward_create"})
-			_ = sendWSCommand(outNodeIDOr0(tun), "RestartGost", map[string]any{"reason": "forward_create"})
-		}
-		// restart gost on involved nodes to ensure services take effect
-		nodesToRestart := make(map[int64]struct{})
-		nodesToRestart[tun.InNodeID] = struct{}{}
-		if len(path) > 0 {
-			for _, nid := range path {
-				nodesToRestart[nid] = struct{}{}
-			}
-		}
-		nodesToRestart[outNodeIDOr0(tun)] = struct{}{}
-		for nid := range nodesToRestart {
-			if nid > 0 {
-				_ = sendWSCommand(nid, "RestartGost", map[string]any{"reason": "forward_create"})
-			}
-		}
-	} else {
-		// port-forward: support multi-level path
-		path := getTunnelPathNodes(tun.ID)
-		ifaceMap := getTunnelIfaceMap(tun.ID)
-		bindMap := getTunnelBindMap(tun.ID)
-		if len(path) == 0 {
-			// single hop: in-node listens on inPort and forwards to remoteAddr
-			var iface *string
-			// entry iface mapping takes precedence, then forward/interfaceName, then tunnel.interfaceName
-			if ip, ok := ifaceMap[tun.InNodeID]; ok && ip != "" {
-				tmp := ip
-				iface = &tmp
-			} else {
-				iface = preferIface(f.InterfaceName, tun.InterfaceName)
-			}
-            svc := buildServiceConfig(name, f.InPort, f.RemoteAddr, iface)
-            if obsName, spec := buildObserverPluginSpec(tun.InNodeID, name); obsName != "" && spec != nil {
-                svc["observer"] = obsName
-                svc["_observers"] = []any{spec}
-            }
-			_ = sendWSCommand(tun.InNodeID, "AddService", []map[string]any{svc})
-			_ = sendWSCommand(tun.InNodeID, "RestartGost", map[string]any{"reason": "forward_create"})
-		} else {
-			// chain: [inNode -> mid1 -> mid2 -> ... -> last]
-			hops := append([]int64{tun.InNodeID}, path...)
-			hopPorts := make([]int, len(hops))
-			// entry uses requested inPort
-			hopPorts[0] = f.InPort
-			for i := 1; i < len(hops); i++ {
-				prevID := hops[i-1]
-				curID := hops[i]
-				prevOut := ifaceMap[prevID]
-				curIn := bindMap[curID]
-				overlay := isOverlayIP(prevOut) && isOverlayIP(curIn)
-				var n model.Node
-				_ = dbpkg.DB.First(&n, curID).Error
-				if overlay {
-					// 固定范围 10000-65535，自小向上找空闲
-					p := findFreePortOnNodeAny(curID, 10000, 10000)
-					if p == 0 {
-						p = 10000
-					}
-					hopPorts[i] = p
-				} else {
-					minP, maxP := 10000, 65535
-					if n.PortSta > 0 {
-						minP = n.PortSta
-					}
-					if n.PortEnd > 0 {
-						maxP = n.PortEnd
-					}
-					p := findFreePortOnNode(curID, minP, minP, maxP)
-					if p == 0 {
-						p = minP
-					}
-					hopPorts[i] = p
-				}
-				_ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: curID, Cmd: "ForwardPortPick", RequestID: opId, Success: 1, Message: fmt.Sprintf("hop port=%d (%s)", hopPorts[i], ifThen(overlay, "overlay", "range"))}).Error
-			}
-			// deploy services per hop
-			for i := 0; i < len(hops); i++ {
-				nodeID := hops[i]
-				listenPort := hopPorts[i]
-				var target string
-				if i < len(hops)-1 {
-					var n model.Node
-					if err := dbpkg.DB.First(&n, hops[i+1]).Error; err != nil {
-						continue
-					}
-					host := bindMap[hops[i+1]]
-					if host == "" {
-						host = n.ServerIP
-					}
-					target = safeHostPort(host, hopPorts[i+1])
-				} else {
-					target = f.RemoteAddr
-				}
-				var iface *string
-				if ip, ok := ifaceMap[nodeID]; ok && ip != "" {
-					tmp := ip
-					iface = &tmp
-				} else {
-					iface = preferIface(f.InterfaceName, tun.InterfaceName)
-				}
-				svc := buildServiceConfig(name, listenPort, target, iface)
-				_ = sendWSCommand(nodeID, "AddService", []map[string]any{svc})
-				if b, err := json.Marshal(svc); err == nil {
-					s := string(b)
-					_ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: nodeID, Cmd: "ForwardAddService", RequestID: opId, Success: 1, Message: fmt.Sprintf("create hop svc port=%d", listenPort), Stdout: &s}).Error
-				}
-			}
-			// restart gost on all hops
-			nodesToRestart := make(map[int64]struct{})
-			for _, nid := range hops {
-				nodesToRestart[nid] = struct{}{}
-			}
-			for nid := range nodesToRestart {
-				if nid > 0 {
-					_ = sendWSCommand(nid, "RestartGost", map[string]any{"reason": "forward_create"})
-					_ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: nid, Cmd: "ForwardRestartGost", RequestID: opId, Success: 1, Message: "restart gost"}).Error
-				}
-			}
-		}
+	rec := newAckRecorder()
+	msg = deployForward(f, f, tun, opId, rec)
+	if msg == "" {
+		msg = rec.failure()
+	}
+	if msg != "" && rec.interrupted() {
+		// the nodes may have applied it; keep the forward for the reconcile after restart
+		jlog(map[string]interface{}{"event": "forward_deploy_interrupted", "forwardId": f.ID, "requestId": opId, "error": msg})
+		c.JSON(http.StatusOK, response.ErrMsg("服务正在关闭，转发已保存，节点重连后按期望状态核对: "+msg))
+		return
+	}
+	if msg != "" {
+		rec.rollback()
+		dbpkg.DB.Where("forward_id = ?", f.ID).Delete(&model.ForwardHop{})
+		dbpkg.DB.Delete(&model.Forward{}, f.ID)
+		jlog(map[string]interface{}{"event": "forward_deploy_failed", "forwardId": f.ID, "requestId": opId, "error": msg})
+		c.JSON(http.StatusOK, response.ErrMsg("端口转发创建失败，已回滚: "+msg))
+		return
 	}
 	c.JSON(http.StatusOK, response.Ok(map[string]any{"requestId": opId}))
 }
@@ -450,11 +168,29 @@ func ForwardUpdate(c *gin.Context) {
 		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
 		return
 	}
+	remote, msg := normalizeTargets(req.RemoteAddr, req.Targets)
+	if msg != "" {
+		c.JSON(http.StatusOK, response.ErrMsg(msg))
+		return
+	}
+	strategy, ok := normalizeStrategy(req.Strategy)
+	if !ok {
+		c.JSON(http.StatusOK, response.ErrMsg("负载策略无效"))
+		return
+	}
+	proto, ok := normalizeForwardProtocol(req.Protocol)
+	if !ok {
+		c.JSON(http.StatusOK, response.ErrMsg("转发协议无效"))
+		return
+	}
+	protoGiven := req.Protocol != nil
+	req.RemoteAddr, req.Strategy, req.Protocol = remote, strategy, proto
 	var f model.Forward
 	if err := dbpkg.DB.First(&f, req.ID).Error; err != nil {
 		c.JSON(http.StatusOK, response.ErrMsg("转发不存在"))
 		return
 	}
+	prev := f
 	// ensure tunnel exists
 	var tun model.Tunnel
 	if err := dbpkg.DB.First(&tun, req.TunnelID).Error; err != nil {
@@ -493,297 +229,94 @@ func ForwardUpdate(c *gin.Context) {
 		f.RemoteAddr = req.RemoteAddr
 	}
 	f.InterfaceName, f.Strategy = req.InterfaceName, req.Strategy
+	if protoGiven {
+		f.Protocol = req.Protocol
+	}
 	f.UpdatedTime = time.Now().UnixMilli()
 	if err := dbpkg.DB.Save(&f).Error; err != nil {
 		c.JSON(http.StatusOK, response.ErrMsg("端口转发更新失败"))
 		return
 	}
-	// push update
+	// push update; on a failed hop the row and the nodes go back to prev
 	opId := RandUUID()
+	rec := newAckRecorder()
+	msg = deployForward(prev, f, tun, opId, rec)
+	if msg == "" {
+		msg = rec.failure()
+	}
+	if msg != "" && rec.interrupted() {
+		jlog(map[string]interface{}{"event": "forward_deploy_interrupted", "forwardId": f.ID, "requestId": opId, "error": msg})
+		c.JSON(http.StatusOK, response.ErrMsg("服务正在关闭，转发已保存，节点重连后按期望状态核对: "+msg))
+		return
+	}
+	if msg != "" {
+		_ = dbpkg.DB.Save(&prev).Error
+		_ = deployForward(f, prev, tun, opId, nil)
+		jlog(map[string]interface{}{"event": "forward_deploy_failed", "forwardId": f.ID, "requestId": opId, "error": msg})
+		c.JSON(http.StatusOK, response.ErrMsg("端口转发更新失败，已回滚: "+msg))
+		return
+	}
+	c.JSON(http.StatusOK, response.Ok(map[string]any{"msg": "端口转发更新成功", "requestId": opId}))
+}
+
+// deployForward renders a forward's services for its tunnel and pushes them to the
+// entry, mid and exit nodes. prev is the forward as last deployed; services it
+// had that the current settings no longer use are removed. Commands go through
+// rec (nil = plain send); a batch recorder defers the gost restarts. It returns
+// an error message, "" on success.
+func deployForward(prev, f model.Forward, tun model.Tunnel, opId string, rec *pushRecorder) string {
 	name := buildServiceName(f.ID, f.UserID, f.TunnelID)
-	if tun.Type == 2 {
+	if tun.Type == 2 && f.OutPort == nil {
 		// ensure outPort exists as TLS tunnel port
-		if f.OutPort == nil {
-			if op := firstFreePortOut(tun, f.ID); op != 0 {
-				f.OutPort = &op
-				dbpkg.DB.Model(&model.Forward{}).Where("id=?", f.ID).Update("out_port", op)
-			} else {
-				c.JSON(http.StatusOK, response.ErrMsg("隧道出口端口已满，无法分配新端口"))
-				return
-			}
-		}
-		// update out-node gRPC relay service
-		// apply bind IP (in IP) for exit if configured
-		bindMap := getTunnelBindMap(tun.ID)
-		addrStr := fmt.Sprintf(":%d", *f.OutPort)
-		if ip, ok := bindMap[outNodeIDOr0(tun)]; ok && ip != "" {
-			addrStr = safeHostPort(ip, *f.OutPort)
-		}
-        outSvc := map[string]any{
-            "name":     name,
-            "addr":     addrStr,
-            "listener": map[string]any{"type": "grpc"},
-            "handler":  map[string]any{"type": "relay", "auth": map[string]any{"username": fmt.Sprintf("u-%d", f.ID), "password": util.MD5(fmt.Sprintf("%d:%d", f.ID, f.CreatedTime))[:16]}},
-            "metadata": map[string]any{"managedBy": "network-panel", "enableStats": true, "observer.period": "5s", "observer.resetTraffic": false},
-        }
-		_ = sendWSCommand(outNodeIDOr0(tun), "AddService", []map[string]any{outSvc})
-		if b, err := json.Marshal(outSvc); err == nil {
-			s := string(b)
-			_ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: outNodeIDOr0(tun), Cmd: "ForwardAddService", RequestID: opId, Success: 1, Message: "update out svc", Stdout: &s}).Error
-		}
-
-		// multi-hop mids (update or create)
-		path := getTunnelPathNodes(tun.ID)
-		ifaceMap := getTunnelIfaceMap(tun.ID)
-		var entryTarget string
-		if len(path) > 0 {
-			// allocate ports for mids per overlay rule
-			midPorts := make([]int, len(path))
-			for i := range path {
-				var prevID int64
-				if i == 0 {
-					prevID = tun.InNodeID
-				} else {
-					prevID = path[i-1]
-				}
-				prevOut := ifaceMap[prevID]
-				nextIn := bindMap[path[i]]
-				overlay := isOverlayIP(prevOut) && isOverlayIP(nextIn)
-				var n model.Node
-				_ = dbpkg.DB.First(&n, path[i]).Error
-				if overlay {
-					p := findFreePortOnNodeAny(path[i], 10000, 10000)
-					if p == 0 {
-						p = 10000
-					}
-					midPorts[i] = p
-				} else {
-					minP, maxP := 10000, 65535
-					if n.PortSta > 0 {
-						minP = n.PortSta
-					}
-					if n.PortEnd > 0 {
-						maxP = n.PortEnd
-					}
-					p := findFreePortOnNode(path[i], minP, minP, maxP)
-					if p == 0 {
-						p = minP
-					}
-					midPorts[i] = p
-				}
-				_ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: path[i], Cmd: "ForwardPortPick", RequestID: opId, Success: 1, Message: fmt.Sprintf("mid port=%d (%s)", midPorts[i], ifThen(overlay, "overlay", "range"))}).Error
-			}
-			// update services on mids
-			for i := 0; i < len(path); i++ {
-				nid := path[i]
-				thisPort := midPorts[i]
-				var target string
-				if i < len(path)-1 {
-					var nx model.Node
-					if err := dbpkg.DB.First(&nx, path[i+1]).Error; err != nil {
-						continue
-					}
-                host := bindMap[path[i+1]]
-                if host == "" { host = preferIPv4(nx) }
-                if host == "" { host = firstIPAny(nx) }
-                target = safeHostPort(host, midPorts[i+1])
-                } else {
-                    // last mid forwards directly to exit relay (gRPC) on out node
-                    // Use exit bind IP if provided; otherwise fall back to tunnel/Node ServerIP
-                    exHost := ""
-                    if ip, ok := bindMap[outNodeIDOr0(tun)]; ok && ip != "" {
-                        exHost = ip
-                    } else {
-                        exHost = getOutNodeIP(tun)
-                    }
-                    target = safeHostPort(exHost, *f.OutPort)
-                }
-				midName := fmt.Sprintf("%s_mid_%d", buildServiceName(f.ID, f.UserID, f.TunnelID), i)
-				var iface *string
-				if ip, ok := ifaceMap[nid]; ok && ip != "" {
-					tmp := ip
-					iface = &tmp
-				}
-				// listener addr with bind if provided
-				listen := fmt.Sprintf(":%d", thisPort)
-				if bindIP, ok := bindMap[nid]; ok && bindIP != "" {
-					listen = safeHostPort(bindIP, thisPort)
-				}
-                svc := map[string]any{
-                    "name":      midName,
-                    "addr":      listen,
-                    "listener":  map[string]any{"type": "tcp"},
-                    "handler":   map[string]any{"type": "forward"},
-                    "forwarder": map[string]any{"nodes": []map[string]any{{"name": "target", "addr": target}}},
-                    "metadata":  map[string]any{"managedBy": "network-panel", "enableStats": true, "observer.period": "5s", "observer.resetTraffic": false},
-                }
-				if iface != nil && *iface != "" {
-					svc["metadata"].(map[string]any)["interface"] = *iface
-				}
-				_ = sendWSCommand(nid, "AddService", []map[string]any{svc})
-				if b, err := json.Marshal(svc); err == nil {
-					s := string(b)
-					_ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: nid, Cmd: "ForwardAddService", RequestID: opId, Success: 1, Message: fmt.Sprintf("update mid svc %s port=%d", midName, thisPort), Stdout: &s}).Error
-				}
-			}
-			// compute entry target to first mid
-			host0 := bindMap[path[0]]
-			if host0 == "" {
-				var firstN model.Node
-				_ = dbpkg.DB.First(&firstN, path[0]).Error
-				host0 = preferIPv4(firstN)
-			}
-			entryTarget = safeHostPort(host0, midPorts[0])
-		}
-		if b, err := json.Marshal(outSvc); err == nil {
+		if op := firstFreePortOut(tun, f.ID); op != 0 {
+			f.OutPort = &op
+			dbpkg.DB.Model(&model.Forward{}).Where("id=?", f.ID).Update("out_port", op)
+		} else {
+			return "隧道出口端口已满，无法分配新端口"
+		}
+	}
+	// listen ports on the relay path are picked per deployment and kept for the desired state
+	path := getTunnelPathNodes(tun.ID)
+	ports := pickPathPorts(tun, path, opId)
+	saveForwardHops(f.ID, path, ports)
+	var restart []int64
+	seen := map[int64]bool{}
+	for _, ns := range renderForward(f, tun, path, ports) {
+		_ = rec.send(ns.NodeID, "AddService", ns.Services)
+		if b, err := json.Marshal(ns.Services); err == nil {
 			s := string(b)
-			_ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: outNodeIDOr0(tun), Cmd: "ForwardAddService", RequestID: opId, Success: 1, Message: "update out svc", Stdout: &s}).Error
+			_ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: ns.NodeID, Cmd: "ForwardAddService", RequestID: opId, Success: 1, Message: "update " + ns.Role + " svc", Stdout: &s}).Error
 		}
-
-		// update in-node entry service with chain(dialer=grpc, connector=relay) and forwarder target (remote)
-		// 出口地址优先使用出口节点的监听IP(隧道编辑中的 inIp/bind)，否则回退到隧道/节点出口IP
-		outIP := getOutNodeIP(tun)
-		if ipBind, ok := bindMap[outNodeIDOr0(tun)]; ok && ipBind != "" {
-			outIP = ipBind
-		}
-		exitAddr := safeHostPort(outIP, *f.OutPort)
-		// 入口 forwarder 统一指向远程地址（取第一项），附带入口节点 interface（若配置）
-		ifaceMap = getTunnelIfaceMap(tun.ID)
-		var inIface *string
-		if ip, ok := ifaceMap[tun.InNodeID]; ok && ip != "" {
-			tmp := ip
-			inIface = &tmp
-		}
-        inSvc := buildServiceConfig(name, f.InPort, firstTargetHost(f.RemoteAddr), inIface)
-        if obsName, spec := buildObserverPluginSpec(tun.InNodeID, name); obsName != "" && spec != nil {
-            inSvc["observer"] = obsName
-            inSvc["_observers"] = []any{spec}
-        }
-		chainName := "chain_" + name
-		hopName := "hop_" + name
-		// ensure handler is forward and attach chain
-		if h, ok := inSvc["handler"].(map[string]any); ok {
-			h["type"] = "forward"
-			h["chain"] = chainName
-		} else {
-			inSvc["handler"] = map[string]any{"type": "forward", "chain": chainName}
+		if !seen[ns.NodeID] {
+			seen[ns.NodeID] = true
+			restart = append(restart, ns.NodeID)
 		}
-		user := fmt.Sprintf("u-%d", f.ID)
-		pass := util.MD5(fmt.Sprintf("%d:%d", f.ID, f.CreatedTime))[:16]
-		// entry chain target: if multi-hop, dial first mid; else dial exit relay
-		if len(path) == 0 {
-			entryTarget = exitAddr
+	}
+	// restart gost on every node involved so the changes take effect
+	for _, nid := range restart {
+		rec.restartGost(nid, opId)
+	}
+	// drop the TCP/UDP twin the new protocol no longer uses
+	if stale := staleServiceNames(prev, f, name); len(stale) > 0 {
+		nodes := []int64{tun.InNodeID}
+		if tun.Type != 2 {
+			nodes = append(nodes, getTunnelPathNodes(tun.ID)...)
 		}
-		node := map[string]any{"name": "node-" + name, "addr": entryTarget, "connector": map[string]any{"type": "relay", "auth": map[string]any{"username": user, "password": pass}}, "dialer": map[string]any{"type": "grpc"}}
-		inSvc["_chains"] = []any{map[string]any{"name": chainName, "hops": []any{map[string]any{"name": hopName, "nodes": []any{node}}}}}
-        _ = sendWSCommand(tun.InNodeID, "AddService", []map[string]any{inSvc})
-		if b, err := json.Marshal(inSvc); err == nil {
-			s := string(b)
-			_ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: tun.InNodeID, Cmd: "ForwardUpdateService", RequestID: opId, Success: 1, Message: "update entry svc", Stdout: &s}).Error
+		for _, nid := range nodes {
+			_ = rec.send(nid, "DeleteService", map[string]any{"services": stale})
 		}
-		// 强制重启入口与出口节点的 gost，确保更新立即生效
-		_ = sendWSCommand(tun.InNodeID, "RestartGost", map[string]any{"reason": "forward_update"})
-		_ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: tun.InNodeID, Cmd: "ForwardRestartGost", RequestID: opId, Success: 1, Message: "restart gost"}).Error
-		_ = sendWSCommand(outNodeIDOr0(tun), "RestartGost", map[string]any{"reason": "forward_update"})
-		_ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: outNodeIDOr0(tun), Cmd: "ForwardRestartGost", RequestID: opId, Success: 1, Message: "restart gost"}).Error
-    } else {
-        // port-forward (type=1): support multi-level path similar to create
-        path := getTunnelPathNodes(tun.ID)
-        ifaceMap := getTunnelIfaceMap(tun.ID)
-        bindMap := getTunnelBindMap(tun.ID)
-        if len(path) == 0 {
-            // single hop: entry forwards directly to remoteAddr
-            var iface *string
-            if ip, ok := ifaceMap[tun.InNodeID]; ok && ip != "" {
-                tmp := ip
-                iface = &tmp
-            } else {
-                iface = preferIface(f.InterfaceName, tun.InterfaceName)
-            }
-            svc := buildServiceConfig(name, f.InPort, f.RemoteAddr, iface)
-            if obsName, spec := buildObserverPluginSpec(tun.InNodeID, name); obsName != "" && spec != nil {
-                svc["observer"] = obsName
-                svc["_observers"] = []any{spec}
-            }
-            _ = sendWSCommand(tun.InNodeID, "AddService", []map[string]any{svc})
-            if b, err := json.Marshal(svc); err == nil {
-                s := string(b)
-                _ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: tun.InNodeID, Cmd: "ForwardUpdateService", RequestID: opId, Success: 1, Message: "update entry svc (type1-single)", Stdout: &s}).Error
-            }
-            _ = sendWSCommand(tun.InNodeID, "RestartGost", map[string]any{"reason": "forward_update"})
-            _ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: tun.InNodeID, Cmd: "ForwardRestartGost", RequestID: opId, Success: 1, Message: "restart gost"}).Error
-        } else {
-            // chain: [inNode -> mid1 -> ... -> last]; entry uses f.InPort
-            hops := append([]int64{tun.InNodeID}, path...)
-            hopPorts := make([]int, len(hops))
-            hopPorts[0] = f.InPort
-            for i := 1; i < len(hops); i++ {
-                prevID := hops[i-1]
-                curID := hops[i]
-                prevOut := ifaceMap[prevID]
-                curIn := bindMap[curID]
-                overlay := isOverlayIP(prevOut) && isOverlayIP(curIn)
-                var n model.Node
-                _ = dbpkg.DB.First(&n, curID).Error
-                if overlay {
-                    p := findFreePortOnNodeAny(curID, 10000, 10000)
-                    if p == 0 { p = 10000 }
-                    hopPorts[i] = p
-                } else {
-                    minP, maxP := 10000, 65535
-                    if n.PortSta > 0 { minP = n.PortSta }
-                    if n.PortEnd > 0 { maxP = n.PortEnd }
-                    p := findFreePortOnNode(curID, minP, minP, maxP)
-                    if p == 0 { p = minP }
-                    hopPorts[i] = p
-                }
-                _ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: curID, Cmd: "ForwardPortPick", RequestID: opId, Success: 1, Message: fmt.Sprintf("hop port=%d", hopPorts[i])}).Error
-            }
-            // deploy services per hop (upsert)
-            for i := 0; i < len(hops); i++ {
-                nodeID := hops[i]
-                listenPort := hopPorts[i]
-                var target string
-                if i < len(hops)-1 {
-                    var n model.Node
-                    if err := dbpkg.DB.First(&n, hops[i+1]).Error; err != nil { continue }
-                    host := bindMap[hops[i+1]]
-                    if host == "" { host = preferIPv4(n) }
-                    if host == "" { host = firstIPAny(n) }
-                    target = safeHostPort(host, hopPorts[i+1])
-                } else {
-                    target = firstTargetHost(f.RemoteAddr)
-                }
-                var iface *string
-                if ip, ok := ifaceMap[nodeID]; ok && ip != "" {
-                    tmp := ip
-                    iface = &tmp
-                } else {
-                    iface = preferIface(f.InterfaceName, tun.InterfaceName)
-                }
-                svc := buildServiceConfig(name, listenPort, target, iface)
-                _ = sendWSCommand(nodeID, "AddService", []map[string]any{svc})
-                if b, err := json.Marshal(svc); err == nil {
-                    s := string(b)
-                    _ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: nodeID, Cmd: "ForwardAddService", RequestID: opId, Success: 1, Message: fmt.Sprintf("update hop svc port=%d", listenPort), Stdout: &s}).Error
-                }
-            }
-            // restart gost on all hops to apply changes
-            nodesToRestart := make(map[int64]struct{})
-            for _, nid := range hops { nodesToRestart[nid] = struct{}{} }
-            for nid := range nodesToRestart {
-                if nid > 0 {
-                    _ = sendWSCommand(nid, "RestartGost", map[string]any{"reason": "forward_update"})
-                    _ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: nid, Cmd: "ForwardRestartGost", RequestID: opId, Success: 1, Message: "restart gost"}).Error
-                }
-            }
-        }
-    }
-	c.JSON(http.StatusOK, response.Ok(map[string]any{"msg": "端口转发更新成功", "requestId": opId}))
+	}
+	return ""
 }
 
 // POST /api/v1/forward/delete
-func ForwardDelete(c *gin.Context) {
+func ForwardDelete(c *gin.Context) { forwardDelete(c, false) }
+
+// POST /api/v1/forward/force-delete
+// Deletes the row even when a node cannot confirm removing the services.
+func ForwardForceDelete(c *gin.Context) { forwardDelete(c, true) }
+
+func forwardDelete(c *gin.Context, force bool) {
 	var p struct {
 		ID int64 `json:"id"`
 	}
@@ -797,37 +330,47 @@ func ForwardDelete(c *gin.Context) {
 	var tun model.Tunnel
 	_ = dbpkg.DB.First(&tun, f.TunnelID).Error
 	name := buildServiceName(f.ID, f.UserID, f.TunnelID)
+	rec := newAckRecorder()
 	if tun.Type == 2 && f.OutPort != nil {
-		// 删除入口与出口上的主服务
-		_ = sendWSCommand(tun.InNodeID, "DeleteService", map[string]any{"services": []string{name}})
-		_ = sendWSCommand(outNodeIDOr0(tun), "DeleteService", map[string]any{"services": []string{name}})
+		// 删除入口（含 UDP 服务）与出口上的主服务
+		_ = rec.send(tun.InNodeID, "DeleteService", map[string]any{"services": allNetworkServiceNames(name)})
+		_ = rec.send(outNodeIDOr0(tun), "DeleteService", map[string]any{"services": []string{name}})
 		// 删除多级路径的中间节点 mid 服务（name_mid_i）
 		path := getTunnelPathNodes(tun.ID)
 		for i := 0; i < len(path); i++ {
 			midName := fmt.Sprintf("%s_mid_%d", name, i)
-			_ = sendWSCommand(path[i], "DeleteService", map[string]any{"services": []string{midName}})
+			_ = rec.send(path[i], "DeleteService", map[string]any{"services": []string{midName}})
 		}
 	} else {
-		// 端口转发：删除入口上的服务
-		_ = sendWSCommand(tun.InNodeID, "DeleteService", map[string]any{"services": []string{name}})
+		// 端口转发：删除入口上的服务（含 UDP 服务）
+		_ = rec.send(tun.InNodeID, "DeleteService", map[string]any{"services": allNetworkServiceNames(name)})
 		// 若端口转发也采用了多级路径（各 hop 使用相同 name），尝试在中间节点删除同名服务
 		path := getTunnelPathNodes(tun.ID)
 		for _, nid := range path {
-			_ = sendWSCommand(nid, "DeleteService", map[string]any{"services": []string{name}})
+			_ = rec.send(nid, "DeleteService", map[string]any{"services": allNetworkServiceNames(name)})
 		}
 	}
+	if msg := rec.failure(); msg != "" && !force {
+		c.JSON(http.StatusOK, response.ErrMsg("端口转发删除失败: "+msg))
+		return
+	}
 	if err := dbpkg.DB.Delete(&model.Forward{}, p.ID).Error; err != nil {
 		c.JSON(http.StatusOK, response.ErrMsg("端口转发删除失败"))
 		return
 	}
+	dbpkg.DB.Where("forward_id = ?", p.ID).Delete(&model.ForwardHop{})
 	c.JSON(http.StatusOK, response.OkMsg("端口转发删除成功"))
 }
 
-// POST /api/v1/forward/force-delete
-func ForwardForceDelete(c *gin.Context) { ForwardDelete(c) }
-
 // POST /api/v1/forward/pause
-func ForwardPause(c *gin.Context) {
+func ForwardPause(c *gin.Context) { forwardSetPaused(c, true) }
+
+// POST /api/v1/forward/resume
+func ForwardResume(c *gin.Context) { forwardSetPaused(c, false) }
+
+// forwardSetPaused pauses or resumes a forward on its nodes and stores the status
+// once every node acknowledged; a partial change is undone.
+func forwardSetPaused(c *gin.Context, paused bool) {
 	var p struct {
 		ID int64 `json:"id"`
 	}
@@ -840,46 +383,32 @@ func ForwardPause(c *gin.Context) {
 		c.JSON(http.StatusOK, response.ErrMsg("转发不存在"))
 		return
 	}
-	// set status
-	dbpkg.DB.Model(&model.Forward{}).Where("id = ?", p.ID).Update("status", 0)
-	// send pause to node(s)
 	var t model.Tunnel
 	if err := dbpkg.DB.First(&t, f.TunnelID).Error; err == nil {
-		name := buildServiceName(f.ID, f.UserID, f.TunnelID)
-		_ = sendWSCommand(t.InNodeID, "PauseService", map[string]interface{}{"services": []string{name}})
-		if t.Type == 2 {
-			_ = sendWSCommand(outNodeIDOr0(t), "PauseService", map[string]interface{}{"services": []string{name}})
+		rec := newAckRecorder()
+		pushForwardPaused(rec, t, f, paused)
+		if msg := rec.failure(); msg != "" {
+			// on shutdown the outcome is unknown; the reconcile after restart applies the stored status
+			if !rec.interrupted() {
+				pushForwardPaused(nil, t, f, !paused)
+			}
+			c.JSON(http.StatusOK, response.ErrMsg(ifThen(paused, "暂停失败: ", "恢复失败: ")+msg))
+			return
 		}
 	}
+	dbpkg.DB.Model(&model.Forward{}).Where("id = ?", p.ID).Update("status", ifThen(paused, 0, 1))
 	c.JSON(http.StatusOK, response.OkNoData())
 }
 
-// POST /api/v1/forward/resume
-func ForwardResume(c *gin.Context) {
-	var p struct {
-		ID int64 `json:"id"`
-	}
-	if err := c.ShouldBindJSON(&p); err != nil {
-		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
-		return
-	}
-	var f model.Forward
-	if err := dbpkg.DB.First(&f, p.ID).Error; err != nil {
-		c.JSON(http.StatusOK, response.ErrMsg("转发不存在"))
-		return
-	}
-	// set status
-	dbpkg.DB.Model(&model.Forward{}).Where("id = ?", p.ID).Update("status", 1)
-	// send resume to node(s)
-	var t model.Tunnel
-	if err := dbpkg.DB.First(&t, f.TunnelID).Error; err == nil {
-		name := buildServiceName(f.ID, f.UserID, f.TunnelID)
-		_ = sendWSCommand(t.InNodeID, "ResumeService", map[string]interface{}{"services": []string{name}})
-		if t.Type == 2 {
-			_ = sendWSCommand(outNodeIDOr0(t), "ResumeService", map[string]interface{}{"services": []string{name}})
-		}
+// pushForwardPaused sends PauseService or ResumeService for a forward's services
+// on the tunnel entry and, for tunnel-forward, the exit.
+func pushForwardPaused(rec *pushRecorder, t model.Tunnel, f model.Forward, paused bool) {
+	cmd := ifThen(paused, "PauseService", "ResumeService")
+	name := buildServiceName(f.ID, f.UserID, f.TunnelID)
+	_ = rec.send(t.InNodeID, cmd, map[string]interface{}{"services": forwardServiceNames(f, name)})
+	if t.Type == 2 {
+		_ = rec.send(outNodeIDOr0(t), cmd, map[string]interface{}{"services": []string{name}})
 	}
-	c.JSON(http.StatusOK, response.OkNoData())
 }
 
 // POST /api/v1/forward/diagnose
@@ -1349,16 +878,10 @@ func ForwardDiagnoseStep(c *gin.Context) {
         if success && bw > 0 {
             duration := 5.0 // seconds (client payload uses 5s)
             bytes := int64((bw * 1e6 / 8.0) * duration)
-            // add to out_flow as diagnostic consumption
-            dbpkg.DB.Model(&model.Forward{}).Where("id=?", f.ID).
-                Updates(map[string]any{"out_flow": gorm.Expr("out_flow + ?", bytes), "updated_time": time.Now().UnixMilli()})
-            dbpkg.DB.Model(&model.User{}).Where("id=?", f.UserID).
-                Updates(map[string]any{"out_flow": gorm.Expr("out_flow + ?", bytes), "updated_time": time.Now().UnixMilli()})
+            // diagnostic consumption, billed as download like regular traffic
             var ut model.UserTunnel
-            if err := dbpkg.DB.Where("user_id=? and tunnel_id=?", f.UserID, f.TunnelID).First(&ut).Error; err == nil && ut.ID > 0 {
-                dbpkg.DB.Model(&model.UserTunnel{}).Where("id=?", ut.ID).
-                    Updates(map[string]any{"out_flow": gorm.Expr("out_flow + ?", bytes)})
-            }
+            _ = dbpkg.DB.Where("user_id=? and tunnel_id=?", f.UserID, f.TunnelID).First(&ut).Error
+            chargeTransfer(f.ID, f.UserID, ut.ID, t, 0, bytes)
         }
         res = map[string]interface{}{
             "success": success, "description": "iperf3 反向带宽测试", "nodeName": inNode.Name, "nodeId": inNode.ID,
@@ -1380,15 +903,10 @@ func containsColon(s string) bool {
 	return false
 }
 func firstTargetHost(addr string) string {
-	// remoteAddr may be comma-separated list; return first host part
-	for i := 0; i < len(addr); i++ {
-		if addr[i] == ',' {
-			addr = addr[:i]
-			break
-		}
+	// remoteAddr may be a comma-separated list with weights; return the first host:port
+	if ts := parseTargets(addr); len(ts) > 0 {
+		return ts[0].Addr
 	}
-	// strip brackets for IPv6 if present; keep host
-	// host:port format assumed
 	return addr
 }
 
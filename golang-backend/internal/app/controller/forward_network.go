@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"net"
+	"strings"
+
+	"network-panel/golang-backend/internal/app/model"
+)
+
+// Forward protocols, stored in Forward.Protocol (nil = tcp).
+const (
+	forwardTCP  = "tcp"
+	forwardUDP  = "udp"
+	forwardBoth = "both"
+)
+
+// normalizeForwardProtocol validates a requested protocol; nil or empty means tcp.
+func normalizeForwardProtocol(p *string) (*string, bool) {
+	if p == nil || strings.TrimSpace(*p) == "" {
+		return nil, true
+	}
+	v := strings.ToLower(strings.TrimSpace(*p))
+	switch v {
+	case forwardTCP, forwardUDP, forwardBoth:
+		return &v, true
+	case "tcp+udp", "tcpudp", "all":
+		v = forwardBoth
+		return &v, true
+	}
+	return nil, false
+}
+
+// forwardNetworks lists the networks a forward listens on.
+func forwardNetworks(f model.Forward) []string {
+	p := ""
+	if f.Protocol != nil {
+		p = *f.Protocol
+	}
+	switch p {
+	case forwardUDP:
+		return []string{forwardUDP}
+	case forwardBoth:
+		return []string{forwardTCP, forwardUDP}
+	}
+	return []string{forwardTCP}
+}
+
+// udpServiceName is the name of the UDP twin of a service; it keeps the
+// forwardId_ prefix so flow reports resolve to the same forward.
+func udpServiceName(name string) string { return name + "_udp" }
+
+// forwardServiceNames returns the service names deployed for a forward under the
+// given base name.
+func forwardServiceNames(f model.Forward, name string) []string {
+	out := make([]string, 0, 2)
+	for _, n := range forwardNetworks(f) {
+		if n == forwardUDP {
+			out = append(out, udpServiceName(name))
+		} else {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// allNetworkServiceNames is used on delete so a twin left by an earlier protocol
+// is removed as well.
+func allNetworkServiceNames(name string) []string { return []string{name, udpServiceName(name)} }
+
+// staleServiceNames returns the names deployed for the old protocol that the
+// new protocol no longer uses.
+func staleServiceNames(old, cur model.Forward, name string) []string {
+	keep := map[string]bool{}
+	for _, n := range forwardServiceNames(cur, name) {
+		keep[n] = true
+	}
+	var out []string
+	for _, n := range forwardServiceNames(old, name) {
+		if !keep[n] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// expandNetworks turns a TCP forward service into the services for the forward's
+// networks. The UDP twin uses a udp listener kept alive between datagrams; the
+// handler, forwarder and chain stay the same (a relay chain carries UDP too).
+// udpListen optionally binds the entry UDP listener (Tunnel.UDPListenAddr).
+func expandNetworks(f model.Forward, svc map[string]any, udpListen *string) []map[string]any {
+	out := make([]map[string]any, 0, 2)
+	for _, n := range forwardNetworks(f) {
+		if n == forwardTCP {
+			out = append(out, svc)
+			continue
+		}
+		u := make(map[string]any, len(svc))
+		for k, v := range svc {
+			u[k] = v
+		}
+		name, _ := svc["name"].(string)
+		u["name"] = udpServiceName(name)
+		u["listener"] = map[string]any{"type": "udp", "metadata": map[string]any{"keepalive": true, "ttl": "60s"}}
+		if addr, ok := svc["addr"].(string); ok && udpListen != nil {
+			if host := listenHost(*udpListen); host != "" {
+				if _, port, err := net.SplitHostPort(addr); err == nil {
+					u["addr"] = net.JoinHostPort(host, port)
+				}
+			}
+		}
+		out = append(out, u)
+	}
+	return out
+}
+
+// listenHost extracts the host of a tunnel listen address ("[::]", "0.0.0.0",
+// "1.2.3.4:0"); "" when unset or not an IP.
+func listenHost(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		addr = h
+	}
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	if net.ParseIP(addr) == nil {
+		return ""
+	}
+	return addr
+}
@@ -0,0 +1,346 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"network-panel/golang-backend/internal/app/model"
+	"network-panel/golang-backend/internal/app/util"
+	dbpkg "network-panel/golang-backend/internal/db"
+)
+
+// nodeServices are the services a forward runs on one node in one role.
+type nodeServices struct {
+	NodeID   int64
+	Role     string // entry, mid, exit
+	Services []map[string]any
+}
+
+// forwardPaused reports whether a forward's services run paused: the forward is
+// paused or its user's permission on the tunnel is not active.
+func forwardPaused(f model.Forward) bool {
+	if f.Status != nil && *f.Status == 0 {
+		return true
+	}
+	var ut model.UserTunnel
+	return dbpkg.DB.Where("user_id = ? AND tunnel_id = ?", f.UserID, f.TunnelID).First(&ut).Error == nil && ut.Status != 1
+}
+
+// markPaused sets metadata.paused the way PauseService does.
+func markPaused(services []map[string]any) {
+	for _, svc := range services {
+		meta, _ := svc["metadata"].(map[string]any)
+		if meta == nil {
+			meta = map[string]any{}
+			svc["metadata"] = meta
+		}
+		meta["paused"] = true
+	}
+}
+
+// pickPathPorts picks a free listen port on each relay path node. Adjacent hops
+// that both use 10.126.126.* overlay addresses may use any port from 10000,
+// otherwise the node's port range applies.
+func pickPathPorts(tun model.Tunnel, path []int64, opId string) []int {
+	ifaceMap := getTunnelIfaceMap(tun.ID)
+	bindMap := getTunnelBindMap(tun.ID)
+	ports := make([]int, len(path))
+	for i, nid := range path {
+		prevID := tun.InNodeID
+		if i > 0 {
+			prevID = path[i-1]
+		}
+		overlay := isOverlayIP(ifaceMap[prevID]) && isOverlayIP(bindMap[nid])
+		var n model.Node
+		_ = dbpkg.DB.First(&n, nid).Error
+		if overlay {
+			ports[i] = findFreePortOnNodeAny(nid, 10000, 10000)
+			if ports[i] == 0 {
+				ports[i] = 10000
+			}
+		} else {
+			minP, maxP := 10000, 65535
+			if n.PortSta > 0 {
+				minP = n.PortSta
+			}
+			if n.PortEnd > 0 {
+				maxP = n.PortEnd
+			}
+			ports[i] = findFreePortOnNode(nid, minP, minP, maxP)
+			if ports[i] == 0 {
+				ports[i] = minP
+			}
+		}
+		_ = dbpkg.DB.Create(&model.NodeOpLog{TimeMs: time.Now().UnixMilli(), NodeID: nid, Cmd: "ForwardPortPick", RequestID: opId, Success: 1, Message: fmt.Sprintf("hop port=%d (%s)", ports[i], ifThen(overlay, "overlay", "range"))}).Error
+	}
+	return ports
+}
+
+// saveForwardHops replaces the forward's stored path ports.
+func saveForwardHops(forwardID int64, path []int64, ports []int) {
+	dbpkg.DB.Where("forward_id = ?", forwardID).Delete(&model.ForwardHop{})
+	for i, nid := range path {
+		_ = dbpkg.DB.Create(&model.ForwardHop{ForwardID: forwardID, Idx: i, NodeID: nid, Port: ports[i]}).Error
+	}
+}
+
+// loadForwardHops returns the stored path ports; false when the forward was
+// not deployed over this path.
+func loadForwardHops(forwardID int64, path []int64) ([]int, bool) {
+	var hops []model.ForwardHop
+	dbpkg.DB.Where("forward_id = ?", forwardID).Order("idx").Find(&hops)
+	if len(hops) != len(path) {
+		return nil, false
+	}
+	ports := make([]int, len(path))
+	for i, h := range hops {
+		if h.Idx != i || h.NodeID != path[i] {
+			return nil, false
+		}
+		ports[i] = h.Port
+	}
+	return ports, true
+}
+
+var hopBackfillMu sync.Mutex
+
+// liveServicePorts maps node id to the listen port of each service the node runs,
+// queried once per node (nil when the node did not answer).
+type liveServicePorts map[int64]map[string]int
+
+func (l liveServicePorts) port(nodeID int64, names ...string) int {
+	m, ok := l[nodeID]
+	if !ok {
+		for _, svc := range queryNodeServicesRaw(nodeID) {
+			n, _ := svc["name"].(string)
+			addr, _ := svc["addr"].(string)
+			if p := parsePort(addr); n != "" && p > 0 {
+				if m == nil {
+					m = map[string]int{}
+				}
+				m[n] = p
+			}
+		}
+		l[nodeID] = m
+	}
+	for _, n := range names {
+		if p := m[n]; p > 0 {
+			return p
+		}
+	}
+	return 0
+}
+
+// BackfillForwardHops stores the path ports of forwards deployed before they
+// were saved. It asks the path nodes for their live services, so it runs out of
+// band (scheduler, node connect) and waits while a path node is offline.
+func BackfillForwardHops() {
+	var tunnels []model.Tunnel
+	dbpkg.DB.Order("id").Find(&tunnels)
+	live := liveServicePorts{}
+	for _, tun := range tunnels {
+		path := getTunnelPathNodes(tun.ID)
+		if len(path) == 0 {
+			continue
+		}
+		online := true
+		nodeConnMu.RLock()
+		for _, nid := range path {
+			online = online && len(nodeConns[nid]) > 0
+		}
+		nodeConnMu.RUnlock()
+		if !online {
+			continue
+		}
+		var forwards []model.Forward
+		dbpkg.DB.Where("tunnel_id = ?", tun.ID).Order("id").Find(&forwards)
+		for _, f := range forwards {
+			if _, ok := loadForwardHops(f.ID, path); !ok {
+				backfillForwardHops(f, tun, path, live)
+			}
+		}
+	}
+}
+
+// backfillForwardHops stores the path ports of a forward deployed before they
+// were saved: each hop keeps the port its service listens on now, hops the node
+// cannot tell get a fresh port (the drift fix then pushes them everywhere).
+func backfillForwardHops(f model.Forward, tun model.Tunnel, path []int64, live liveServicePorts) []int {
+	hopBackfillMu.Lock()
+	defer hopBackfillMu.Unlock()
+	if ports, ok := loadForwardHops(f.ID, path); ok {
+		return ports
+	}
+	name := buildServiceName(f.ID, f.UserID, f.TunnelID)
+	ports := make([]int, len(path))
+	missing := false
+	for i, nid := range path {
+		names := allNetworkServiceNames(name)
+		if tun.Type == 2 {
+			names = []string{fmt.Sprintf("%s_mid_%d", name, i)}
+		}
+		ports[i] = live.port(nid, names...)
+		missing = missing || ports[i] == 0
+	}
+	if missing {
+		picked := pickPathPorts(tun, path, "hop_backfill")
+		for i := range ports {
+			if ports[i] == 0 {
+				ports[i] = picked[i]
+			}
+		}
+	}
+	saveForwardHops(f.ID, path, ports)
+	jlog(map[string]interface{}{"event": "forward_hops_backfilled", "forwardId": f.ID, "path": path, "ports": ports, "repicked": missing})
+	return ports
+}
+
+// renderForward builds the services of a forward on every node it uses, given
+// the tunnel path and the listen port on each path node. Tunnel-forward: relay
+// on the exit, plain forwards on the mids (<service>_mid_<i>), forward + relay
+// chain on the entry. Port-forward: the entry forwards to the first path node,
+// each path node to the next, the last one to the targets; all use the service
+// name. Paused forwards render paused on the nodes PauseService reaches.
+func renderForward(f model.Forward, tun model.Tunnel, path []int64, ports []int) []nodeServices {
+	name := buildServiceName(f.ID, f.UserID, f.TunnelID)
+	bindMap := getTunnelBindMap(tun.ID)
+	ifaceMap := getTunnelIfaceMap(tun.ID)
+	paused := forwardPaused(f)
+	// next hop address: the path node's listen (bind) IP, else its node address
+	hopAddr := func(nid int64, port int) (string, bool) {
+		host := bindMap[nid]
+		if host == "" {
+			var n model.Node
+			if err := dbpkg.DB.First(&n, nid).Error; err != nil {
+				return "", false
+			}
+			host = preferIPv4(n)
+			if host == "" {
+				host = firstIPAny(n)
+			}
+		}
+		return safeHostPort(host, port), true
+	}
+	var out []nodeServices
+
+	if tun.Type == 2 {
+		if f.OutPort == nil {
+			return nil
+		}
+		exitID := outNodeIDOr0(tun)
+		addrStr := fmt.Sprintf(":%d", *f.OutPort)
+		exHost := getOutNodeIP(tun)
+		if ip := bindMap[exitID]; ip != "" {
+			addrStr = safeHostPort(ip, *f.OutPort)
+			exHost = ip
+		}
+		exitAddr := safeHostPort(exHost, *f.OutPort)
+		user := fmt.Sprintf("u-%d", f.ID)
+		pass := util.MD5(fmt.Sprintf("%d:%d", f.ID, f.CreatedTime))[:16]
+		outSvc := map[string]any{
+			"name":     name,
+			"addr":     addrStr,
+			"listener": transportOf(tun).listener(),
+			"handler":  map[string]any{"type": "relay", "auth": map[string]any{"username": user, "password": pass}},
+			"metadata": map[string]any{"managedBy": "network-panel", "enableStats": true, "observer.period": "5s", "observer.resetTraffic": false},
+		}
+		exit := nodeServices{NodeID: exitID, Role: "exit", Services: []map[string]any{outSvc}}
+		if paused {
+			markPaused(exit.Services)
+		}
+		out = append(out, exit)
+
+		for i, nid := range path {
+			target := exitAddr
+			if i < len(path)-1 {
+				next, ok := hopAddr(path[i+1], ports[i+1])
+				if !ok {
+					continue
+				}
+				target = next
+			}
+			listen := fmt.Sprintf(":%d", ports[i])
+			if ip := bindMap[nid]; ip != "" {
+				listen = safeHostPort(ip, ports[i])
+			}
+			svc := map[string]any{
+				"name":      fmt.Sprintf("%s_mid_%d", name, i),
+				"addr":      listen,
+				"listener":  transportOf(tun).midListener(),
+				"handler":   map[string]any{"type": "forward"},
+				"forwarder": map[string]any{"nodes": []map[string]any{{"name": "target", "addr": target}}},
+				"metadata":  map[string]any{"managedBy": "network-panel", "enableStats": true, "observer.period": "5s", "observer.resetTraffic": false},
+			}
+			if ip := ifaceMap[nid]; ip != "" {
+				svc["metadata"].(map[string]any)["interface"] = ip
+			}
+			out = append(out, nodeServices{NodeID: nid, Role: "mid", Services: []map[string]any{svc}})
+		}
+
+		// entry: forward to the targets through a relay chain dialing the first mid, or the exit
+		entryTarget := exitAddr
+		if len(path) > 0 {
+			host0 := bindMap[path[0]]
+			if host0 == "" {
+				var first model.Node
+				_ = dbpkg.DB.First(&first, path[0]).Error
+				host0 = preferIPv4(first)
+			}
+			entryTarget = safeHostPort(host0, ports[0])
+		}
+		var inIface *string
+		if ip := ifaceMap[tun.InNodeID]; ip != "" {
+			inIface = &ip
+		}
+		inSvc := buildTargetServiceConfig(name, f.InPort, f, inIface)
+		if obsName, spec := buildObserverPluginSpec(tun.InNodeID, name); obsName != "" && spec != nil {
+			inSvc["observer"] = obsName
+			inSvc["_observers"] = []any{spec}
+		}
+		chainName := "chain_" + name
+		inSvc["handler"] = map[string]any{"type": "forward", "chain": chainName}
+		node := map[string]any{"name": "node-" + name, "addr": entryTarget, "connector": map[string]any{"type": "relay", "auth": map[string]any{"username": user, "password": pass}}, "dialer": transportOf(tun).dialer()}
+		inSvc["_chains"] = []any{map[string]any{"name": chainName, "hops": []any{map[string]any{"name": "hop_" + name, "nodes": []any{node}}}}}
+		entry := nodeServices{NodeID: tun.InNodeID, Role: "entry", Services: expandNetworks(f, inSvc, tun.UDPListenAddr)}
+		if paused {
+			markPaused(entry.Services)
+		}
+		return append(out, entry)
+	}
+
+	// port-forward: [entry -> path...], entry listens on the forward's port
+	hops := append([]int64{tun.InNodeID}, path...)
+	hopPorts := append([]int{f.InPort}, ports...)
+	for i, nid := range hops {
+		var iface *string
+		if ip := ifaceMap[nid]; ip != "" {
+			iface = &ip
+		} else {
+			iface = preferIface(f.InterfaceName, tun.InterfaceName)
+		}
+		var svc map[string]any
+		if i < len(hops)-1 {
+			next, ok := hopAddr(hops[i+1], hopPorts[i+1])
+			if !ok {
+				continue
+			}
+			svc = buildServiceConfig(name, hopPorts[i], next, iface)
+		} else {
+			svc = buildTargetServiceConfig(name, hopPorts[i], f, iface)
+		}
+		// flow is reported once, by the entry
+		if i == 0 {
+			if obsName, spec := buildObserverPluginSpec(nid, name); obsName != "" && spec != nil {
+				svc["observer"] = obsName
+				svc["_observers"] = []any{spec}
+			}
+		}
+		ns := nodeServices{NodeID: nid, Role: ifThen(i == 0, "entry", "mid"), Services: expandNetworks(f, svc, ifThen(i == 0, tun.UDPListenAddr, nil))}
+		if i == 0 && paused {
+			markPaused(ns.Services)
+		}
+		out = append(out, ns)
+	}
+	return out
+}
@@ -1,8 +1,13 @@
 package controller
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,173 +18,344 @@ import (
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-    "gorm.io/gorm/clause"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
 )
 
-// POST /api/v1/migrate {host, port, user, password, db}
-func MigrateFrom(c *gin.Context) {
-	var p struct {
-		Host     string `json:"host" binding:"required"`
-		Port     string `json:"port" binding:"required"`
-		User     string `json:"user" binding:"required"`
-		Password string `json:"password"`
-		DBName   string `json:"db" binding:"required"`
+// migSource describes the migration source and options shared by all migrate endpoints.
+// type=mysql (default): {host, port, user, password, db}
+// type=sqlite: {path}
+type migSource struct {
+	Type     string `json:"type"`
+	Host     string `json:"host"`
+	Port     string `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	DBName   string `json:"db"`
+	Path     string `json:"path"`
+	// options
+	DryRun     bool   `json:"dryRun"`
+	OnConflict string `json:"onConflict"` // skip (default), overwrite, fail
+	BatchSize  int    `json:"batchSize"`
+}
+
+type migOptions struct {
+	DryRun     bool
+	OnConflict string
+	BatchSize  int
+}
+
+const (
+	migConflictSkip      = "skip"
+	migConflictOverwrite = "overwrite"
+	migConflictFail      = "fail"
+)
+
+func (p migSource) options() (migOptions, error) {
+	o := migOptions{DryRun: p.DryRun, OnConflict: strings.ToLower(strings.TrimSpace(p.OnConflict)), BatchSize: p.BatchSize}
+	if o.OnConflict == "" {
+		o.OnConflict = migConflictSkip
+	}
+	if o.OnConflict != migConflictSkip && o.OnConflict != migConflictOverwrite && o.OnConflict != migConflictFail {
+		return o, fmt.Errorf("onConflict 仅支持 skip/overwrite/fail")
+	}
+	if o.BatchSize <= 0 || o.BatchSize > 5000 {
+		o.BatchSize = 500
+	}
+	return o, nil
+}
+
+// open connects to the source database; the returned message is user facing.
+func (p migSource) open() (*gorm.DB, string) {
+	cfg := &gorm.Config{Logger: logger.Default.LogMode(logger.Warn)}
+	switch strings.ToLower(strings.TrimSpace(p.Type)) {
+	case "sqlite":
+		path := strings.TrimSpace(p.Path)
+		if path == "" {
+			return nil, "参数错误"
+		}
+		if st, err := os.Stat(path); err != nil || st.IsDir() {
+			return nil, "源数据库文件不存在"
+		}
+		if os.Getenv("DB_DIALECT") == "sqlite" {
+			cur := os.Getenv("DB_SQLITE_PATH")
+			if cur == "" {
+				cur = "./flux.db"
+			}
+			a, _ := filepath.Abs(path)
+			b, _ := filepath.Abs(cur)
+			if a == b {
+				return nil, "源数据库与当前数据库相同"
+			}
+		}
+		src, err := dbpkg.OpenSQLite(path, cfg)
+		if err != nil {
+			return nil, "连接源数据库失败"
+		}
+		return src, ""
+	case "", "mysql":
+		if p.Host == "" || p.Port == "" || p.User == "" || p.DBName == "" {
+			return nil, "参数错误"
+		}
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local&timeout=10s", p.User, p.Password, p.Host, p.Port, p.DBName)
+		src, err := gorm.Open(mysql.Open(dsn), cfg)
+		if err != nil {
+			return nil, "连接源数据库失败"
+		}
+		return src, ""
+	default:
+		return nil, "不支持的源数据库类型"
 	}
+}
+
+func closeGorm(d *gorm.DB) {
+	if d == nil {
+		return
+	}
+	if sqlDB, err := d.DB(); err == nil && sqlDB != nil {
+		_ = sqlDB.Close()
+	}
+}
+
+// POST /api/v1/migrate {type?, host, port, user, password, db | path, dryRun?, onConflict?, batchSize?}
+func MigrateFrom(c *gin.Context) {
+	var p migSource
 	if err := c.ShouldBindJSON(&p); err != nil {
 		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
 		return
 	}
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local&timeout=10s", p.User, p.Password, p.Host, p.Port, p.DBName)
-	src, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	opt, err := p.options()
 	if err != nil {
-		c.JSON(http.StatusOK, response.ErrMsg("连接源数据库失败"))
+		c.JSON(http.StatusOK, response.ErrMsg(err.Error()))
 		return
 	}
+	src, msg := p.open()
+	if src == nil {
+		c.JSON(http.StatusOK, response.ErrMsg(msg))
+		return
+	}
+	defer closeGorm(src)
 
 	// migrate each table
-	stats, err := copyAll(src, dbpkg.DB)
+	stats, err := copyAll(src, dbpkg.DB, opt)
 	if err != nil {
 		c.JSON(http.StatusOK, response.ErrMsg("迁移失败: "+err.Error()))
 		return
 	}
-	c.JSON(http.StatusOK, response.Ok(map[string]any{"tables": stats}))
+	c.JSON(http.StatusOK, response.Ok(map[string]any{"tables": stats, "dryRun": opt.DryRun}))
 }
 
 type tableStat struct {
 	Table    string `json:"table"`
 	SrcCount int64  `json:"srcCount"`
-	Inserted int64  `json:"inserted"`
+	// diff against destination (computed in both dry-run and real runs)
+	New       int64 `json:"new"`
+	Identical int64 `json:"identical"`
+	Changed   int64 `json:"changed"`
+	// sample of primary keys that exist in destination with different content
+	ChangedKeys []any `json:"changedKeys,omitempty"`
+	// applied result (always 0 on dry-run)
+	Inserted int64 `json:"inserted"`
+	Updated  int64 `json:"updated"`
+	Skipped  int64 `json:"skipped"`
 }
 
-func copyAll(src *gorm.DB, dst *gorm.DB) ([]tableStat, error) {
-	out := make([]tableStat, 0, 8)
-	// order matters due to relations
-	if st, err := copyTable[model.User](src, dst, "user"); err != nil {
-		return out, err
-	} else {
-		out = append(out, st)
-	}
-	if st, err := copyTable[model.Node](src, dst, "node"); err != nil {
-		return out, err
-	} else {
-		out = append(out, st)
-	}
-	if st, err := copyTable[model.Tunnel](src, dst, "tunnel"); err != nil {
-		return out, err
-	} else {
-		out = append(out, st)
-	}
-	if st, err := copyTable[model.Forward](src, dst, "forward"); err != nil {
-		return out, err
-	} else {
-		out = append(out, st)
-	}
-	if st, err := copyTable[model.UserTunnel](src, dst, "user_tunnel"); err != nil {
-		return out, err
-	} else {
-		out = append(out, st)
-	}
-	if st, err := copyTable[model.SpeedLimit](src, dst, "speed_limit"); err != nil {
-		return out, err
-	} else {
-		out = append(out, st)
-	}
-	if st, err := copyTable[model.ViteConfig](src, dst, "vite_config"); err != nil {
-		return out, err
-	} else {
-		out = append(out, st)
-	}
-	if st, err := copyTable[model.StatisticsFlow](src, dst, "statistics_flow"); err != nil {
-		return out, err
-	} else {
-		out = append(out, st)
-	}
-	return out, nil
+// migTable is one step of the migration. Order matters due to relations.
+type migTable struct {
+	name  string
+	count func(src *gorm.DB) (int64, error)
+	copy  func(src, dst *gorm.DB, opt migOptions, progress func(rows int64)) (tableStat, error)
 }
 
-func copyTable[T any](src *gorm.DB, dst *gorm.DB, table string) (tableStat, error) {
-    st := tableStat{Table: table}
-    if err := src.Model(new(T)).Count(&st.SrcCount).Error; err != nil {
-        return st, err
-    }
-    if st.SrcCount == 0 {
-        return st, nil
-    }
-    var list []T
-    if err := src.Find(&list).Error; err != nil {
-        return st, err
-    }
-    if len(list) == 0 {
-        return st, nil
-    }
-    // Use upsert semantics to avoid duplicate primary key errors when destination已有数据
-    // For MySQL: INSERT ... ON DUPLICATE KEY UPDATE ...
-    // For SQLite: INSERT ... ON CONFLICT(id) DO UPDATE SET ...
-    if err := dst.Clauses(clause.OnConflict{UpdateAll: true}).Create(&list).Error; err != nil {
-        return st, err
-    }
-    st.Inserted = int64(len(list))
-    return st, nil
+func migStep[T any](name string) migTable {
+	return migTable{
+		name: name,
+		count: func(src *gorm.DB) (int64, error) {
+			var n int64
+			err := src.Model(new(T)).Count(&n).Error
+			return n, err
+		},
+		copy: func(src, dst *gorm.DB, opt migOptions, progress func(rows int64)) (tableStat, error) {
+			return copyTable[T](src, dst, name, opt, progress)
+		},
+	}
 }
 
-// POST /api/v1/migrate/test {host, port, user, password, db}
+var migTables = []migTable{
+	migStep[model.User]("user"),
+	migStep[model.Node]("node"),
+	migStep[model.Tunnel]("tunnel"),
+	migStep[model.Forward]("forward"),
+	migStep[model.ForwardHop]("forward_hop"),
+	migStep[model.UserTunnel]("user_tunnel"),
+	migStep[model.SpeedLimit]("speed_limit"),
+	migStep[model.ViteConfig]("vite_config"),
+	migStep[model.StatisticsFlow]("statistics_flow"),
+	migStep[model.ExitSetting]("exit_setting"),
+	migStep[model.ProbeTarget]("probe_target"),
+	migStep[model.NodeProbeResult]("node_probe_result"),
+	migStep[model.NodeDisconnectLog]("node_disconnect_log"),
+	migStep[model.Alert]("alert"),
+	migStep[model.AlertRule]("alert_rule"),
+	migStep[model.AlertRuleState]("alert_rule_state"),
+	migStep[model.NotifyChannel]("notify_channel"),
+	migStep[model.NotifyDelivery]("notify_delivery"),
+	migStep[model.NodeSysInfo]("node_sysinfo"),
+	migStep[model.NodeSysInfoRollup]("node_sysinfo_rollup"),
+	migStep[model.NodeProbeRollup]("node_probe_rollup"),
+	migStep[model.NodeNetIfSample]("node_netif_sample"),
+	migStep[model.NodeNetIfRollup]("node_netif_rollup"),
+	migStep[model.NodeRuntime]("node_runtime"),
+	migStep[model.NodeOpLog]("node_op_log"),
+	migStep[model.NodeServicePush]("node_service_push"),
+	migStep[model.NodeCommand]("node_command"),
+	migStep[model.NodeDrift]("node_drift"),
+}
+
+func copyAll(src *gorm.DB, dst *gorm.DB, opt migOptions) ([]tableStat, error) {
+	out := make([]tableStat, 0, len(migTables))
+	err := migApply(dst, opt, func(tx *gorm.DB) error {
+		for _, t := range migTables {
+			st, err := t.copy(src, tx, opt, nil)
+			if err != nil {
+				return fmt.Errorf("%s: %w", t.name, err)
+			}
+			out = append(out, st)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// migApply runs the copy of all tables in one destination transaction, so a
+// failure (such as a "fail" conflict) rolls back the tables copied before it
+// and leaves the destination untouched. A dry run only reads.
+func migApply(dst *gorm.DB, opt migOptions, fn func(tx *gorm.DB) error) error {
+	if opt.DryRun {
+		return fn(dst)
+	}
+	return dst.Transaction(fn)
+}
+
+// copyTable streams rows from src in primary-key batches and merges them into dst
+// according to opt.OnConflict.
+func copyTable[T any](src *gorm.DB, dst *gorm.DB, table string, opt migOptions, progress func(rows int64)) (tableStat, error) {
+	st := tableStat{Table: table}
+	if err := src.Model(new(T)).Count(&st.SrcCount).Error; err != nil {
+		return st, err
+	}
+	if st.SrcCount == 0 {
+		return st, nil
+	}
+	sch, err := schema.Parse(new(T), &sync.Map{}, dst.NamingStrategy)
+	if err != nil {
+		return st, err
+	}
+	pk := sch.PrioritizedPrimaryField
+	if pk == nil {
+		return st, fmt.Errorf("no primary key")
+	}
+	var batch []T
+	var done int64
+	res := src.Model(new(T)).FindInBatches(&batch, opt.BatchSize, func(_ *gorm.DB, _ int) error {
+		if err := mergeBatch(dst, batch, pk, opt, &st); err != nil {
+			return err
+		}
+		done += int64(len(batch))
+		if progress != nil {
+			progress(done)
+		}
+		return nil
+	})
+	return st, res.Error
+}
+
+func mergeBatch[T any](dst *gorm.DB, batch []T, pk *schema.Field, opt migOptions, st *tableStat) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	keyOf := func(row *T) any {
+		v, _ := pk.ValueOf(ctx, reflect.ValueOf(row).Elem())
+		return v
+	}
+	keys := make([]any, 0, len(batch))
+	for i := range batch {
+		keys = append(keys, keyOf(&batch[i]))
+	}
+	var existing []T
+	if err := dst.Where(clause.IN{Column: clause.Column{Name: pk.DBName}, Values: keys}).Find(&existing).Error; err != nil {
+		return err
+	}
+	byKey := make(map[any]*T, len(existing))
+	for i := range existing {
+		byKey[keyOf(&existing[i])] = &existing[i]
+	}
+	fresh := make([]T, 0, len(batch))
+	changed := make([]T, 0)
+	for i := range batch {
+		k := keyOf(&batch[i])
+		cur, ok := byKey[k]
+		switch {
+		case !ok:
+			st.New++
+			fresh = append(fresh, batch[i])
+		case reflect.DeepEqual(*cur, batch[i]):
+			st.Identical++
+		default:
+			st.Changed++
+			changed = append(changed, batch[i])
+			if len(st.ChangedKeys) < 20 {
+				st.ChangedKeys = append(st.ChangedKeys, k)
+			}
+		}
+	}
+	if opt.DryRun {
+		return nil
+	}
+	if len(changed) > 0 && opt.OnConflict == migConflictFail {
+		return fmt.Errorf("目标库已存在不同的记录 %s=%v", pk.DBName, keyOf(&changed[0]))
+	}
+	if len(fresh) > 0 {
+		if err := dst.Create(&fresh).Error; err != nil {
+			return err
+		}
+		st.Inserted += int64(len(fresh))
+	}
+	st.Skipped += int64(len(batch) - len(fresh) - len(changed))
+	if len(changed) > 0 {
+		if opt.OnConflict == migConflictOverwrite {
+			if err := dst.Clauses(clause.OnConflict{UpdateAll: true}).Create(&changed).Error; err != nil {
+				return err
+			}
+			st.Updated += int64(len(changed))
+		} else {
+			st.Skipped += int64(len(changed))
+		}
+	}
+	return nil
+}
+
+// POST /api/v1/migrate/test {type?, host, port, user, password, db | path}
 // return basic connectivity and per-table counts
 func MigrateTest(c *gin.Context) {
-	var p struct {
-		Host     string `json:"host" binding:"required"`
-		Port     string `json:"port" binding:"required"`
-		User     string `json:"user" binding:"required"`
-		Password string `json:"password"`
-		DBName   string `json:"db" binding:"required"`
-	}
+	var p migSource
 	if err := c.ShouldBindJSON(&p); err != nil {
 		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
 		return
 	}
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local&timeout=10s", p.User, p.Password, p.Host, p.Port, p.DBName)
-	src, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	if err != nil {
-		c.JSON(http.StatusOK, response.ErrMsg("连接失败"))
+	src, msg := p.open()
+	if src == nil {
+		c.JSON(http.StatusOK, response.ErrMsg(msg))
 		return
 	}
-	var (
-		userCount           int64
-		nodeCount           int64
-		tunnelCount         int64
-		forwardCount        int64
-		userTunnelCount     int64
-		speedLimitCount     int64
-		viteConfigCount     int64
-		statisticsFlowCount int64
-	)
+	defer closeGorm(src)
 	counts := map[string]int64{}
-
-	_ = src.Model(&model.User{}).Count(&userCount).Error
-	counts["user"] = userCount
-	/*
-		_ = src.Model(&model.Node{}).Count(&counts["node"]).Error
-			_ = src.Model(&model.Tunnel{}).Count(&counts["tunnel"]).Error
-			_ = src.Model(&model.Forward{}).Count(&counts["forward"]).Error
-			_ = src.Model(&model.UserTunnel{}).Count(&counts["user_tunnel"]).Error
-			_ = src.Model(&model.SpeedLimit{}).Count(&counts["speed_limit"]).Error
-			_ = src.Model(&model.ViteConfig{}).Count(&counts["vite_config"]).Error
-			_ = src.Model(&model.StatisticsFlow{}).Count(&counts["statistics_flow"]).Error
-	*/
-	_ = src.Model(&model.Node{}).Count(&nodeCount).Error
-	counts["node"] = nodeCount
-	_ = src.Model(&model.Tunnel{}).Count(&tunnelCount).Error
-	counts["tunnel"] = tunnelCount
-	_ = src.Model(&model.Forward{}).Count(&forwardCount).Error
-	counts["forward"] = forwardCount
-	_ = src.Model(&model.UserTunnel{}).Count(&userTunnelCount).Error
-	counts["user_tunnel"] = userTunnelCount
-	_ = src.Model(&model.SpeedLimit{}).Count(&speedLimitCount).Error
-	counts["speed_limit"] = speedLimitCount
-	_ = src.Model(&model.ViteConfig{}).Count(&viteConfigCount).Error
-	counts["vite_config"] = viteConfigCount
-	_ = src.Model(&model.StatisticsFlow{}).Count(&statisticsFlowCount).Error
-	counts["statistics_flow"] = statisticsFlowCount
-	
+	for _, t := range migTables {
+		n, _ := t.count(src)
+		counts[t.name] = n
+	}
 	c.JSON(http.StatusOK, response.Ok(map[string]any{"ok": true, "counts": counts}))
 }
 
@@ -191,9 +367,13 @@ type migProgress struct {
 	UpdatedAt int64       `json:"updatedAt"`
 	Status    string      `json:"status"` // running, done, error
 	Error     string      `json:"error,omitempty"`
+	DryRun    bool        `json:"dryRun"`
 	Tables    []tableStat `json:"tables"`
 	Current   int         `json:"current"`
 	Total     int         `json:"total"`
+	// table in progress and rows processed so far
+	Table string `json:"table,omitempty"`
+	Rows  int64  `json:"rows"`
 }
 
 var (
@@ -203,31 +383,32 @@ var (
 
 // POST /api/v1/migrate/start
 func MigrateStart(c *gin.Context) {
-	var p struct {
-		Host     string `json:"host" binding:"required"`
-		Port     string `json:"port" binding:"required"`
-		User     string `json:"user" binding:"required"`
-		Password string `json:"password"`
-		DBName   string `json:"db" binding:"required"`
-	}
+	var p migSource
 	if err := c.ShouldBindJSON(&p); err != nil {
 		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
 		return
 	}
-	job := &migProgress{JobID: fmt.Sprintf("job_%d", time.Now().UnixNano()), StartedAt: time.Now().UnixMilli(), UpdatedAt: time.Now().UnixMilli(), Status: "running", Total: 8}
+	opt, err := p.options()
+	if err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg(err.Error()))
+		return
+	}
+	job := &migProgress{JobID: fmt.Sprintf("job_%d", time.Now().UnixNano()), StartedAt: time.Now().UnixMilli(), UpdatedAt: time.Now().UnixMilli(), Status: "running", Total: len(migTables), DryRun: opt.DryRun}
 	migMu.Lock()
 	migJobs[job.JobID] = job
 	migMu.Unlock()
 	go func() {
-		defer func() { job.UpdatedAt = time.Now().UnixMilli() }()
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local&timeout=10s", p.User, p.Password, p.Host, p.Port, p.DBName)
-		src, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-		if err != nil {
+		src, msg := p.open()
+		if src == nil {
+			migMu.Lock()
 			job.Status = "error"
-			job.Error = "连接源数据库失败"
+			job.Error = msg
+			job.UpdatedAt = time.Now().UnixMilli()
+			migMu.Unlock()
 			return
 		}
-		runWithProgress(src, dbpkg.DB, job)
+		defer closeGorm(src)
+		runWithProgress(src, dbpkg.DB, job, opt)
 	}()
 	c.JSON(http.StatusOK, response.Ok(map[string]any{"jobId": job.JobID}))
 }
@@ -237,47 +418,52 @@ func MigrateStatus(c *gin.Context) {
 	id := c.Query("jobId")
 	migMu.Lock()
 	job := migJobs[id]
+	var snap migProgress
+	if job != nil {
+		snap = *job
+		snap.Tables = append([]tableStat(nil), job.Tables...)
+	}
 	migMu.Unlock()
 	if job == nil {
 		c.JSON(http.StatusOK, response.ErrMsg("job not found"))
 		return
 	}
-	c.JSON(http.StatusOK, response.Ok(job))
+	c.JSON(http.StatusOK, response.Ok(snap))
 }
 
-func runWithProgress(src *gorm.DB, dst *gorm.DB, job *migProgress) {
-	update := func() { job.UpdatedAt = time.Now().UnixMilli() }
-	do := func(table string, fn func() (tableStat, error)) bool {
-		st, err := fn()
-		if err != nil {
-			job.Status = "error"
-			job.Error = table + ":" + err.Error()
-			update()
-			return false
-		}
-		job.Tables = append(job.Tables, st)
-		job.Current = len(job.Tables)
-		update()
-		return true
-	}
-	seq := []struct {
-		name string
-		fn   func() (tableStat, error)
-	}{
-		{"user", func() (tableStat, error) { return copyTable[model.User](src, dst, "user") }},
-		{"node", func() (tableStat, error) { return copyTable[model.Node](src, dst, "node") }},
-		{"tunnel", func() (tableStat, error) { return copyTable[model.Tunnel](src, dst, "tunnel") }},
-		{"forward", func() (tableStat, error) { return copyTable[model.Forward](src, dst, "forward") }},
-		{"user_tunnel", func() (tableStat, error) { return copyTable[model.UserTunnel](src, dst, "user_tunnel") }},
-		{"speed_limit", func() (tableStat, error) { return copyTable[model.SpeedLimit](src, dst, "speed_limit") }},
-		{"vite_config", func() (tableStat, error) { return copyTable[model.ViteConfig](src, dst, "vite_config") }},
-		{"statistics_flow", func() (tableStat, error) { return copyTable[model.StatisticsFlow](src, dst, "statistics_flow") }},
-	}
-	for _, step := range seq {
-		if !do(step.name, step.fn) {
-			return
+func runWithProgress(src *gorm.DB, dst *gorm.DB, job *migProgress, opt migOptions) {
+	update := func(fn func()) {
+		migMu.Lock()
+		fn()
+		job.UpdatedAt = time.Now().UnixMilli()
+		migMu.Unlock()
+	}
+	var failed string
+	err := migApply(dst, opt, func(tx *gorm.DB) error {
+		for _, t := range migTables {
+			name := t.name
+			update(func() { job.Table = name; job.Rows = 0 })
+			st, err := t.copy(src, tx, opt, func(rows int64) { update(func() { job.Rows = rows }) })
+			if err != nil {
+				failed = name
+				return err
+			}
+			update(func() {
+				job.Tables = append(job.Tables, st)
+				job.Current = len(job.Tables)
+			})
 		}
+		return nil
+	})
+	if err != nil {
+		update(func() {
+			job.Status = "error"
+			job.Error = failed + ":" + err.Error()
+			if !opt.DryRun {
+				job.Error += "（已回滚，目标库未改动）"
+			}
+		})
+		return
 	}
-	job.Status = "done"
-	update()
+	update(func() { job.Status = "done"; job.Table = "" })
 }
@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"network-panel/golang-backend/internal/app/model"
+	"network-panel/golang-backend/internal/app/response"
+	dbpkg "network-panel/golang-backend/internal/db"
+)
+
+// Per-interface bandwidth: the agent reports cumulative rx/tx counters per interface
+// ("net_interfaces"); the panel keeps the previous reading per node/interface in
+// memory and stores the deltas and rates as node_netif_sample rows.
+
+type netIfReading struct {
+	rx, tx uint64
+	timeMs int64
+}
+
+var (
+	netIfMu   sync.Mutex
+	netIfLast = map[int64]map[string]netIfReading{}
+)
+
+// counterDelta returns the bytes counted between two readings. A counter that went
+// backwards was reset (reboot, driver reload, wrap), so everything since the reset
+// is the current value.
+func counterDelta(prev, cur uint64) uint64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+// recordNetIfCounters turns a sysinfo payload's interface counters into per-interface
+// samples and returns the current rates for broadcasting. The first reading of an
+// interface only primes the state.
+func recordNetIfCounters(nodeID int64, m map[string]interface{}) []map[string]interface{} {
+	list, _ := m["net_interfaces"].([]interface{})
+	if len(list) == 0 {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	netIfMu.Lock()
+	last := netIfLast[nodeID]
+	if last == nil {
+		last = map[string]netIfReading{}
+		netIfLast[nodeID] = last
+	}
+	rows := make([]model.NodeNetIfSample, 0, len(list))
+	for _, it := range list {
+		e, _ := it.(map[string]interface{})
+		name, _ := e["name"].(string)
+		if name == "" {
+			continue
+		}
+		rxf, _ := toFloat(e["rx"])
+		txf, _ := toFloat(e["tx"])
+		cur := netIfReading{rx: uint64(rxf), tx: uint64(txf), timeMs: now}
+		prev, ok := last[name]
+		last[name] = cur
+		if !ok || now <= prev.timeMs {
+			continue
+		}
+		dt := now - prev.timeMs
+		rx, tx := counterDelta(prev.rx, cur.rx), counterDelta(prev.tx, cur.tx)
+		rows = append(rows, model.NodeNetIfSample{
+			NodeID: nodeID, Iface: name, TimeMs: now, IntervalMs: dt,
+			RxBytes: int64(rx), TxBytes: int64(tx),
+			RxBps: float64(rx) * 1000 / float64(dt), TxBps: float64(tx) * 1000 / float64(dt),
+		})
+	}
+	netIfMu.Unlock()
+	if len(rows) == 0 {
+		return nil
+	}
+	_ = dbpkg.DB.Create(&rows).Error
+	rates := make([]map[string]interface{}, 0, len(rows))
+	for _, r := range rows {
+		rates = append(rates, map[string]interface{}{"name": r.Iface, "rx_bps": r.RxBps, "tx_bps": r.TxBps})
+	}
+	return rates
+}
+
+// netIfSample is one bandwidth point: a raw sample or a rollup bucket.
+type netIfSample struct {
+	Iface              string
+	TimeMs, IntervalMs int64
+	RxBytes, TxBytes   int64
+	RxPeak, TxPeak     float64
+}
+
+// loadNetIfSamples returns a node's interface samples in [from, to) (iface "" = all),
+// picking the tier like loadSysSamples.
+func loadNetIfSamples(nodeID int64, iface string, from, to int64) ([]netIfSample, int) {
+	res := readRetention().resolutionFor(time.Now().UnixMilli() - from)
+	out := make([]netIfSample, 0)
+	covered := map[string]int64{}
+	rawFrom := from
+	if res > 0 {
+		q := dbpkg.DB.Where("node_id = ? AND res_s = ? AND time_ms >= ? AND time_ms < ?", nodeID, res, from, to)
+		if iface != "" {
+			q = q.Where("iface = ?", iface)
+		}
+		var rows []model.NodeNetIfRollup
+		q.Order("time_ms asc").Find(&rows)
+		for _, r := range rows {
+			out = append(out, netIfSample{Iface: r.Iface, TimeMs: r.TimeMs, IntervalMs: r.IntervalMs, RxBytes: r.RxBytes, TxBytes: r.TxBytes, RxPeak: r.RxBpsMax, TxPeak: r.TxBpsMax})
+			covered[r.Iface] = r.TimeMs + int64(res)*1000
+		}
+		if len(covered) > 0 {
+			rawFrom = to
+			for _, end := range covered {
+				rawFrom = min64(rawFrom, end)
+			}
+		}
+	}
+	if rawFrom < to {
+		q := dbpkg.DB.Where("node_id = ? AND time_ms >= ? AND time_ms < ?", nodeID, rawFrom, to)
+		if iface != "" {
+			q = q.Where("iface = ?", iface)
+		}
+		var rows []model.NodeNetIfSample
+		q.Order("time_ms asc").Find(&rows)
+		for _, r := range rows {
+			if r.TimeMs < covered[r.Iface] {
+				continue
+			}
+			out = append(out, netIfSample{Iface: r.Iface, TimeMs: r.TimeMs, IntervalMs: r.IntervalMs, RxBytes: r.RxBytes, TxBytes: r.TxBytes, RxPeak: r.RxBps, TxPeak: r.TxBps})
+		}
+	}
+	return out, res
+}
+
+// netIfBucket is the bandwidth of one interface in a bucket: average rates over the
+// reported time, peak per-report rates and the bytes transferred.
+type netIfBucket struct {
+	TimeMs   int64   `json:"timeMs"`
+	RxBps    float64 `json:"rxBps"`
+	TxBps    float64 `json:"txBps"`
+	RxBpsMax float64 `json:"rxBpsMax"`
+	TxBpsMax float64 `json:"txBpsMax"`
+	RxBytes  int64   `json:"rxBytes"`
+	TxBytes  int64   `json:"txBytes"`
+
+	intervalMs int64
+}
+
+// POST /api/v1/node/bandwidth {nodeId, iface?, range | from,to, points?}
+// Per-interface bandwidth series built from the agent's interface counters.
+func NodeBandwidth(c *gin.Context) {
+	var p struct {
+		NodeID int64  `json:"nodeId" binding:"required"`
+		Iface  string `json:"iface"`
+		seriesParams
+	}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	from, to, ok := p.window()
+	if !ok {
+		c.JSON(http.StatusOK, response.ErrMsg("时间范围无效"))
+		return
+	}
+	samples, res := loadNetIfSamples(p.NodeID, p.Iface, from, to)
+	bms := p.bucketMs(from, to, res)
+	series := map[string][]netIfBucket{}
+	for _, s := range samples {
+		start := from + (s.TimeMs-from)/bms*bms
+		list := series[s.Iface]
+		if len(list) == 0 || list[len(list)-1].TimeMs != start {
+			list = append(list, netIfBucket{TimeMs: start})
+		}
+		b := &list[len(list)-1]
+		b.RxBytes += s.RxBytes
+		b.TxBytes += s.TxBytes
+		b.intervalMs += s.IntervalMs
+		b.RxBpsMax = max(b.RxBpsMax, s.RxPeak)
+		b.TxBpsMax = max(b.TxBpsMax, s.TxPeak)
+		series[s.Iface] = list
+	}
+	ifaces := make([]string, 0, len(series))
+	for name, list := range series {
+		for i := range list {
+			if list[i].intervalMs > 0 {
+				list[i].RxBps = float64(list[i].RxBytes) * 1000 / float64(list[i].intervalMs)
+				list[i].TxBps = float64(list[i].TxBytes) * 1000 / float64(list[i].intervalMs)
+			}
+		}
+		ifaces = append(ifaces, name)
+	}
+	sort.Strings(ifaces)
+	c.JSON(http.StatusOK, response.Ok(map[string]any{
+		"interfaces": ifaces,
+		"series":     series,
+		"from":       from,
+		"to":         to,
+		"resolution": res,
+		"bucketMs":   bms,
+	}))
+}
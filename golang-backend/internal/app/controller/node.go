@@ -166,6 +166,8 @@ func NodeDelete(c *gin.Context) {
         c.JSON(http.StatusOK, response.ErrMsg("节点删除失败"))
         return
     }
+    dbpkg.DB.Where("node_id = ?", p.ID).Delete(&model.NodeCommand{})
+    dbpkg.DB.Where("node_id = ?", p.ID).Delete(&model.NodeDrift{})
     c.JSON(http.StatusOK, response.OkMsg("节点删除成功"))
 }
 
@@ -189,11 +191,15 @@ func NodeInstallCmd(c *gin.Context) {
 		c.JSON(http.StatusOK, response.ErrMsg("请先前往网站配置中设置ip"))
 		return
 	}
-	server := wrapIPv6(cfg.Value)
+	server := wrapIPv6(serverBaseURL())
 	// Pull install.sh from the deployed service instead of GitHub raw
 	// Assumes the service exposes GET /install.sh on the same address stored in vite_config.ip
 	// Example: ip = 1.2.3.4:6365 or [2001:db8::1]:6365
-	cmd := "curl -fsSL http://" + server + "/install.sh -o ./install.sh && chmod +x ./install.sh && ./install.sh -a " + server + " -s " + n.Secret
+	// With TLS on, the script is fetched over https and the agent connects with wss.
+	cmd := "curl -fsSL " + panelScheme() + "://" + server + "/install.sh -o ./install.sh && chmod +x ./install.sh && ./install.sh -a " + server + " -s " + n.Secret
+	if panelWSScheme() == "wss" {
+		cmd += " -S wss"
+	}
 	c.JSON(http.StatusOK, response.Ok(cmd))
 }
 
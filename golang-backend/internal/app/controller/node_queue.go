@@ -0,0 +1,327 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"network-panel/golang-backend/internal/app/model"
+	"network-panel/golang-backend/internal/app/response"
+	dbpkg "network-panel/golang-backend/internal/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Per-node queue of service commands that could not be delivered because the
+// node was offline. Commands are replayed in order when the node reconnects;
+// while a node has queued commands, later ones are queued behind them so the
+// order on the node stays the order they were issued in.
+
+const (
+	queuePending = "pending"
+	queueFailed  = "failed"
+
+	// replays that get no acknowledgement are retried this many times
+	queueMaxAttempts = 5
+)
+
+// queueableCmds may wait for an offline node even when the caller waits for the
+// outcome: they only remove or toggle services, so applying them late is still
+// right. Adds and updates have to be confirmed while the user waits.
+var queueableCmds = map[string]bool{"DeleteService": true, "PauseService": true, "ResumeService": true}
+
+// queueSupersedes lists, per command, the earlier commands for the same service
+// a later one makes pointless: a delete or a full add replaces whatever was
+// queued before, a pause or resume replaces an earlier pause or resume.
+var queueSupersedes = map[string]map[string]bool{
+	"DeleteService": {"AddService": true, "UpdateService": true, "DeleteService": true, "PauseService": true, "ResumeService": true},
+	"AddService":    {"AddService": true, "UpdateService": true, "DeleteService": true, "PauseService": true, "ResumeService": true},
+	"PauseService":  {"PauseService": true, "ResumeService": true},
+	"ResumeService": {"PauseService": true, "ResumeService": true},
+}
+
+var queueMu sync.Mutex // serializes queue edits
+
+func hasQueuedCommands(nodeID int64) bool {
+	var n int64
+	dbpkg.DB.Model(&model.NodeCommand{}).Where("node_id = ? AND status = ?", nodeID, queuePending).Count(&n)
+	return n > 0
+}
+
+// queueServiceCommand stores a command for later delivery, after dropping what
+// it supersedes.
+func queueServiceCommand(nodeID int64, cmd string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	names := pushServiceNames(b)
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	dropSupersededLocked(nodeID, cmd, names)
+	now := time.Now().UnixMilli()
+	row := model.NodeCommand{NodeID: nodeID, Cmd: cmd, Services: strings.Join(names, ","), Payload: string(b), Status: queuePending, CreatedTime: now, UpdatedTime: now}
+	if err := dbpkg.DB.Create(&row).Error; err != nil {
+		return err
+	}
+	jlog(map[string]interface{}{"event": "node_command_queued", "nodeId": nodeID, "cmd": cmd, "services": names})
+	return nil
+}
+
+// dropSuperseded removes the services named by a command about to be sent from
+// the queued commands it supersedes.
+func dropSuperseded(nodeID int64, cmd string, data interface{}) {
+	b, _ := json.Marshal(data)
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	dropSupersededLocked(nodeID, cmd, pushServiceNames(b))
+}
+
+func dropSupersededLocked(nodeID int64, cmd string, names []string) {
+	kinds := queueSupersedes[cmd]
+	if len(kinds) == 0 || len(names) == 0 {
+		return
+	}
+	drop := map[string]bool{}
+	for _, n := range names {
+		drop[n] = true
+	}
+	var rows []model.NodeCommand
+	dbpkg.DB.Where("node_id = ?", nodeID).Order("id").Find(&rows)
+	for _, row := range rows {
+		if !kinds[row.Cmd] {
+			continue
+		}
+		payload, left, changed := filterCommandServices(row.Cmd, row.Payload, drop)
+		switch {
+		case !changed:
+		case len(left) == 0:
+			dbpkg.DB.Delete(&model.NodeCommand{}, row.ID)
+		default:
+			dbpkg.DB.Model(&model.NodeCommand{}).Where("id = ?", row.ID).
+				Updates(map[string]any{"payload": payload, "services": strings.Join(left, ","), "updated_time": time.Now().UnixMilli()})
+		}
+	}
+}
+
+// filterCommandServices removes the dropped services from a queued payload:
+// a list of service configs for AddService/UpdateService, {services: [names]}
+// otherwise. It returns the new payload and the names left.
+func filterCommandServices(cmd, payload string, drop map[string]bool) (string, []string, bool) {
+	var left []string
+	changed := false
+	if cmd == "AddService" || cmd == "UpdateService" {
+		var list []map[string]any
+		if json.Unmarshal([]byte(payload), &list) != nil {
+			return payload, nil, false
+		}
+		kept := make([]map[string]any, 0, len(list))
+		for _, svc := range list {
+			n, _ := svc["name"].(string)
+			if drop[n] {
+				changed = true
+				continue
+			}
+			kept = append(kept, svc)
+			left = append(left, n)
+		}
+		b, _ := json.Marshal(kept)
+		return string(b), left, changed
+	}
+	var obj struct {
+		Services []string `json:"services"`
+	}
+	if json.Unmarshal([]byte(payload), &obj) != nil {
+		return payload, nil, false
+	}
+	for _, n := range obj.Services {
+		if drop[n] {
+			changed = true
+			continue
+		}
+		left = append(left, n)
+	}
+	b, _ := json.Marshal(map[string]any{"services": left})
+	return string(b), left, changed
+}
+
+// sendOrQueue delivers a service command without waiting for the outcome, or
+// queues it when the node is offline or still has queued commands.
+func sendOrQueue(nodeID int64, cmd string, data interface{}) error {
+	if hasQueuedCommands(nodeID) {
+		if err := queueServiceCommand(nodeID, cmd, data); err != nil {
+			return err
+		}
+		go replayNodeQueue(nodeID)
+		return nil
+	}
+	err := sendWSCommand(nodeID, cmd, data)
+	if errors.Is(err, errNodeOffline) {
+		return queueServiceCommand(nodeID, cmd, data)
+	}
+	return err
+}
+
+var (
+	replayMu  sync.Mutex
+	replaying = map[int64]bool{}
+)
+
+// replayNodeQueue delivers a node's pending commands in order, each waiting for
+// the node's acknowledgement. It stops when the node goes away or does not
+// answer; a command the node rejects is kept as failed and the rest continue.
+func replayNodeQueue(nodeID int64) {
+	replayMu.Lock()
+	if replaying[nodeID] {
+		replayMu.Unlock()
+		return
+	}
+	replaying[nodeID] = true
+	replayMu.Unlock()
+	defer func() {
+		replayMu.Lock()
+		delete(replaying, nodeID)
+		replayMu.Unlock()
+	}()
+	for {
+		var row model.NodeCommand
+		if err := dbpkg.DB.Where("node_id = ? AND status = ?", nodeID, queuePending).Order("id").First(&row).Error; err != nil {
+			return
+		}
+		_, err := sendServiceCommand(nodeID, row.Cmd, json.RawMessage(row.Payload))
+		queueMu.Lock()
+		// the row may have been coalesced meanwhile; only settle what was sent
+		var cur model.NodeCommand
+		if dbpkg.DB.First(&cur, row.ID).Error != nil || cur.Payload != row.Payload {
+			queueMu.Unlock()
+			if errors.Is(err, errNodeOffline) || errors.Is(err, errNoAck) || errors.Is(err, errShutdown) {
+				return
+			}
+			continue
+		}
+		now := time.Now().UnixMilli()
+		switch {
+		case err == nil:
+			dbpkg.DB.Delete(&model.NodeCommand{}, row.ID)
+			jlog(map[string]interface{}{"event": "node_command_replayed", "nodeId": nodeID, "cmd": row.Cmd, "services": row.Services})
+		case errors.Is(err, errNodeOffline), errors.Is(err, errShutdown):
+			queueMu.Unlock()
+			return
+		case errors.Is(err, errNoAck):
+			upd := map[string]any{"attempts": row.Attempts + 1, "last_error": err.Error(), "updated_time": now}
+			if row.Attempts+1 >= queueMaxAttempts {
+				upd["status"] = queueFailed
+			}
+			dbpkg.DB.Model(&model.NodeCommand{}).Where("id = ?", row.ID).Updates(upd)
+			queueMu.Unlock()
+			return
+		default:
+			dbpkg.DB.Model(&model.NodeCommand{}).Where("id = ?", row.ID).
+				Updates(map[string]any{"status": queueFailed, "attempts": row.Attempts + 1, "last_error": err.Error(), "updated_time": now})
+			jlog(map[string]interface{}{"event": "node_command_failed", "nodeId": nodeID, "cmd": row.Cmd, "services": row.Services, "error": err.Error()})
+		}
+		queueMu.Unlock()
+	}
+}
+
+// ReplayNodeQueues retries the pending commands of connected nodes, e.g. after a
+// replay got no acknowledgement.
+func ReplayNodeQueues() {
+	var ids []int64
+	dbpkg.DB.Model(&model.NodeCommand{}).Where("status = ?", queuePending).Distinct().Pluck("node_id", &ids)
+	for _, id := range ids {
+		nodeConnMu.RLock()
+		online := len(nodeConns[id]) > 0
+		nodeConnMu.RUnlock()
+		if online {
+			go replayNodeQueue(id)
+		}
+	}
+}
+
+// POST /api/v1/node/queue/list {nodeId?}
+func NodeQueueList(c *gin.Context) {
+	var p struct {
+		NodeID int64 `json:"nodeId"`
+	}
+	_ = c.ShouldBindJSON(&p)
+	q := dbpkg.DB.Model(&model.NodeCommand{}).Order("node_id, id")
+	if p.NodeID > 0 {
+		q = q.Where("node_id = ?", p.NodeID)
+	}
+	var rows []model.NodeCommand
+	q.Limit(1000).Find(&rows)
+	type summary struct {
+		NodeID   int64  `json:"nodeId"`
+		NodeName string `json:"nodeName"`
+		Online   bool   `json:"online"`
+		Pending  int    `json:"pending"`
+		Failed   int    `json:"failed"`
+	}
+	idx := map[int64]int{}
+	nodes := []summary{}
+	for _, r := range rows {
+		i, ok := idx[r.NodeID]
+		if !ok {
+			i = len(nodes)
+			idx[r.NodeID] = i
+			nodes = append(nodes, summary{NodeID: r.NodeID})
+		}
+		if r.Status == queueFailed {
+			nodes[i].Failed++
+		} else {
+			nodes[i].Pending++
+		}
+	}
+	for i := range nodes {
+		var n model.Node
+		if dbpkg.DB.Select("name").First(&n, nodes[i].NodeID).Error == nil {
+			nodes[i].NodeName = n.Name
+		}
+		nodeConnMu.RLock()
+		nodes[i].Online = len(nodeConns[nodes[i].NodeID]) > 0
+		nodeConnMu.RUnlock()
+	}
+	c.JSON(http.StatusOK, response.Ok(map[string]any{"nodes": nodes, "commands": rows}))
+}
+
+// POST /api/v1/node/queue/retry {nodeId}
+// Puts the node's failed commands back in line and replays the queue.
+func NodeQueueRetry(c *gin.Context) {
+	var p struct {
+		NodeID int64 `json:"nodeId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	queueMu.Lock()
+	dbpkg.DB.Model(&model.NodeCommand{}).Where("node_id = ? AND status = ?", p.NodeID, queueFailed).
+		Updates(map[string]any{"status": queuePending, "attempts": 0, "updated_time": time.Now().UnixMilli()})
+	queueMu.Unlock()
+	go replayNodeQueue(p.NodeID)
+	c.JSON(http.StatusOK, response.OkMsg("已重新排队"))
+}
+
+// POST /api/v1/node/queue/delete {id} | {nodeId}
+func NodeQueueDelete(c *gin.Context) {
+	var p struct {
+		ID     int64 `json:"id"`
+		NodeID int64 `json:"nodeId"`
+	}
+	if err := c.ShouldBindJSON(&p); err != nil || (p.ID == 0 && p.NodeID == 0) {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	if p.ID > 0 {
+		dbpkg.DB.Delete(&model.NodeCommand{}, p.ID)
+	} else {
+		dbpkg.DB.Where("node_id = ?", p.NodeID).Delete(&model.NodeCommand{})
+	}
+	c.JSON(http.StatusOK, response.OkMsg("已删除"))
+}
@@ -0,0 +1,600 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"network-panel/golang-backend/internal/app/model"
+	"network-panel/golang-backend/internal/app/response"
+	dbpkg "network-panel/golang-backend/internal/db"
+)
+
+// Notification channels. Every event passed to notifyCallback is offered to each
+// enabled channel whose event/severity filters match; the channel's template is
+// rendered with the event payload ({key} for any payload field) and sent in the
+// background with exponential backoff. Each delivery ends up in notify_delivery.
+
+var notifyTypes = map[string]bool{"webhook": true, "telegram": true, "smtp": true, "dingtalk": true, "wecom": true, "feishu": true}
+
+const notifyDefaultTemplate = "[{event}] {name} {message}"
+
+// notifyChannelConfig is the union of the per-type settings.
+type notifyChannelConfig struct {
+	// webhook, dingtalk, wecom, feishu
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	Secret  string            `json:"secret"` // dingtalk / feishu signing secret
+	// telegram
+	BotToken string `json:"botToken"`
+	ChatID   string `json:"chatId"`
+	APIBase  string `json:"apiBase"`
+	// smtp
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	Security string   `json:"security"` // starttls (default), ssl, none
+	Subject  string   `json:"subject"`  // template, default "[{event}] {name}"
+}
+
+func (cfg notifyChannelConfig) validate(typ string) string {
+	switch typ {
+	case "webhook", "dingtalk", "wecom", "feishu":
+		if u, err := url.Parse(cfg.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "请填写有效的 URL"
+		}
+	case "telegram":
+		if cfg.BotToken == "" || cfg.ChatID == "" {
+			return "请填写 botToken 和 chatId"
+		}
+	case "smtp":
+		if cfg.Host == "" || cfg.From == "" || len(cfg.To) == 0 {
+			return "请填写 SMTP 服务器、发件人和收件人"
+		}
+	}
+	return ""
+}
+
+// notifyMatch reports whether a channel wants the event.
+func notifyMatch(ch model.NotifyChannel, event string, payload map[string]any) bool {
+	if ch.Events != nil && *ch.Events != "" {
+		var events []string
+		_ = json.Unmarshal([]byte(*ch.Events), &events)
+		if len(events) > 0 {
+			ok := false
+			for _, e := range events {
+				if e == event || e == "*" || (strings.HasSuffix(e, "*") && strings.HasPrefix(event, strings.TrimSuffix(e, "*"))) {
+					ok = true
+					break
+				}
+			}
+			if !ok {
+				return false
+			}
+		}
+	}
+	sev, _ := payload["severity"].(string)
+	if sev != "" && ch.Severities != nil && *ch.Severities != "" {
+		var sevs []string
+		_ = json.Unmarshal([]byte(*ch.Severities), &sevs)
+		if len(sevs) > 0 {
+			for _, s := range sevs {
+				if s == sev {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// renderNotifyTemplate replaces {key} with payload values; {payload} is the whole
+// payload as JSON and {timeStr} the event time in server local time.
+func renderNotifyTemplate(tpl string, payload map[string]any) string {
+	if tpl == "" {
+		return tpl
+	}
+	b, _ := json.Marshal(payload)
+	pairs := []string{"{payload}", string(b)}
+	if t, ok := toFloat(payload["time"]); ok {
+		pairs = append(pairs, "{timeStr}", time.UnixMilli(int64(t)).Format("2006-01-02 15:04:05"))
+	}
+	keys := make([]string, 0, len(payload))
+	for k := range payload {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var s string
+		switch v := payload[k].(type) {
+		case nil:
+		case string:
+			s = v
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		case map[string]any, []any:
+			vb, _ := json.Marshal(v)
+			s = string(vb)
+		default:
+			s = fmt.Sprintf("%v", v)
+		}
+		pairs = append(pairs, "{"+k+"}", s)
+	}
+	return strings.NewReplacer(pairs...).Replace(tpl)
+}
+
+// dispatchNotification offers an event to all enabled channels.
+func dispatchNotification(event string, payload map[string]any) {
+	var channels []model.NotifyChannel
+	dbpkg.DB.Where("status = 1").Find(&channels)
+	for _, ch := range channels {
+		if !notifyMatch(ch, event, payload) {
+			continue
+		}
+		go deliverNotification(ch, event, payload, ch.MaxRetries)
+	}
+}
+
+// deliverNotification sends with up to retries extra attempts (backoff 2s, 4s, ...
+// capped at 60s) and records the outcome.
+func deliverNotification(ch model.NotifyChannel, event string, payload map[string]any, retries int) model.NotifyDelivery {
+	start := time.Now()
+	content := renderNotifyTemplate(notifyTemplate(ch), payload)
+	var err error
+	attempts := 0
+	backoff := 2 * time.Second
+	for {
+		attempts++
+		if err = sendNotification(ch, content, payload); err == nil || attempts > retries {
+			break
+		}
+		time.Sleep(backoff)
+		backoff = min(backoff*2, time.Minute)
+	}
+	rec := model.NotifyDelivery{
+		TimeMs: start.UnixMilli(), ChannelID: ch.ID, ChannelName: ch.Name, Event: event,
+		Success: 1, Attempts: attempts, Content: content, DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		rec.Success = 0
+		rec.Error = err.Error()
+		jlog(map[string]interface{}{"event": "notify_failed", "channelId": ch.ID, "type": ch.Type, "notifyEvent": event, "attempts": attempts, "error": err.Error()})
+	}
+	_ = dbpkg.DB.Create(&rec).Error
+	return rec
+}
+
+func notifyTemplate(ch model.NotifyChannel) string {
+	if ch.Template != "" {
+		return ch.Template
+	}
+	if ch.Type == "webhook" {
+		return "{payload}"
+	}
+	return notifyDefaultTemplate
+}
+
+var notifyHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+func sendNotification(ch model.NotifyChannel, content string, payload map[string]any) error {
+	var cfg notifyChannelConfig
+	if err := json.Unmarshal([]byte(ch.Config), &cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	switch ch.Type {
+	case "webhook":
+		method := strings.ToUpper(cfg.Method)
+		if method == "GET" {
+			u := cfg.URL
+			if content != "" {
+				q := url.Values{"content": {content}}.Encode()
+				if strings.Contains(u, "?") {
+					u += "&" + q
+				} else {
+					u += "?" + q
+				}
+			}
+			_, err := notifyHTTP("GET", u, nil, cfg.Headers)
+			return err
+		}
+		_, err := notifyHTTP("POST", cfg.URL, []byte(content), cfg.Headers)
+		return err
+	case "telegram":
+		base := strings.TrimRight(cfg.APIBase, "/")
+		if base == "" {
+			base = "https://api.telegram.org"
+		}
+		body, _ := json.Marshal(map[string]any{"chat_id": cfg.ChatID, "text": content})
+		resp, err := notifyHTTP("POST", base+"/bot"+cfg.BotToken+"/sendMessage", body, nil)
+		if err != nil {
+			return err
+		}
+		var r struct {
+			OK          bool   `json:"ok"`
+			Description string `json:"description"`
+		}
+		if json.Unmarshal(resp, &r) == nil && !r.OK {
+			return fmt.Errorf("telegram: %s", r.Description)
+		}
+		return nil
+	case "dingtalk":
+		u := cfg.URL
+		if cfg.Secret != "" {
+			ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+			mac := hmac.New(sha256.New, []byte(cfg.Secret))
+			mac.Write([]byte(ts + "\n" + cfg.Secret))
+			sep := "?"
+			if strings.Contains(u, "?") {
+				sep = "&"
+			}
+			u += sep + "timestamp=" + ts + "&sign=" + url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+		}
+		body, _ := json.Marshal(map[string]any{"msgtype": "text", "text": map[string]any{"content": content}})
+		return notifyBotResult(notifyHTTP("POST", u, body, nil))
+	case "wecom":
+		body, _ := json.Marshal(map[string]any{"msgtype": "text", "text": map[string]any{"content": content}})
+		return notifyBotResult(notifyHTTP("POST", cfg.URL, body, nil))
+	case "feishu":
+		msg := map[string]any{"msg_type": "text", "content": map[string]any{"text": content}}
+		if cfg.Secret != "" {
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+			mac := hmac.New(sha256.New, []byte(ts+"\n"+cfg.Secret))
+			msg["timestamp"] = ts
+			msg["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		}
+		body, _ := json.Marshal(msg)
+		return notifyBotResult(notifyHTTP("POST", cfg.URL, body, nil))
+	case "smtp":
+		subject := cfg.Subject
+		if subject == "" {
+			subject = "[{event}] {name}"
+		}
+		return sendNotifyMail(cfg, renderNotifyTemplate(subject, payload), content)
+	}
+	return fmt.Errorf("unsupported channel type %q", ch.Type)
+}
+
+// notifyHTTP performs the request and returns the body; non-2xx is an error.
+func notifyHTTP(method, u string, body []byte, headers map[string]string) ([]byte, error) {
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u, rd)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := notifyHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return b, fmt.Errorf("http %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	return b, nil
+}
+
+// notifyBotResult checks the error code of DingTalk / WeCom / Feishu bot replies.
+func notifyBotResult(b []byte, err error) error {
+	if err != nil {
+		return err
+	}
+	var r struct {
+		ErrCode *int   `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		Code    *int   `json:"code"`
+		Msg     string `json:"msg"`
+	}
+	if json.Unmarshal(b, &r) != nil {
+		return nil
+	}
+	if r.ErrCode != nil && *r.ErrCode != 0 {
+		return fmt.Errorf("errcode %d: %s", *r.ErrCode, r.ErrMsg)
+	}
+	if r.Code != nil && *r.Code != 0 {
+		return fmt.Errorf("code %d: %s", *r.Code, r.Msg)
+	}
+	return nil
+}
+
+func sendNotifyMail(cfg notifyChannelConfig, subject, content string) error {
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+		if cfg.Security == "ssl" {
+			port = 465
+		}
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if cfg.Security == "ssl" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: cfg.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if cfg.Security != "ssl" && cfg.Security != "none" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("smtp: server does not support STARTTLS")
+		}
+		if err := c.StartTLS(&tls.Config{ServerName: cfg.Host}); err != nil {
+			return err
+		}
+	}
+	if cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(cfg.From); err != nil {
+		return err
+	}
+	for _, to := range cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	msg := "From: " + cfg.From + "\r\n" +
+		"To: " + strings.Join(cfg.To, ", ") + "\r\n" +
+		"Subject: =?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte(subject)) + "?=\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\n" +
+		base64.StdEncoding.EncodeToString([]byte(content)) + "\r\n"
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// PruneNotifyDeliveries drops delivery log rows older than notify_delivery_retention_days (default 30).
+func PruneNotifyDeliveries() {
+	days := getConfigInt("notify_delivery_retention_days", 30)
+	if days <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-time.Duration(days) * 24 * time.Hour).UnixMilli()
+	dbpkg.DB.Where("time_ms < ?", cutoff).Delete(&model.NotifyDelivery{})
+}
+
+// ---- Admin endpoints ----
+
+type notifyChannelParams struct {
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	Config     json.RawMessage `json:"config"`
+	Template   *string         `json:"template"`
+	Events     []string        `json:"events"`
+	Severities []string        `json:"severities"`
+	MaxRetries *int            `json:"maxRetries"`
+	Status     *int            `json:"status"`
+}
+
+func (p notifyChannelParams) apply(ch *model.NotifyChannel) string {
+	if p.Name != "" {
+		ch.Name = p.Name
+	}
+	if p.Type != "" {
+		ch.Type = p.Type
+	}
+	if len(p.Config) > 0 {
+		// accept an object or a JSON string
+		var s string
+		if json.Unmarshal(p.Config, &s) == nil {
+			ch.Config = s
+		} else {
+			ch.Config = string(p.Config)
+		}
+	}
+	if p.Template != nil {
+		ch.Template = *p.Template
+	}
+	if p.Events != nil {
+		ch.Events = jsonListOrNil(p.Events)
+	}
+	if p.Severities != nil {
+		ch.Severities = jsonListOrNil(p.Severities)
+	}
+	if p.MaxRetries != nil {
+		ch.MaxRetries = *p.MaxRetries
+	}
+	if p.Status != nil {
+		ch.Status = *p.Status
+	}
+	if ch.Name == "" {
+		return "名称不能为空"
+	}
+	if !notifyTypes[ch.Type] {
+		return "不支持的通道类型"
+	}
+	if ch.MaxRetries < 0 || ch.MaxRetries > 10 {
+		return "重试次数范围 0-10"
+	}
+	var cfg notifyChannelConfig
+	if err := json.Unmarshal([]byte(ch.Config), &cfg); err != nil {
+		return "通道配置格式错误"
+	}
+	return cfg.validate(ch.Type)
+}
+
+func jsonListOrNil(list []string) *string {
+	if len(list) == 0 {
+		return nil
+	}
+	b, _ := json.Marshal(list)
+	s := string(b)
+	return &s
+}
+
+// POST /api/v1/notify-channel/list
+func NotifyChannelList(c *gin.Context) {
+	var list []model.NotifyChannel
+	dbpkg.DB.Order("id desc").Find(&list)
+	c.JSON(http.StatusOK, response.Ok(list))
+}
+
+// POST /api/v1/notify-channel/create {name, type, config, template?, events?, severities?, maxRetries?}
+func NotifyChannelCreate(c *gin.Context) {
+	var p notifyChannelParams
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	now := time.Now().UnixMilli()
+	ch := model.NotifyChannel{CreatedTime: now, UpdatedTime: now, Status: 1, MaxRetries: 3}
+	if msg := p.apply(&ch); msg != "" {
+		c.JSON(http.StatusOK, response.ErrMsg(msg))
+		return
+	}
+	if err := dbpkg.DB.Create(&ch).Error; err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("保存失败"))
+		return
+	}
+	c.JSON(http.StatusOK, response.Ok(ch))
+}
+
+// POST /api/v1/notify-channel/update {id, ...same as create, status?}
+func NotifyChannelUpdate(c *gin.Context) {
+	var p struct {
+		ID int64 `json:"id" binding:"required"`
+		notifyChannelParams
+	}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	var ch model.NotifyChannel
+	if err := dbpkg.DB.First(&ch, p.ID).Error; err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("不存在"))
+		return
+	}
+	if msg := p.apply(&ch); msg != "" {
+		c.JSON(http.StatusOK, response.ErrMsg(msg))
+		return
+	}
+	ch.UpdatedTime = time.Now().UnixMilli()
+	if err := dbpkg.DB.Save(&ch).Error; err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("保存失败"))
+		return
+	}
+	c.JSON(http.StatusOK, response.Ok(ch))
+}
+
+// POST /api/v1/notify-channel/delete {id}
+func NotifyChannelDelete(c *gin.Context) {
+	var p struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	_ = dbpkg.DB.Delete(&model.NotifyChannel{}, p.ID).Error
+	c.JSON(http.StatusOK, response.OkNoData())
+}
+
+// POST /api/v1/notify-channel/test {id}
+// Sends a test message once (no retries) and returns the delivery record.
+func NotifyChannelTest(c *gin.Context) {
+	var p struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	var ch model.NotifyChannel
+	if err := dbpkg.DB.First(&ch, p.ID).Error; err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("不存在"))
+		return
+	}
+	payload := map[string]any{
+		"event": "test", "nodeId": 0, "name": "network-panel", "time": time.Now().UnixMilli(),
+		"message": "这是一条测试通知", "severity": "info",
+	}
+	rec := deliverNotification(ch, "test", payload, 0)
+	if rec.Success != 1 {
+		c.JSON(http.StatusOK, response.ErrMsg("发送失败: "+rec.Error))
+		return
+	}
+	c.JSON(http.StatusOK, response.Ok(rec))
+}
+
+// POST /api/v1/notify-channel/deliveries {channelId?, event?, success?, page?, size?}
+func NotifyDeliveryList(c *gin.Context) {
+	var p struct {
+		ChannelID int64  `json:"channelId"`
+		Event     string `json:"event"`
+		Success   *int   `json:"success"`
+		Page      int    `json:"page"`
+		Size      int    `json:"size"`
+	}
+	_ = c.ShouldBindJSON(&p)
+	if p.Page <= 0 {
+		p.Page = 1
+	}
+	if p.Size <= 0 || p.Size > 200 {
+		p.Size = 50
+	}
+	q := dbpkg.DB.Model(&model.NotifyDelivery{})
+	if p.ChannelID > 0 {
+		q = q.Where("channel_id = ?", p.ChannelID)
+	}
+	if p.Event != "" {
+		q = q.Where("event = ?", p.Event)
+	}
+	if p.Success != nil {
+		q = q.Where("success = ?", *p.Success)
+	}
+	var total int64
+	q.Count(&total)
+	var list []model.NotifyDelivery
+	q.Order("time_ms desc, id desc").Offset((p.Page - 1) * p.Size).Limit(p.Size).Find(&list)
+	c.JSON(http.StatusOK, response.Ok(map[string]any{"list": list, "total": total}))
+}
@@ -9,8 +9,9 @@ import (
 
 // makeObserverForNode builds an observer string for gost service to report flow to panel.
 // Template can be customized via vite_config name "forward_observer_template".
-// Placeholders: {SERVER} -> base server URL (http://host), {SECRET} -> node secret.
-// Default: webapi://{SERVER}/flow/upload?secret={SECRET}
+// Placeholders: {SERVER} -> server host[:port], {SCHEME} -> http or https, {SECRET} -> node secret.
+// Default: {SCHEME}://{SERVER}/flow/upload?secret={SECRET}, https when the panel
+// serves TLS or the configured ip is an https:// address.
 func makeObserverForNode(nodeID int64) string {
     secret := nodeSecret(nodeID)
     if secret == "" {
@@ -22,9 +23,10 @@ func makeObserverForNode(nodeID int64) string {
     }
     tpl := strings.TrimSpace(getCfg("forward_observer_template"))
     if tpl == "" {
-        tpl = "webapi://{SERVER}/flow/upload?secret={SECRET}"
+        tpl = "{SCHEME}://{SERVER}/flow/upload?secret={SECRET}"
     }
     v := strings.ReplaceAll(tpl, "{SERVER}", base)
+    v = strings.ReplaceAll(v, "{SCHEME}", panelScheme())
     v = strings.ReplaceAll(v, "{SECRET}", secret)
     return v
 }
@@ -45,11 +47,12 @@ func buildObserverPluginSpec(nodeID int64, serviceName string) (string, map[stri
         fwdID = serviceName
     }
     obsName := "obs_" + fwdID
-    // Build plugin addr using http scheme
-    addr := "http://" + base + "/flow/upload?secret=" + secret + "&id=" + fwdID
-    // allow override via template forward_observer_plugin_template, e.g. http://{SERVER}/path?secret={SECRET}&id={ID}
+    // Build plugin addr using http or https, depending on whether the panel serves TLS
+    addr := panelScheme() + "://" + base + "/flow/upload?secret=" + secret + "&id=" + fwdID
+    // allow override via template forward_observer_plugin_template, e.g. {SCHEME}://{SERVER}/path?secret={SECRET}&id={ID}
     if tpl := strings.TrimSpace(getCfg("forward_observer_plugin_template")); tpl != "" {
         v := strings.ReplaceAll(tpl, "{SERVER}", base)
+        v = strings.ReplaceAll(v, "{SCHEME}", panelScheme())
         v = strings.ReplaceAll(v, "{SECRET}", secret)
         v = strings.ReplaceAll(v, "{ID}", fwdID)
         addr = v
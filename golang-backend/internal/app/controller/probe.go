@@ -145,90 +145,22 @@ func AgentReportProbe(c *gin.Context) {
 
 // ---- Query stats for frontend ----
 
-// POST /api/v1/node/network-stats {nodeId, range}
+// POST /api/v1/node/network-stats {nodeId, range | from,to, points?}
 func NodeNetworkStats(c *gin.Context) {
 	var p struct {
-		NodeID int64  `json:"nodeId" binding:"required"`
-		Range  string `json:"range"`
+		NodeID int64 `json:"nodeId" binding:"required"`
+		seriesParams
 	}
 	if err := c.ShouldBindJSON(&p); err != nil {
 		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
 		return
 	}
-	// time window
-	now := time.Now().UnixMilli()
-	var windowMs int64
-	switch p.Range {
-	case "1h":
-		windowMs = 3600 * 1000
-	case "12h":
-		windowMs = 12 * 3600 * 1000
-	case "1d":
-		windowMs = 24 * 3600 * 1000
-	case "7d":
-		windowMs = 7 * 24 * 3600 * 1000
-	case "30d":
-		windowMs = 30 * 24 * 3600 * 1000
-	default:
-		windowMs = 3600 * 1000
-	}
-	from := now - windowMs
-
-	// results
-	var results []model.NodeProbeResult
-	dbpkg.DB.Where("node_id = ? AND time_ms >= ?", p.NodeID, from).Order("time_ms asc").Find(&results)
-
-	// collect target meta
-	targetIDs := make([]int64, 0)
-	seen := map[int64]struct{}{}
-	for _, r := range results {
-		if _, ok := seen[r.TargetID]; !ok {
-			seen[r.TargetID] = struct{}{}
-			targetIDs = append(targetIDs, r.TargetID)
-		}
-	}
-	m := map[int64]map[string]string{}
-	if len(targetIDs) > 0 {
-		var tgts []model.ProbeTarget
-		dbpkg.DB.Where("id IN ?", targetIDs).Find(&tgts)
-		for _, t := range tgts {
-			m[t.ID] = map[string]string{"name": t.Name, "ip": t.IP}
-		}
-	}
-
-	// disconnect logs
-	var logs []model.NodeDisconnectLog
-	dbpkg.DB.Where("node_id = ? AND (down_at_ms >= ? OR (up_at_ms IS NOT NULL AND up_at_ms >= ?))", p.NodeID, from, from).Order("down_at_ms asc").Find(&logs)
-
-	// compute SLA in window: uptime / window
-	// approximate: subtract summed downtime intersecting window
-	var downMs int64 = 0
-	for _, l := range logs {
-		start := l.DownAtMs
-		end := now
-		if l.UpAtMs != nil {
-			end = *l.UpAtMs
-		}
-		// intersect [start,end] with [from,now]
-		s := max64(start, from)
-		e := min64(end, now)
-		if e > s {
-			downMs += (e - s)
-		}
-	}
-	sla := 0.0
-	if windowMs > 0 {
-		sla = float64(windowMs-downMs) / float64(windowMs)
+	data, ok := networkStats(p.NodeID, p.seriesParams)
+	if !ok {
+		c.JSON(http.StatusOK, response.ErrMsg("时间范围无效"))
+		return
 	}
-
-	c.JSON(http.StatusOK, response.Ok(map[string]interface{}{
-		"results":     results,
-		"targets":     m,
-		"disconnects": logs,
-		"sla":         sla,
-		"from":        from,
-		"to":          now,
-	}))
+	c.JSON(http.StatusOK, response.Ok(data))
 }
 
 func min64(a, b int64) int64 {
@@ -244,84 +176,18 @@ func max64(a, b int64) int64 {
 	return b
 }
 
-// Batch network stats across nodes (latest+avg rtt in window)
-// POST /api/v1/node/network-stats-batch {range}
+// Batch network stats across nodes (latest + avg/min/max/p95 rtt and loss in window)
+// POST /api/v1/node/network-stats-batch {range | from,to}
 func NodeNetworkStatsBatch(c *gin.Context) {
-	var p struct {
-		Range string `json:"range"`
-	}
+	var p seriesParams
 	if err := c.ShouldBindJSON(&p); err != nil {
 		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
 		return
 	}
-	now := time.Now().UnixMilli()
-	var windowMs int64
-	switch p.Range {
-	case "1h":
-		windowMs = 3600 * 1000
-	case "12h":
-		windowMs = 12 * 3600 * 1000
-	case "1d":
-		windowMs = 24 * 3600 * 1000
-	default:
-		windowMs = 3600 * 1000
-	}
-	from := now - windowMs
-	// fetch in window
-	var rows []model.NodeProbeResult
-	dbpkg.DB.Where("time_ms >= ?", from).Order("time_ms asc").Find(&rows)
-	// aggregate per node
-	type stat struct {
-		Sum          int
-		Cnt          int
-		Latest       *int
-		LatestTarget int64
-	}
-	agg := map[int64]*stat{}
-	for _, r := range rows {
-		s := agg[r.NodeID]
-		if s == nil {
-			s = &stat{}
-			agg[r.NodeID] = s
-		}
-		if r.OK == 1 && r.RTTMs > 0 {
-			s.Sum += r.RTTMs
-			s.Cnt++
-		}
-		v := r.RTTMs
-		s.Latest = &v
-		s.LatestTarget = r.TargetID
-	}
-	// fetch target metas for latest target per node
-	tset := map[int64]struct{}{}
-	for _, s := range agg {
-		if s.LatestTarget > 0 {
-			tset[s.LatestTarget] = struct{}{}
-		}
-	}
-	tmeta := map[int64]map[string]string{}
-	if len(tset) > 0 {
-		ids := make([]int64, 0, len(tset))
-		for id := range tset {
-			ids = append(ids, id)
-		}
-		var tgts []model.ProbeTarget
-		dbpkg.DB.Where("id IN ?", ids).Find(&tgts)
-		for _, t := range tgts {
-			tmeta[t.ID] = map[string]string{"name": t.Name, "ip": t.IP}
-		}
-	}
-	out := map[int64]map[string]any{}
-	for nid, s := range agg {
-		var avg *int
-		if s.Cnt > 0 {
-			v := s.Sum / s.Cnt
-			avg = &v
-		}
-		out[nid] = map[string]any{"avg": avg, "latest": s.Latest}
-		if m, ok := tmeta[s.LatestTarget]; ok {
-			out[nid]["latestTarget"] = map[string]any{"id": s.LatestTarget, "name": m["name"], "ip": m["ip"]}
-		}
+	out, ok := networkStatsBatch(p)
+	if !ok {
+		c.JSON(http.StatusOK, response.ErrMsg("时间范围无效"))
+		return
 	}
 	c.JSON(http.StatusOK, response.Ok(out))
 }
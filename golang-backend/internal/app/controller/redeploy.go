@@ -0,0 +1,263 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"network-panel/golang-backend/internal/app/model"
+	"network-panel/golang-backend/internal/app/response"
+	dbpkg "network-panel/golang-backend/internal/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+type forwardDeployResult struct {
+	ForwardID int64  `json:"forwardId"`
+	Name      string `json:"name"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+}
+
+// redeployJob re-renders and pushes every forward of a tunnel after a
+// tunnel-level change. Changes arriving while a job is still queued join it, so
+// saving a tunnel with its path, interfaces and binds runs a single redeploy.
+type redeployJob struct {
+	JobID      string                `json:"jobId"`
+	TunnelID   int64                 `json:"tunnelId"`
+	Reasons    []string              `json:"reasons"`
+	Status     string                `json:"status"` // queued, running, done, error
+	Error      string                `json:"error,omitempty"`
+	CreatedAt  int64                 `json:"createdAt"`
+	StartedAt  int64                 `json:"startedAt,omitempty"`
+	FinishedAt int64                 `json:"finishedAt,omitempty"`
+	Total      int                   `json:"total"`
+	Current    int                   `json:"current"`
+	Failed     int                   `json:"failed"`
+	Forwards   []forwardDeployResult `json:"forwards"`
+	Nodes      []nodeDeployResult    `json:"nodes"`
+
+	// path before the first path change joined into this job
+	oldPath     []int64
+	pathChanged bool
+}
+
+const (
+	redeployDelay     = 2 * time.Second
+	redeployRetention = time.Hour
+)
+
+var (
+	redeployMu      sync.Mutex
+	redeployJobs    = map[string]*redeployJob{}
+	redeployQueued  = map[int64]*redeployJob{}
+	redeployRunning = map[int64]bool{}
+)
+
+// scheduleTunnelRedeploy queues a redeploy of the tunnel's forwards and returns
+// the job id. oldPath is the relay path before the change when the change
+// edited it (nil otherwise), so services left on dropped hops get removed.
+func scheduleTunnelRedeploy(tunnelID int64, reason string, oldPath []int64) string {
+	redeployMu.Lock()
+	defer redeployMu.Unlock()
+	now := time.Now()
+	for id, j := range redeployJobs {
+		if j.FinishedAt > 0 && now.Sub(time.UnixMilli(j.FinishedAt)) > redeployRetention {
+			delete(redeployJobs, id)
+		}
+	}
+	j := redeployQueued[tunnelID]
+	if j == nil {
+		j = &redeployJob{JobID: fmt.Sprintf("redeploy_%d_%d", tunnelID, now.UnixNano()), TunnelID: tunnelID, Status: "queued", CreatedAt: now.UnixMilli()}
+		redeployJobs[j.JobID] = j
+		redeployQueued[tunnelID] = j
+		go runRedeploy(j)
+	}
+	j.Reasons = append(j.Reasons, reason)
+	if oldPath != nil && !j.pathChanged {
+		j.oldPath, j.pathChanged = oldPath, true
+	}
+	return j.JobID
+}
+
+func runRedeploy(j *redeployJob) {
+	time.Sleep(redeployDelay)
+	// one job per tunnel at a time; a change made meanwhile queues the next one
+	for {
+		redeployMu.Lock()
+		if !redeployRunning[j.TunnelID] {
+			delete(redeployQueued, j.TunnelID)
+			redeployRunning[j.TunnelID] = true
+			j.Status = "running"
+			j.StartedAt = time.Now().UnixMilli()
+			redeployMu.Unlock()
+			break
+		}
+		redeployMu.Unlock()
+		time.Sleep(500 * time.Millisecond)
+	}
+	defer func() {
+		redeployMu.Lock()
+		delete(redeployRunning, j.TunnelID)
+		j.FinishedAt = time.Now().UnixMilli()
+		redeployMu.Unlock()
+	}()
+
+	var tun model.Tunnel
+	if err := dbpkg.DB.First(&tun, j.TunnelID).Error; err != nil {
+		redeployMu.Lock()
+		j.Status, j.Error = "error", "隧道不存在"
+		redeployMu.Unlock()
+		return
+	}
+	var list []model.Forward
+	dbpkg.DB.Where("tunnel_id = ?", tun.ID).Order("id").Find(&list)
+	redeployMu.Lock()
+	j.Total = len(list)
+	oldPath, pathChanged := j.oldPath, j.pathChanged
+	redeployMu.Unlock()
+
+	rec := newAckRecorder()
+	// restart each node once after all forwards are pushed, not once per forward
+	rec.batch = true
+	if pathChanged {
+		for nid, names := range droppedHopServices(tun, list, oldPath, getTunnelPathNodes(tun.ID)) {
+			_ = rec.send(nid, "DeleteService", map[string]any{"services": names})
+		}
+	}
+	for _, f := range list {
+		res := forwardDeployResult{ForwardID: f.ID, Name: f.Name}
+		errsBefore := rec.errCount()
+		// paused forwards render paused, keeping nodes in line with Forward.Status
+		msg := deployForward(f, f, tun, j.JobID, rec)
+		if msg == "" && rec.errCount() > errsBefore {
+			msg = rec.failureSince(errsBefore)
+		}
+		res.OK, res.Error = msg == "", msg
+		jlog(map[string]interface{}{"event": "forward_redeploy", "tunnelId": tun.ID, "forwardId": f.ID, "jobId": j.JobID, "ok": res.OK, "error": msg})
+		redeployMu.Lock()
+		j.Forwards = append(j.Forwards, res)
+		j.Current++
+		if !res.OK {
+			j.Failed++
+		}
+		redeployMu.Unlock()
+	}
+	rec.flushRestarts(j.JobID)
+
+	nodes := rec.results()
+	for i := range nodes {
+		var n model.Node
+		if dbpkg.DB.Select("name").First(&n, nodes[i].NodeID).Error == nil {
+			nodes[i].NodeName = n.Name
+		}
+	}
+	redeployMu.Lock()
+	j.Nodes = nodes
+	j.Status = "done"
+	for _, n := range nodes {
+		if n.Failed > 0 {
+			j.Status, j.Error = "error", "部分节点下发失败"
+		}
+	}
+	if j.Failed > 0 {
+		j.Status, j.Error = "error", "部分转发下发失败"
+	}
+	redeployMu.Unlock()
+}
+
+// droppedHopServices lists, per node, the relay services a path change left
+// behind: tunnel-forward mids are named <service>_mid_<index>, port-forward hops
+// reuse the service name.
+func droppedHopServices(tun model.Tunnel, forwards []model.Forward, oldPath, newPath []int64) map[int64][]string {
+	keep := map[int64]map[string]bool{}
+	mark := func(nid int64, name string) {
+		if keep[nid] == nil {
+			keep[nid] = map[string]bool{}
+		}
+		keep[nid][name] = true
+	}
+	hopNames := func(f model.Forward, name string, i int) []string {
+		if tun.Type == 2 {
+			return []string{fmt.Sprintf("%s_mid_%d", name, i)}
+		}
+		return allNetworkServiceNames(name)
+	}
+	out := map[int64][]string{}
+	for _, f := range forwards {
+		name := buildServiceName(f.ID, f.UserID, f.TunnelID)
+		for i, nid := range newPath {
+			for _, n := range hopNames(f, name, i) {
+				mark(nid, n)
+			}
+		}
+		for i, nid := range oldPath {
+			// the entry and exit keep their own services
+			if nid == tun.InNodeID || nid == outNodeIDOr0(tun) {
+				continue
+			}
+			for _, n := range hopNames(f, name, i) {
+				if !keep[nid][n] {
+					out[nid] = append(out[nid], n)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// tunnelDeployFields are the tunnel fields rendered into forward services; name,
+// billing and ratio only matter to the panel.
+func tunnelDeployFields(t model.Tunnel) []any {
+	return []any{t.InNodeID, outNodeIDOr0(t), t.Type, transportOf(t), t.InterfaceName, t.TCPListenAddr, t.UDPListenAddr}
+}
+
+// redeployJobSnapshot copies a job for serialization.
+func redeployJobSnapshot(id string) (redeployJob, bool) {
+	redeployMu.Lock()
+	defer redeployMu.Unlock()
+	j := redeployJobs[id]
+	if j == nil {
+		return redeployJob{}, false
+	}
+	snap := *j
+	snap.Reasons = append([]string(nil), j.Reasons...)
+	snap.Forwards = append([]forwardDeployResult(nil), j.Forwards...)
+	snap.Nodes = append([]nodeDeployResult(nil), j.Nodes...)
+	return snap, true
+}
+
+// POST /api/v1/tunnel/redeploy {tunnelId}
+func TunnelRedeploy(c *gin.Context) {
+	var p struct {
+		TunnelID int64 `json:"tunnelId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	var t model.Tunnel
+	if err := dbpkg.DB.First(&t, p.TunnelID).Error; err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("隧道不存在"))
+		return
+	}
+	c.JSON(http.StatusOK, response.Ok(map[string]any{"jobId": scheduleTunnelRedeploy(t.ID, "manual", nil)}))
+}
+
+// POST /api/v1/tunnel/redeploy/status {jobId}
+func TunnelRedeployStatus(c *gin.Context) {
+	var p struct {
+		JobID string `json:"jobId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	snap, ok := redeployJobSnapshot(p.JobID)
+	if !ok {
+		c.JSON(http.StatusOK, response.ErrMsg("任务不存在"))
+		return
+	}
+	c.JSON(http.StatusOK, response.Ok(snap))
+}
@@ -0,0 +1,264 @@
+package controller
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"network-panel/golang-backend/internal/app/model"
+	dbpkg "network-panel/golang-backend/internal/db"
+)
+
+// Time-series retention: raw node_sysinfo / node_probe_result / node_netif_sample rows
+// are rolled up into 1-minute and 1-hour buckets and pruned once they are older than
+// their tier's retention. Readers pick the finest tier whose retention still covers
+// the window.
+
+const (
+	resMinute = 60
+	resHour   = 3600
+)
+
+type retentionPolicy struct {
+	RawMs    int64 // raw samples
+	MinuteMs int64 // 1-minute buckets
+	HourMs   int64 // 1-hour buckets, 0 keeps them forever
+}
+
+// readRetention reads the retention policy from vite_config:
+//   - metrics_raw_retention_hours   (default 24)
+//   - metrics_minute_retention_days (default 7)
+//   - metrics_hour_retention_days   (default 365, 0 = keep forever)
+func readRetention() retentionPolicy {
+	const hourMs, dayMs = int64(3600 * 1000), int64(24 * 3600 * 1000)
+	raw := getConfigInt("metrics_raw_retention_hours", 24)
+	if raw <= 0 {
+		raw = 24
+	}
+	minute := getConfigInt("metrics_minute_retention_days", 7)
+	if minute <= 0 {
+		minute = 7
+	}
+	hour := getConfigInt("metrics_hour_retention_days", 365)
+	if hour < 0 {
+		hour = 0
+	}
+	p := retentionPolicy{RawMs: int64(raw) * hourMs, MinuteMs: int64(minute) * dayMs, HourMs: int64(hour) * dayMs}
+	// a coarser tier never expires before a finer one
+	if p.MinuteMs < p.RawMs {
+		p.MinuteMs = p.RawMs
+	}
+	if p.HourMs > 0 && p.HourMs < p.MinuteMs {
+		p.HourMs = p.MinuteMs
+	}
+	return p
+}
+
+// resolutionFor returns the bucket size in seconds to serve a window with (0 = raw).
+func (p retentionPolicy) resolutionFor(windowMs int64) int {
+	switch {
+	case windowMs <= p.RawMs:
+		return 0
+	case windowMs <= p.MinuteMs:
+		return resMinute
+	default:
+		return resHour
+	}
+}
+
+// sysSample is one sysinfo point: a raw row (N = 1) or a rollup bucket.
+type sysSample struct {
+	NodeID, TimeMs                   int64
+	N                                int
+	Uptime, BytesRx, BytesTx         int64
+	CPU, CPUMax, Mem, MemMax         float64
+	Load1, Load5, Load15             float64
+	TCPEstab, TCPTimeWait, Conntrack float64
+	ConntrackMax, GostRestarts       int64
+	DiskUsage                        float64
+}
+
+// probeSample is one probe point: a raw result (N = 1) or a rollup bucket. RTT fields
+// cover successful probes only and are nil when none succeeded.
+type probeSample struct {
+	NodeID, TargetID, TimeMs int64
+	N, OK                    int
+	RTTAvg                   *float64
+	RTTMin, RTTMax           *int
+}
+
+// loadSysSamples returns a node's sysinfo in [from, to) from the finest tier that still
+// covers from, topped up with raw rows newer than the last closed bucket.
+func loadSysSamples(nodeID, from, to int64) ([]sysSample, int) {
+	res := readRetention().resolutionFor(time.Now().UnixMilli() - from)
+	out := make([]sysSample, 0)
+	rawFrom := from
+	if res > 0 {
+		var rows []model.NodeSysInfoRollup
+		dbpkg.DB.Where("node_id = ? AND res_s = ? AND time_ms >= ? AND time_ms < ?", nodeID, res, from, to).Order("time_ms asc").Find(&rows)
+		for _, r := range rows {
+			out = append(out, sysSample{NodeID: r.NodeID, TimeMs: r.TimeMs, N: r.Samples, Uptime: r.Uptime, BytesRx: r.BytesRx, BytesTx: r.BytesTx, CPU: r.CPU, CPUMax: r.CPUMax, Mem: r.Mem, MemMax: r.MemMax,
+				Load1: r.Load1, Load5: r.Load5, Load15: r.Load15, TCPEstab: r.TCPEstab, TCPTimeWait: r.TCPTimeWait, Conntrack: r.ConntrackCount,
+				ConntrackMax: r.ConntrackMax, GostRestarts: r.GostRestarts, DiskUsage: r.DiskUsage})
+		}
+		if len(rows) > 0 {
+			rawFrom = max64(from, rows[len(rows)-1].TimeMs+int64(res)*1000)
+		}
+	}
+	if rawFrom < to {
+		var rows []model.NodeSysInfo
+		dbpkg.DB.Where("node_id = ? AND time_ms >= ? AND time_ms < ?", nodeID, rawFrom, to).Order("time_ms asc").Find(&rows)
+		for _, r := range rows {
+			out = append(out, sysSample{NodeID: r.NodeID, TimeMs: r.TimeMs, N: 1, Uptime: r.Uptime, BytesRx: r.BytesRx, BytesTx: r.BytesTx, CPU: r.CPU, CPUMax: r.CPU, Mem: r.Mem, MemMax: r.Mem,
+				Load1: r.Load1, Load5: r.Load5, Load15: r.Load15, TCPEstab: float64(r.TCPEstab), TCPTimeWait: float64(r.TCPTimeWait), Conntrack: float64(r.ConntrackCount),
+				ConntrackMax: r.ConntrackMax, GostRestarts: r.GostRestarts, DiskUsage: r.DiskUsage})
+		}
+	}
+	return out, res
+}
+
+// loadProbeSamples returns probe results in [from, to) (nodeID 0 = all nodes), picking
+// the tier like loadSysSamples. Raw rows are only added after the last closed bucket
+// of their node/target so nothing is counted twice.
+func loadProbeSamples(nodeID, from, to int64) ([]probeSample, int) {
+	res := readRetention().resolutionFor(time.Now().UnixMilli() - from)
+	out := make([]probeSample, 0)
+	type key struct{ node, target int64 }
+	covered := map[key]int64{}
+	rawFrom := from
+	if res > 0 {
+		q := dbpkg.DB.Where("res_s = ? AND time_ms >= ? AND time_ms < ?", res, from, to)
+		if nodeID > 0 {
+			q = q.Where("node_id = ?", nodeID)
+		}
+		var rows []model.NodeProbeRollup
+		q.Order("time_ms asc").Find(&rows)
+		for _, r := range rows {
+			out = append(out, probeSample{NodeID: r.NodeID, TargetID: r.TargetID, TimeMs: r.TimeMs, N: r.Samples, OK: r.OKCount, RTTAvg: r.RTTAvg, RTTMin: r.RTTMin, RTTMax: r.RTTMax})
+			covered[key{r.NodeID, r.TargetID}] = r.TimeMs + int64(res)*1000
+		}
+		if len(covered) > 0 {
+			rawFrom = to
+			for _, end := range covered {
+				rawFrom = min64(rawFrom, end)
+			}
+		}
+	}
+	if rawFrom < to {
+		q := dbpkg.DB.Where("time_ms >= ? AND time_ms < ?", rawFrom, to)
+		if nodeID > 0 {
+			q = q.Where("node_id = ?", nodeID)
+		}
+		var rows []model.NodeProbeResult
+		q.Order("time_ms asc").Find(&rows)
+		for _, r := range rows {
+			if r.TimeMs < covered[key{r.NodeID, r.TargetID}] {
+				continue
+			}
+			it := probeSample{NodeID: r.NodeID, TargetID: r.TargetID, TimeMs: r.TimeMs, N: 1, OK: r.OK}
+			if r.OK == 1 && r.RTTMs > 0 {
+				v, rtt := float64(r.RTTMs), r.RTTMs
+				it.RTTAvg, it.RTTMin, it.RTTMax = &v, &rtt, &rtt
+			}
+			out = append(out, it)
+		}
+	}
+	return out, res
+}
+
+// rollupSpec describes how one raw table is aggregated into its rollup table.
+type rollupSpec struct {
+	raw, table string
+	keys       string // grouping columns besides the bucket
+	cols       string // aggregated columns
+	fromRaw    string // aggregates over raw rows, matching cols
+	fromMinute string // aggregates over minute buckets, matching cols
+}
+
+var rollupSpecs = []rollupSpec{
+	{
+		raw: "node_sysinfo", table: "node_sysinfo_rollup", keys: "node_id",
+		cols: "samples, uptime, bytes_rx, bytes_tx, cpu, cpu_max, mem, mem_max, " +
+			"load1, load5, load15, tcp_estab, tcp_time_wait, conntrack_count, conntrack_max, disk_usage, gost_restarts",
+		fromRaw: "COUNT(*), MAX(uptime), MAX(bytes_rx), MAX(bytes_tx), AVG(cpu), MAX(cpu), AVG(mem), MAX(mem), " +
+			"AVG(load1), AVG(load5), AVG(load15), AVG(tcp_estab), AVG(tcp_time_wait), AVG(conntrack_count), MAX(conntrack_max), MAX(disk_usage), MAX(gost_restarts)",
+		fromMinute: "SUM(samples), MAX(uptime), MAX(bytes_rx), MAX(bytes_tx), SUM(cpu * samples) / SUM(samples), MAX(cpu_max), SUM(mem * samples) / SUM(samples), MAX(mem_max), " +
+			"SUM(load1 * samples) / SUM(samples), SUM(load5 * samples) / SUM(samples), SUM(load15 * samples) / SUM(samples), " +
+			"SUM(tcp_estab * samples) / SUM(samples), SUM(tcp_time_wait * samples) / SUM(samples), SUM(conntrack_count * samples) / SUM(samples), " +
+			"MAX(conntrack_max), MAX(disk_usage), MAX(gost_restarts)",
+	},
+	{
+		raw: "node_probe_result", table: "node_probe_rollup", keys: "node_id, target_id",
+		cols: "samples, ok_count, rtt_avg, rtt_min, rtt_max",
+		fromRaw: "COUNT(*), SUM(CASE WHEN ok = 1 THEN 1 ELSE 0 END), " +
+			"AVG(CASE WHEN ok = 1 AND rtt_ms > 0 THEN rtt_ms END), MIN(CASE WHEN ok = 1 AND rtt_ms > 0 THEN rtt_ms END), MAX(CASE WHEN ok = 1 AND rtt_ms > 0 THEN rtt_ms END)",
+		fromMinute: "SUM(samples), SUM(ok_count), SUM(rtt_avg * ok_count) / NULLIF(SUM(ok_count), 0), MIN(rtt_min), MAX(rtt_max)",
+	},
+	{
+		raw: "node_netif_sample", table: "node_netif_rollup", keys: "node_id, iface",
+		cols:       "interval_ms, rx_bytes, tx_bytes, rx_bps_max, tx_bps_max",
+		fromRaw:    "SUM(interval_ms), SUM(rx_bytes), SUM(tx_bytes), MAX(rx_bps), MAX(tx_bps)",
+		fromMinute: "SUM(interval_ms), SUM(rx_bytes), SUM(tx_bytes), MAX(rx_bps_max), MAX(tx_bps_max)",
+	},
+}
+
+// DownsampleMetrics rolls raw samples up into the minute and hour tiers and prunes
+// rows past their retention. Raw and minute rows are only deleted once the next tier
+// covers them, so a stalled rollup never loses data.
+func DownsampleMetrics() {
+	p := readRetention()
+	now := time.Now().UnixMilli()
+	// leave a couple of minutes for late probe reports before closing a bucket
+	settled := now - 2*60*1000
+	for _, s := range rollupSpecs {
+		minuteDone := s.rollup(resMinute, settled)
+		hourDone := s.rollup(resHour, minuteDone)
+		s.prune("DELETE FROM `"+s.raw+"` WHERE time_ms < ?", min64(now-p.RawMs, minuteDone))
+		s.prune("DELETE FROM `"+s.table+"` WHERE res_s = 60 AND time_ms < ?", min64(now-p.MinuteMs, hourDone))
+		if p.HourMs > 0 {
+			s.prune("DELETE FROM `"+s.table+"` WHERE res_s = 3600 AND time_ms < ?", now-p.HourMs)
+		}
+	}
+}
+
+// rollup aggregates closed buckets of size res (seconds) before until and returns the
+// time up to which the tier is complete. Minute buckets are built from raw rows,
+// hour buckets from minute buckets.
+func (s rollupSpec) rollup(res int, until int64) int64 {
+	bucket := int64(res) * 1000
+	end := until - until%bucket
+	src, aggs, srcWhere := s.raw, s.fromRaw, ""
+	if res == resHour {
+		src, aggs, srcWhere = s.table, s.fromMinute, " AND res_s = 60"
+	}
+	var last sql.NullInt64
+	dbpkg.DB.Raw("SELECT MAX(time_ms) FROM `"+s.table+"` WHERE res_s = ?", res).Scan(&last)
+	start := last.Int64 + bucket
+	if !last.Valid {
+		var first sql.NullInt64
+		dbpkg.DB.Raw("SELECT MIN(time_ms) FROM `" + src + "` WHERE 1 = 1" + srcWhere).Scan(&first)
+		if !first.Valid {
+			return end
+		}
+		start = first.Int64 - first.Int64%bucket
+	}
+	bucketExpr := fmt.Sprintf("time_ms - (time_ms %% %d)", bucket)
+	// chunk by day so a first run over a large backlog stays manageable
+	const chunk = int64(24 * 3600 * 1000)
+	for from := start; from < end; from += chunk {
+		to := min64(from+chunk, end)
+		stmt := fmt.Sprintf("INSERT INTO `%s` (%s, res_s, time_ms, %s) SELECT %s, %d, %s, %s FROM `%s` WHERE time_ms >= ? AND time_ms < ?%s GROUP BY %s, %s",
+			s.table, s.keys, s.cols, s.keys, res, bucketExpr, aggs, src, srcWhere, s.keys, bucketExpr)
+		if err := dbpkg.DB.Exec(stmt, from, to).Error; err != nil {
+			jlog(map[string]interface{}{"event": "metrics_rollup_error", "table": s.table, "res": res, "from": from, "error": err.Error()})
+			return from
+		}
+	}
+	return max64(start, end)
+}
+
+func (s rollupSpec) prune(stmt string, before int64) {
+	if err := dbpkg.DB.Exec(stmt, before).Error; err != nil {
+		jlog(map[string]interface{}{"event": "metrics_prune_error", "table": s.table, "error": err.Error()})
+	}
+}
@@ -0,0 +1,216 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Setting types stored in vite_config.
+const (
+	settingString = "string"
+	settingInt    = "int"
+	settingBool   = "bool"
+	settingEnum   = "enum"
+	settingURL    = "url"
+	settingJSON   = "json"
+)
+
+// settingDef describes a vite_config key. Keys ending in '*' match a prefix
+// (per-tunnel rows). Public keys are served to anonymous callers (login page,
+// layout); everything else is admin only. Managed keys are written by their
+// own endpoints and rejected by ConfigUpdate.
+type settingDef struct {
+	Key     string   `json:"key"`
+	Type    string   `json:"type"`
+	Default string   `json:"default,omitempty"`
+	Public  bool     `json:"public"`
+	Secret  bool     `json:"secret,omitempty"`
+	Managed bool     `json:"managed,omitempty"`
+	Enum    []string `json:"enum,omitempty"`
+	Min     *int     `json:"min,omitempty"`
+	Max     *int     `json:"max,omitempty"`
+	Desc    string   `json:"desc"`
+}
+
+func intp(n int) *int { return &n }
+
+var settingDefs = []settingDef{
+	// site / UI
+	{Key: "app_name", Type: settingString, Public: true, Desc: "网站名称"},
+	{Key: "captcha_enabled", Type: settingBool, Default: "false", Public: true, Desc: "登录验证码"},
+	{Key: "captcha_type", Type: settingEnum, Public: true, Enum: []string{"", "RANDOM", "SLIDER", "ROTATE", "CONCAT", "WORD_IMAGE_CLICK"}, Desc: "验证码类型"},
+	{Key: "show_probe", Type: settingBool, Default: "false", Public: true, Desc: "显示探针页面"},
+	{Key: "show_network", Type: settingBool, Default: "false", Public: true, Desc: "显示网络页面"},
+	{Key: "poll_interval_sec", Type: settingInt, Default: "3", Public: true, Min: intp(1), Max: intp(3600), Desc: "前端轮询间隔（秒）"},
+
+	// panel address and flow reporting
+	{Key: "ip", Type: settingString, Desc: "面板对外地址 host:port，可带 https:// 前缀"},
+	{Key: "forward_observer_template", Type: settingString, Desc: "流量上报 observer 地址模板，占位符 {SCHEME} {SERVER} {SECRET}"},
+	{Key: "forward_observer_plugin_template", Type: settingString, Desc: "流量上报插件地址模板，占位符 {SCHEME} {SERVER} {SECRET} {ID}"},
+	{Key: "forward_observer_plugin_type", Type: settingEnum, Enum: []string{"", "http", "grpc"}, Desc: "流量上报插件类型，默认 http"},
+
+	// multi-target forwards
+	{Key: "forward_max_fails", Type: settingInt, Default: "1", Min: intp(1), Max: intp(100), Desc: "多目标转发：目标连续失败次数达到后暂时剔除"},
+	{Key: "forward_fail_timeout_sec", Type: settingInt, Default: "30", Min: intp(1), Max: intp(3600), Desc: "多目标转发：失败目标剔除时长（秒）"},
+
+	// service deployment
+	{Key: "deploy_ack_timeout_sec", Type: settingInt, Default: "10", Min: intp(1), Max: intp(120), Desc: "服务下发等待节点确认的超时（秒）"},
+	{Key: "drift_auto_fix", Type: settingBool, Default: "true", Desc: "自动修复节点服务漂移"},
+	{Key: "drift_fix_max", Type: settingInt, Default: "20", Min: intp(1), Max: intp(1000), Desc: "单次自动修复的服务数上限（超出需手动修复）"},
+
+	// legacy event callback
+	{Key: "callback_url", Type: settingURL, Secret: true, Desc: "事件回调地址"},
+	{Key: "callback_method", Type: settingEnum, Enum: []string{"", "GET", "POST"}, Desc: "事件回调方法，默认 GET"},
+	{Key: "callback_headers", Type: settingJSON, Secret: true, Desc: "事件回调请求头 JSON 对象"},
+	{Key: "callback_template", Type: settingString, Desc: "事件回调 POST 正文模板"},
+
+	// diagnosis
+	{Key: "diag_local_probe_timeout_ms", Type: settingInt, Min: intp(1), Desc: "本地探测超时（毫秒）"},
+	{Key: "diag_local_probe_timeout_s", Type: settingInt, Min: intp(1), Desc: "本地探测超时（秒）"},
+	{Key: "diag_local_probe_timeout", Type: settingInt, Min: intp(1), Desc: "本地探测超时（秒），兼容旧键"},
+
+	// retention
+	{Key: "metrics_raw_retention_hours", Type: settingInt, Default: "24", Min: intp(1), Desc: "原始指标保留小时数"},
+	{Key: "metrics_minute_retention_days", Type: settingInt, Default: "7", Min: intp(1), Desc: "分钟聚合保留天数"},
+	{Key: "metrics_hour_retention_days", Type: settingInt, Default: "365", Min: intp(0), Desc: "小时聚合保留天数，0 为永久"},
+	{Key: "notify_delivery_retention_days", Type: settingInt, Default: "30", Min: intp(1), Desc: "通知发送记录保留天数"},
+	{Key: "service_push_retention_days", Type: settingInt, Default: "30", Min: intp(1), Desc: "服务下发记录保留天数"},
+
+	// EasyTier
+	{Key: "easytier_install_timeout_sec", Type: settingInt, Default: "420", Min: intp(30), Max: intp(3600), Desc: "EasyTier 安装超时（秒）"},
+	{Key: etEnabledKey, Type: settingBool, Managed: true, Desc: "EasyTier 已启用"},
+	{Key: etSecretKey, Type: settingString, Managed: true, Secret: true, Desc: "EasyTier 网络密钥"},
+	{Key: etMasterKey, Type: settingJSON, Managed: true, Desc: "EasyTier 主控节点"},
+	{Key: etNodesKey, Type: settingJSON, Managed: true, Desc: "EasyTier 成员节点"},
+
+	// per-tunnel rows
+	{Key: "tunnel_path_*", Type: settingJSON, Managed: true, Desc: "隧道多级路径"},
+	{Key: "tunnel_iface_*", Type: settingJSON, Managed: true, Desc: "隧道出口网卡"},
+	{Key: "tunnel_bindip_*", Type: settingJSON, Managed: true, Desc: "隧道绑定 IP"},
+}
+
+// lookupSetting finds the definition for a key, exact matches first.
+func lookupSetting(name string) (settingDef, bool) {
+	for _, d := range settingDefs {
+		if d.Key == name {
+			return d, true
+		}
+	}
+	for _, d := range settingDefs {
+		if p, ok := strings.CutSuffix(d.Key, "*"); ok && strings.HasPrefix(name, p) {
+			return d, true
+		}
+	}
+	return settingDef{}, false
+}
+
+// settingDefault returns the registered default for a key, "" if none.
+func settingDefault(name string) string {
+	d, _ := lookupSetting(name)
+	return d.Default
+}
+
+// settingVisible reports whether a key may be shown to the caller; unknown
+// (legacy) rows are admin only.
+func settingVisible(name string, admin bool) bool {
+	if admin {
+		return true
+	}
+	d, ok := lookupSetting(name)
+	return ok && d.Public
+}
+
+// isAdminCaller reports whether the request carries an admin token; the route
+// must run AuthOptional or RequireRole first.
+func isAdminCaller(c *gin.Context) bool {
+	v, ok := c.Get("role_id")
+	if !ok {
+		return false
+	}
+	role, _ := v.(int)
+	return role == 0
+}
+
+// validate checks a value for this setting; an empty value resets to the default.
+func (d settingDef) validate(v string) error {
+	if v == "" {
+		return nil
+	}
+	switch d.Type {
+	case settingInt:
+		s := v
+		// getConfigInt accepts a trailing 's' for seconds
+		if strings.HasSuffix(strings.ToLower(s), "s") {
+			s = strings.TrimSpace(s[:len(s)-1])
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("需要整数")
+		}
+		if d.Min != nil && n < *d.Min {
+			return fmt.Errorf("不能小于 %d", *d.Min)
+		}
+		if d.Max != nil && n > *d.Max {
+			return fmt.Errorf("不能大于 %d", *d.Max)
+		}
+	case settingBool:
+		if v != "true" && v != "false" {
+			return fmt.Errorf("需要 true 或 false")
+		}
+	case settingEnum:
+		var opts []string
+		for _, e := range d.Enum {
+			if v == e {
+				return nil
+			}
+			if e != "" {
+				opts = append(opts, e)
+			}
+		}
+		return fmt.Errorf("可选值 %s", strings.Join(opts, ", "))
+	case settingURL:
+		u, err := url.Parse(v)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("需要 http(s) 地址")
+		}
+	case settingJSON:
+		if !json.Valid([]byte(v)) {
+			return fmt.Errorf("需要合法的 JSON")
+		}
+	}
+	return nil
+}
+
+// validateSettingUpdate checks a ConfigUpdate batch; the returned message names
+// the first offending key, "" when everything is valid. Values equal to the
+// stored ones are not checked, so the settings page can post back every row it
+// loaded, managed and legacy keys included.
+func validateSettingUpdate(m map[string]string, stored map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if old, ok := stored[k]; ok && old == m[k] {
+			continue
+		}
+		d, ok := lookupSetting(k)
+		if !ok {
+			return "未知配置项: " + k
+		}
+		if d.Managed {
+			return "配置项 " + k + " 由专用接口维护"
+		}
+		if err := d.validate(strings.TrimSpace(m[k])); err != nil {
+			return "配置项 " + k + " " + err.Error()
+		}
+	}
+	return ""
+}
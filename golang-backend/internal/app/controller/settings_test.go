@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"testing"
+
+	"network-panel/golang-backend/internal/app/model"
+	dbpkg "network-panel/golang-backend/internal/db"
+)
+
+func TestEmptySettingReadsDefault(t *testing.T) {
+	useTestDB(t, &model.ViteConfig{})
+	const key = "forward_max_fails"
+	writeSetting(key, "5")
+	if got := getConfigString(key); got != "5" {
+		t.Fatalf("stored value = %q, want 5", got)
+	}
+	writeSetting(key, "")
+	var n int64
+	dbpkg.DB.Model(&model.ViteConfig{}).Where("name = ?", key).Count(&n)
+	if n != 0 {
+		t.Errorf("empty value kept %d rows, want the row deleted", n)
+	}
+	// rows left empty by earlier versions read as the default too
+	dbpkg.DB.Create(&model.ViteConfig{Name: key, Value: ""})
+	if got, want := getConfigString(key), settingDefault(key); got != want {
+		t.Errorf("getConfigString = %q, want default %q", got, want)
+	}
+	if got, want := getCfg(key), settingDefault(key); got != want {
+		t.Errorf("getCfg = %q, want default %q", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"network-panel/golang-backend/internal/app/model"
+	dbpkg "network-panel/golang-backend/internal/db"
+)
+
+// shuttingDown is set once the server starts a graceful shutdown: new websocket
+// connections are refused and closing agent connections is not treated as the
+// node going offline (no disconnect log, alert or callback).
+var shuttingDown atomic.Bool
+
+// BeginShutdown stops accepting websocket connections.
+func BeginShutdown() { shuttingDown.Store(true) }
+
+// CloseConnections sends a close frame to every agent and admin websocket, marks
+// the connected nodes offline and releases requests still waiting for agent replies.
+func CloseConnections() {
+	shuttingDown.Store(true)
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	deadline := time.Now().Add(2 * time.Second)
+
+	nodeConnMu.Lock()
+	ids := make([]int64, 0, len(nodeConns))
+	for id, list := range nodeConns {
+		ids = append(ids, id)
+		for _, nc := range list {
+			if nc != nil && nc.c != nil {
+				_ = nc.c.WriteControl(websocket.CloseMessage, msg, deadline)
+				_ = nc.c.Close()
+			}
+		}
+	}
+	nodeConns = map[int64][]*nodeConn{}
+	nodeConnMu.Unlock()
+	if len(ids) > 0 {
+		dbpkg.DB.Model(&model.Node{}).Where("id IN ?", ids).Update("status", 0)
+	}
+
+	adminMu.Lock()
+	for c := range adminConns {
+		_ = c.WriteControl(websocket.CloseMessage, msg, deadline)
+		_ = c.Close()
+	}
+	adminConns = map[*websocket.Conn]struct{}{}
+	adminMu.Unlock()
+
+	released := releaseWaiters(&diagMu, diagWaiters) + releaseWaiters(&opMu, opWaiters) + releaseServiceAcks()
+	jlog(map[string]interface{}{"event": "connections_closed", "nodes": len(ids), "waitersReleased": released})
+}
+
+// releaseWaiters answers every pending waiter with a failure result.
+func releaseWaiters(mu interface {
+	Lock()
+	Unlock()
+}, waiters map[string]chan map[string]interface{}) int {
+	mu.Lock()
+	defer mu.Unlock()
+	n := 0
+	for id, ch := range waiters {
+		select {
+		case ch <- map[string]interface{}{"type": "Shutdown", "requestId": id, "data": map[string]interface{}{"success": false, "message": "server shutting down"}}:
+		default:
+		}
+		delete(waiters, id)
+		n++
+	}
+	return n
+}
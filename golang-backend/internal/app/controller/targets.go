@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"network-panel/golang-backend/internal/app/dto"
+	"network-panel/golang-backend/internal/app/model"
+)
+
+// forwardTarget is one remote of a forward. Forward.RemoteAddr stores the list
+// comma separated, each entry "host:port" with an optional "#weight" suffix.
+type forwardTarget struct {
+	Addr   string `json:"addr"`
+	Weight int    `json:"weight,omitempty"`
+}
+
+const maxTargetWeight = 100
+
+// gost selector strategies; aliases accepted from the API map onto them.
+var forwardStrategies = map[string]string{
+	"fifo": "fifo", "round": "round", "rand": "rand", "hash": "hash",
+	"round-robin": "round", "roundrobin": "round", "rr": "round", "random": "rand",
+}
+
+// parseTargets splits a stored remote address list; malformed weights count as 1.
+func parseTargets(remote string) []forwardTarget {
+	var out []forwardTarget
+	for _, part := range strings.FieldsFunc(remote, func(r rune) bool { return r == ',' || r == '\n' }) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		t := forwardTarget{Addr: part, Weight: 1}
+		if addr, w, ok := strings.Cut(part, "#"); ok {
+			t.Addr = strings.TrimSpace(addr)
+			if n, err := strconv.Atoi(strings.TrimSpace(w)); err == nil && n > 0 {
+				t.Weight = n
+			}
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// formatTargets is the inverse of parseTargets; weight 1 is omitted.
+func formatTargets(ts []forwardTarget) string {
+	parts := make([]string, 0, len(ts))
+	for _, t := range ts {
+		if t.Weight > 1 {
+			parts = append(parts, fmt.Sprintf("%s#%d", t.Addr, t.Weight))
+		} else {
+			parts = append(parts, t.Addr)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// normalizeTargets validates the remote targets of a create/update request,
+// taken from targets when given, else from remoteAddr, and returns the stored
+// form; "" with no error means no targets were supplied.
+func normalizeTargets(remoteAddr string, targets []dto.ForwardTargetDto) (string, string) {
+	var ts []forwardTarget
+	if len(targets) > 0 {
+		for _, t := range targets {
+			w := 1
+			if t.Weight != nil {
+				w = *t.Weight
+			}
+			ts = append(ts, forwardTarget{Addr: strings.TrimSpace(t.Addr), Weight: w})
+		}
+	} else {
+		for _, part := range strings.FieldsFunc(remoteAddr, func(r rune) bool { return r == ',' || r == '\n' }) {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			t := forwardTarget{Addr: part, Weight: 1}
+			if addr, w, ok := strings.Cut(part, "#"); ok {
+				n, err := strconv.Atoi(strings.TrimSpace(w))
+				if err != nil {
+					return "", "目标地址权重无效: " + part
+				}
+				t.Addr, t.Weight = strings.TrimSpace(addr), n
+			}
+			ts = append(ts, t)
+		}
+	}
+	for _, t := range ts {
+		host, port, err := net.SplitHostPort(t.Addr)
+		if err != nil || host == "" {
+			return "", "目标地址格式无效: " + t.Addr
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			return "", "目标地址端口无效: " + t.Addr
+		}
+		if t.Weight < 1 || t.Weight > maxTargetWeight {
+			return "", fmt.Sprintf("目标地址权重需在 1-%d 之间: %s", maxTargetWeight, t.Addr)
+		}
+	}
+	return formatTargets(ts), ""
+}
+
+// normalizeStrategy maps a requested load-balancing strategy onto a gost
+// selector strategy; nil or empty means fifo.
+func normalizeStrategy(s *string) (*string, bool) {
+	if s == nil || strings.TrimSpace(*s) == "" {
+		return nil, true
+	}
+	v, ok := forwardStrategies[strings.ToLower(strings.TrimSpace(*s))]
+	if !ok {
+		return nil, false
+	}
+	return &v, true
+}
+
+// targetForwarder builds the gost forwarder for a forward's remote targets. With
+// more than one target the nodes get a selector using the forward's strategy and
+// failure tracking: a node failing maxFails times is skipped for failTimeout.
+func targetForwarder(f model.Forward) map[string]any {
+	ts := parseTargets(f.RemoteAddr)
+	if len(ts) <= 1 {
+		return map[string]any{"nodes": []map[string]any{{"name": "target", "addr": firstTargetHost(f.RemoteAddr)}}}
+	}
+	nodes := make([]map[string]any, 0, len(ts))
+	for i, t := range ts {
+		n := map[string]any{"name": fmt.Sprintf("target-%d", i), "addr": t.Addr}
+		if t.Weight > 1 {
+			n["metadata"] = map[string]any{"weight": t.Weight}
+		}
+		nodes = append(nodes, n)
+	}
+	strategy := "fifo"
+	if s, ok := normalizeStrategy(f.Strategy); ok && s != nil {
+		strategy = *s
+	}
+	return map[string]any{
+		"nodes": nodes,
+		"selector": map[string]any{
+			"strategy":    strategy,
+			"maxFails":    getConfigInt("forward_max_fails", 1),
+			"failTimeout": fmt.Sprintf("%ds", getConfigInt("forward_fail_timeout_sec", 30)),
+		},
+	}
+}
+
+// buildTargetServiceConfig is buildServiceConfig forwarding to the forward's
+// remote targets.
+func buildTargetServiceConfig(name string, listenPort int, f model.Forward, iface *string) map[string]any {
+	svc := buildServiceConfig(name, listenPort, firstTargetHost(f.RemoteAddr), iface)
+	svc["forwarder"] = targetForwarder(f)
+	return svc
+}
@@ -0,0 +1,237 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"network-panel/golang-backend/internal/app/model"
+	"network-panel/golang-backend/internal/app/response"
+	dbpkg "network-panel/golang-backend/internal/db"
+	"network-panel/golang-backend/internal/logging"
+)
+
+// servicePushCmds are the service mutations persisted as node_service_push rows.
+var servicePushCmds = map[string]bool{"AddService": true, "UpdateService": true, "DeleteService": true, "PauseService": true, "ResumeService": true}
+
+const servicePushPayloadMax = 8 << 10
+
+// recordServicePush stores a service mutation sent (or failed to send) to a node.
+// The payload is kept with credentials redacted; a payload larger than
+// servicePushPayloadMax is replaced by its size.
+func recordServicePush(nodeID int64, cmdType string, data interface{}, sendErr error) {
+	b, _ := json.Marshal(data)
+	rec := model.NodeServicePush{TimeMs: time.Now().UnixMilli(), NodeID: nodeID, Cmd: cmdType, Services: strings.Join(pushServiceNames(b), ","), Success: 1}
+	if sendErr != nil {
+		rec.Success = 0
+		rec.Error = sendErr.Error()
+	}
+	size := len(b)
+	b, _ = json.Marshal(logging.Redact(data))
+	if len(b) > servicePushPayloadMax {
+		b, _ = json.Marshal(map[string]any{"truncated": true, "size": size})
+	}
+	rec.Payload = string(b)
+	_ = dbpkg.DB.Create(&rec).Error
+}
+
+// PruneServicePushes drops push records older than service_push_retention_days (default 30).
+func PruneServicePushes() {
+	days := getConfigInt("service_push_retention_days", 30)
+	if days <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-time.Duration(days) * 24 * time.Hour).UnixMilli()
+	dbpkg.DB.Where("time_ms < ?", cutoff).Delete(&model.NodeServicePush{})
+}
+
+// pushServiceNames extracts service names from either a list of service configs
+// or a {services: [names]} payload.
+func pushServiceNames(b []byte) []string {
+	var list []struct {
+		Name string `json:"name"`
+	}
+	if json.Unmarshal(b, &list) == nil {
+		names := make([]string, 0, len(list))
+		for _, s := range list {
+			if s.Name != "" {
+				names = append(names, s.Name)
+			}
+		}
+		return names
+	}
+	var obj struct {
+		Services []string `json:"services"`
+	}
+	_ = json.Unmarshal(b, &obj)
+	return obj.Services
+}
+
+// timelineEvent is one entry of a node's incident timeline.
+type timelineEvent struct {
+	TimeMs  int64          `json:"timeMs"`
+	Type    string         `json:"type"` // offline, online, op, alert, service_push
+	Summary string         `json:"summary"`
+	Details map[string]any `json:"details"`
+}
+
+// timelineSource loads the newest limit events of one kind in [from, to) and counts them.
+type timelineSource struct {
+	typ  string
+	load func(nodeID, from, to int64, limit int) ([]timelineEvent, int64)
+}
+
+var timelineSources = []timelineSource{
+	{"offline", func(nodeID, from, to int64, limit int) ([]timelineEvent, int64) {
+		q := dbpkg.DB.Model(&model.NodeDisconnectLog{}).Where("node_id = ? AND down_at_ms >= ? AND down_at_ms < ?", nodeID, from, to)
+		var total int64
+		q.Count(&total)
+		var rows []model.NodeDisconnectLog
+		q.Order("down_at_ms desc").Limit(limit).Find(&rows)
+		out := make([]timelineEvent, 0, len(rows))
+		for _, r := range rows {
+			out = append(out, timelineEvent{TimeMs: r.DownAtMs, Type: "offline", Summary: "节点离线",
+				Details: map[string]any{"id": r.ID, "upAtMs": r.UpAtMs, "durationS": r.DurationS}})
+		}
+		return out, total
+	}},
+	{"online", func(nodeID, from, to int64, limit int) ([]timelineEvent, int64) {
+		q := dbpkg.DB.Model(&model.NodeDisconnectLog{}).Where("node_id = ? AND up_at_ms IS NOT NULL AND up_at_ms >= ? AND up_at_ms < ?", nodeID, from, to)
+		var total int64
+		q.Count(&total)
+		var rows []model.NodeDisconnectLog
+		q.Order("up_at_ms desc").Limit(limit).Find(&rows)
+		out := make([]timelineEvent, 0, len(rows))
+		for _, r := range rows {
+			var dur int64
+			if r.DurationS != nil {
+				dur = *r.DurationS
+			}
+			out = append(out, timelineEvent{TimeMs: *r.UpAtMs, Type: "online", Summary: fmt.Sprintf("节点恢复上线，离线 %ds", dur),
+				Details: map[string]any{"id": r.ID, "downAtMs": r.DownAtMs, "durationS": r.DurationS}})
+		}
+		return out, total
+	}},
+	{"op", func(nodeID, from, to int64, limit int) ([]timelineEvent, int64) {
+		q := dbpkg.DB.Model(&model.NodeOpLog{}).Where("node_id = ? AND time_ms >= ? AND time_ms < ?", nodeID, from, to)
+		var total int64
+		q.Count(&total)
+		var rows []model.NodeOpLog
+		q.Order("time_ms desc, id desc").Limit(limit).Find(&rows)
+		out := make([]timelineEvent, 0, len(rows))
+		for _, r := range rows {
+			summary := r.Cmd
+			if r.Success != 1 {
+				summary += "（失败）"
+			}
+			if r.Message != "" {
+				summary += " " + truncateRunes(r.Message, 120)
+			}
+			out = append(out, timelineEvent{TimeMs: r.TimeMs, Type: "op", Summary: summary,
+				Details: map[string]any{"id": r.ID, "cmd": r.Cmd, "requestId": r.RequestID, "success": r.Success, "message": r.Message, "stdout": r.Stdout, "stderr": r.Stderr}})
+		}
+		return out, total
+	}},
+	{"alert", func(nodeID, from, to int64, limit int) ([]timelineEvent, int64) {
+		q := dbpkg.DB.Model(&model.Alert{}).Where("node_id = ? AND time_ms >= ? AND time_ms < ?", nodeID, from, to)
+		var total int64
+		q.Count(&total)
+		var rows []model.Alert
+		q.Order("time_ms desc, id desc").Limit(limit).Find(&rows)
+		out := make([]timelineEvent, 0, len(rows))
+		for _, r := range rows {
+			out = append(out, timelineEvent{TimeMs: r.TimeMs, Type: "alert", Summary: r.Message,
+				Details: map[string]any{"id": r.ID, "alertType": r.Type, "severity": r.Severity, "state": r.State, "ruleId": r.RuleID, "ackBy": r.AckBy, "resolvedAtMs": r.ResolvedAtMs}})
+		}
+		return out, total
+	}},
+	{"service_push", func(nodeID, from, to int64, limit int) ([]timelineEvent, int64) {
+		q := dbpkg.DB.Model(&model.NodeServicePush{}).Where("node_id = ? AND time_ms >= ? AND time_ms < ?", nodeID, from, to)
+		var total int64
+		q.Count(&total)
+		var rows []model.NodeServicePush
+		q.Order("time_ms desc, id desc").Limit(limit).Find(&rows)
+		out := make([]timelineEvent, 0, len(rows))
+		for _, r := range rows {
+			summary := r.Cmd
+			if r.Services != "" {
+				summary += " " + truncateRunes(r.Services, 120)
+			}
+			if r.Success != 1 {
+				summary += "（发送失败）"
+			}
+			out = append(out, timelineEvent{TimeMs: r.TimeMs, Type: "service_push", Summary: summary,
+				Details: map[string]any{"id": r.ID, "cmd": r.Cmd, "services": r.Services, "success": r.Success, "error": r.Error, "payload": r.Payload}})
+		}
+		return out, total
+	}},
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
+
+// POST /api/v1/node/timeline {nodeId, types?, range | from,to, page?, size?}
+// Disconnects, operations, alerts and service pushes of a node, newest first.
+func NodeTimeline(c *gin.Context) {
+	var p struct {
+		NodeID int64    `json:"nodeId" binding:"required"`
+		Types  []string `json:"types"`
+		Page   int      `json:"page"`
+		Size   int      `json:"size"`
+		seriesParams
+	}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
+		return
+	}
+	if p.Range == "" && p.From == 0 {
+		p.Range = "7d"
+	}
+	from, to, ok := p.window()
+	if !ok {
+		c.JSON(http.StatusOK, response.ErrMsg("时间范围无效"))
+		return
+	}
+	if p.To <= 0 {
+		to++ // include events recorded in the current millisecond
+	}
+	if p.Page <= 0 {
+		p.Page = 1
+	}
+	if p.Size <= 0 || p.Size > 200 {
+		p.Size = 50
+	}
+	if p.Page*p.Size > 10000 {
+		c.JSON(http.StatusOK, response.ErrMsg("分页过深，请缩小时间范围"))
+		return
+	}
+	want := map[string]bool{}
+	for _, t := range p.Types {
+		want[t] = true
+	}
+	// each source contributes at most page*size newest rows; the merged order is exact up to that depth
+	limit := p.Page * p.Size
+	events := make([]timelineEvent, 0, limit)
+	var total int64
+	for _, src := range timelineSources {
+		if len(want) > 0 && !want[src.typ] {
+			continue
+		}
+		list, n := src.load(p.NodeID, from, to, limit)
+		events = append(events, list...)
+		total += n
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].TimeMs > events[j].TimeMs })
+	start := min((p.Page-1)*p.Size, len(events))
+	end := min(start+p.Size, len(events))
+	c.JSON(http.StatusOK, response.Ok(map[string]any{"list": events[start:end], "total": total, "from": from, "to": to}))
+}
@@ -0,0 +1,409 @@
+package controller
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"network-panel/golang-backend/internal/app/model"
+	dbpkg "network-panel/golang-backend/internal/db"
+)
+
+// Range queries for sysinfo/probe series, shared by the admin and share endpoints.
+// Samples come from loadSysSamples/loadProbeSamples (retention.go) and are folded into
+// at most `points` buckets with min/avg/max/p95 and loss percentages.
+
+const (
+	defaultSeriesPoints = 300
+	maxSeriesPoints     = 2000
+)
+
+// seriesParams are the common time-window fields. Explicit from/to (ms) win over
+// range (1h,6h,12h,1d,7d,30d); to defaults to now.
+type seriesParams struct {
+	Range  string `json:"range"`
+	From   int64  `json:"from"`
+	To     int64  `json:"to"`
+	Points int    `json:"points"`
+}
+
+func rangeWindowMs(r string) int64 {
+	switch r {
+	case "6h":
+		return 6 * 3600 * 1000
+	case "12h":
+		return 12 * 3600 * 1000
+	case "1d":
+		return 24 * 3600 * 1000
+	case "7d":
+		return 7 * 24 * 3600 * 1000
+	case "30d":
+		return 30 * 24 * 3600 * 1000
+	default:
+		return 3600 * 1000
+	}
+}
+
+// window resolves the request into [from, to); ok is false for an empty or inverted window.
+func (p seriesParams) window() (from, to int64, ok bool) {
+	to = p.To
+	if to <= 0 {
+		to = time.Now().UnixMilli()
+	}
+	from = p.From
+	if from <= 0 {
+		from = to - rangeWindowMs(p.Range)
+	}
+	return from, to, from < to
+}
+
+// bucketMs returns the bucket width for the window, never finer than the source tier.
+func (p seriesParams) bucketMs(from, to int64, res int) int64 {
+	n := p.Points
+	if n <= 0 {
+		n = defaultSeriesPoints
+	}
+	if n > maxSeriesPoints {
+		n = maxSeriesPoints
+	}
+	b := (to - from + int64(n) - 1) / int64(n)
+	if b < int64(res)*1000 {
+		b = int64(res) * 1000
+	}
+	if b < 1000 {
+		b = 1000
+	}
+	return b
+}
+
+// seriesStats summarises the values that fell into a bucket.
+type seriesStats struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+	P95 float64 `json:"p95"`
+}
+
+// statAcc accumulates samples; each sample may itself be a rollup carrying its own
+// min/max and a weight (number of underlying points). p95 is taken over sample values,
+// which is exact for raw rows and an approximation over rollups.
+type statAcc struct {
+	vals     []float64
+	sum      float64
+	weight   int
+	min, max float64
+}
+
+func (a *statAcc) add(v, lo, hi float64, w int) {
+	if w <= 0 {
+		return
+	}
+	if a.weight == 0 || lo < a.min {
+		a.min = lo
+	}
+	if a.weight == 0 || hi > a.max {
+		a.max = hi
+	}
+	a.vals = append(a.vals, v)
+	a.sum += v * float64(w)
+	a.weight += w
+}
+
+func (a *statAcc) empty() bool { return a.weight == 0 }
+
+func (a *statAcc) result() seriesStats {
+	if a.weight == 0 {
+		return seriesStats{}
+	}
+	sort.Float64s(a.vals)
+	// nearest-rank percentile
+	i := int(math.Ceil(0.95*float64(len(a.vals)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return seriesStats{Min: a.min, Avg: a.sum / float64(a.weight), Max: a.max, P95: a.vals[i]}
+}
+
+func (a *statAcc) addProbe(s probeSample) {
+	if s.RTTAvg == nil || s.OK <= 0 {
+		return
+	}
+	lo, hi := *s.RTTAvg, *s.RTTAvg
+	if s.RTTMin != nil {
+		lo = float64(*s.RTTMin)
+	}
+	if s.RTTMax != nil {
+		hi = float64(*s.RTTMax)
+	}
+	a.add(*s.RTTAvg, lo, hi, s.OK)
+}
+
+func lossPct(total, ok int) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return math.Round(float64(total-ok)*10000/float64(total)) / 100
+}
+
+// sysBucket keeps the NodeSysInfo field names (gauges are bucket averages, counters
+// the last value, disk usage and conntrack max the peak) so existing charts keep
+// working, plus per-bucket stats.
+type sysBucket struct {
+	NodeID         int64       `json:"nodeId"`
+	TimeMs         int64       `json:"timeMs"`
+	Samples        int         `json:"samples"`
+	Uptime         int64       `json:"uptime"`
+	BytesRx        int64       `json:"bytesRx"`
+	BytesTx        int64       `json:"bytesTx"`
+	CPU            float64     `json:"cpu"`
+	Mem            float64     `json:"mem"`
+	Load1          float64     `json:"load1"`
+	Load5          float64     `json:"load5"`
+	Load15         float64     `json:"load15"`
+	TCPEstab       float64     `json:"tcpEstab"`
+	TCPTimeWait    float64     `json:"tcpTimeWait"`
+	ConntrackCount float64     `json:"conntrackCount"`
+	ConntrackMax   int64       `json:"conntrackMax"`
+	DiskUsage      float64     `json:"diskUsage"`
+	GostRestarts   int64       `json:"gostRestarts"`
+	CPUStats       seriesStats `json:"cpuStats"`
+	MemStats       seriesStats `json:"memStats"`
+}
+
+// sysinfoSeries buckets a node's sysinfo over [from, to).
+func sysinfoSeries(nodeID, from, to int64, sp seriesParams) ([]sysBucket, int) {
+	samples, res := loadSysSamples(nodeID, from, to)
+	bms := sp.bucketMs(from, to, res)
+	out := make([]sysBucket, 0)
+	var cpu, mem statAcc
+	flush := func() {
+		if len(out) == 0 || cpu.empty() {
+			return
+		}
+		b := &out[len(out)-1]
+		b.CPUStats, b.MemStats = cpu.result(), mem.result()
+		b.CPU, b.Mem = b.CPUStats.Avg, b.MemStats.Avg
+		// remaining gauges were accumulated as weighted sums
+		n := float64(b.Samples)
+		b.Load1, b.Load5, b.Load15 = b.Load1/n, b.Load5/n, b.Load15/n
+		b.TCPEstab, b.TCPTimeWait, b.ConntrackCount = b.TCPEstab/n, b.TCPTimeWait/n, b.ConntrackCount/n
+		cpu, mem = statAcc{}, statAcc{}
+	}
+	for _, s := range samples {
+		start := from + (s.TimeMs-from)/bms*bms
+		if len(out) == 0 || out[len(out)-1].TimeMs != start {
+			flush()
+			out = append(out, sysBucket{NodeID: nodeID, TimeMs: start})
+		}
+		b := &out[len(out)-1]
+		b.Samples += s.N
+		b.Uptime, b.BytesRx, b.BytesTx, b.GostRestarts = s.Uptime, s.BytesRx, s.BytesTx, s.GostRestarts
+		w := float64(s.N)
+		b.Load1 += s.Load1 * w
+		b.Load5 += s.Load5 * w
+		b.Load15 += s.Load15 * w
+		b.TCPEstab += s.TCPEstab * w
+		b.TCPTimeWait += s.TCPTimeWait * w
+		b.ConntrackCount += s.Conntrack * w
+		b.ConntrackMax = max(b.ConntrackMax, s.ConntrackMax)
+		b.DiskUsage = max(b.DiskUsage, s.DiskUsage)
+		cpu.add(s.CPU, s.CPU, s.CPUMax, s.N)
+		mem.add(s.Mem, s.Mem, s.MemMax, s.N)
+	}
+	flush()
+	return out, res
+}
+
+// probeBucket keeps the NodeProbeResult field names (rttMs is the average RTT of
+// successful probes, ok is 1 if any succeeded) plus loss and RTT stats.
+type probeBucket struct {
+	NodeID   int64        `json:"nodeId"`
+	TargetID int64        `json:"targetId"`
+	TimeMs   int64        `json:"timeMs"`
+	Samples  int          `json:"samples"`
+	OKCount  int          `json:"okCount"`
+	OK       int          `json:"ok"`
+	LossPct  float64      `json:"lossPct"`
+	RTTMs    int          `json:"rttMs"`
+	RTT      *seriesStats `json:"rtt,omitempty"`
+}
+
+// probeSeries buckets probe results of one node per target over [from, to).
+func probeSeries(nodeID, from, to int64, sp seriesParams) ([]probeBucket, int) {
+	samples, res := loadProbeSamples(nodeID, from, to)
+	bms := sp.bucketMs(from, to, res)
+	type key struct{ target, start int64 }
+	type acc struct {
+		b   probeBucket
+		rtt statAcc
+	}
+	buckets := map[key]*acc{}
+	for _, s := range samples {
+		k := key{s.TargetID, from + (s.TimeMs-from)/bms*bms}
+		a := buckets[k]
+		if a == nil {
+			a = &acc{b: probeBucket{NodeID: s.NodeID, TargetID: s.TargetID, TimeMs: k.start}}
+			buckets[k] = a
+		}
+		a.b.Samples += s.N
+		a.b.OKCount += s.OK
+		a.rtt.addProbe(s)
+	}
+	out := make([]probeBucket, 0, len(buckets))
+	for _, a := range buckets {
+		b := a.b
+		b.LossPct = lossPct(b.Samples, b.OKCount)
+		if b.OKCount > 0 {
+			b.OK = 1
+		}
+		if !a.rtt.empty() {
+			st := a.rtt.result()
+			b.RTT = &st
+			b.RTTMs = int(math.Round(st.Avg))
+		}
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].TimeMs != out[j].TimeMs {
+			return out[i].TimeMs < out[j].TimeMs
+		}
+		return out[i].TargetID < out[j].TargetID
+	})
+	return out, res
+}
+
+// networkStats builds the per-node network-stats payload: bucketed probe results,
+// target metadata, disconnects and SLA over the window.
+func networkStats(nodeID int64, sp seriesParams) (map[string]any, bool) {
+	from, to, ok := sp.window()
+	if !ok {
+		return nil, false
+	}
+	results, res := probeSeries(nodeID, from, to, sp)
+
+	// collect target meta
+	m := map[int64]map[string]string{}
+	seen := map[int64]struct{}{}
+	targetIDs := make([]int64, 0)
+	for _, r := range results {
+		if _, ok := seen[r.TargetID]; !ok {
+			seen[r.TargetID] = struct{}{}
+			targetIDs = append(targetIDs, r.TargetID)
+		}
+	}
+	if len(targetIDs) > 0 {
+		var tgts []model.ProbeTarget
+		dbpkg.DB.Where("id IN ?", targetIDs).Find(&tgts)
+		for _, t := range tgts {
+			m[t.ID] = map[string]string{"name": t.Name, "ip": t.IP}
+		}
+	}
+
+	// disconnect logs
+	var logs []model.NodeDisconnectLog
+	dbpkg.DB.Where("node_id = ? AND down_at_ms < ? AND (up_at_ms IS NULL OR up_at_ms >= ?)", nodeID, to, from).Order("down_at_ms asc").Find(&logs)
+
+	// SLA: share of the window not intersecting any downtime
+	now := time.Now().UnixMilli()
+	var downMs int64
+	for _, l := range logs {
+		end := now
+		if l.UpAtMs != nil {
+			end = *l.UpAtMs
+		}
+		s := max64(l.DownAtMs, from)
+		e := min64(end, to)
+		if e > s {
+			downMs += e - s
+		}
+	}
+	windowMs := to - from
+	sla := float64(windowMs-downMs) / float64(windowMs)
+
+	// window totals
+	var total, okCnt int
+	for _, r := range results {
+		total += r.Samples
+		okCnt += r.OKCount
+	}
+
+	return map[string]any{
+		"results":     results,
+		"targets":     m,
+		"disconnects": logs,
+		"sla":         sla,
+		"lossPct":     lossPct(total, okCnt),
+		"from":        from,
+		"to":          to,
+		"resolution":  res,
+		"bucketMs":    sp.bucketMs(from, to, res),
+	}, true
+}
+
+// networkStatsBatch summarises every node over the window: average/min/max/p95 RTT,
+// loss, and the latest probe with its target.
+func networkStatsBatch(sp seriesParams) (map[int64]map[string]any, bool) {
+	from, to, ok := sp.window()
+	if !ok {
+		return nil, false
+	}
+	samples, _ := loadProbeSamples(0, from, to)
+	type stat struct {
+		total, ok    int
+		rtt          statAcc
+		latest       *int
+		latestTarget int64
+		latestMs     int64
+	}
+	agg := map[int64]*stat{}
+	for _, s := range samples {
+		st := agg[s.NodeID]
+		if st == nil {
+			st = &stat{}
+			agg[s.NodeID] = st
+		}
+		st.total += s.N
+		st.ok += s.OK
+		st.rtt.addProbe(s)
+		if s.TimeMs >= st.latestMs {
+			v := 0
+			if s.RTTAvg != nil {
+				v = int(math.Round(*s.RTTAvg))
+			}
+			st.latest, st.latestTarget, st.latestMs = &v, s.TargetID, s.TimeMs
+		}
+	}
+	// fetch target metas for latest target per node
+	tset := map[int64]struct{}{}
+	for _, s := range agg {
+		if s.latestTarget > 0 {
+			tset[s.latestTarget] = struct{}{}
+		}
+	}
+	tmeta := map[int64]map[string]string{}
+	if len(tset) > 0 {
+		ids := make([]int64, 0, len(tset))
+		for id := range tset {
+			ids = append(ids, id)
+		}
+		var tgts []model.ProbeTarget
+		dbpkg.DB.Where("id IN ?", ids).Find(&tgts)
+		for _, t := range tgts {
+			tmeta[t.ID] = map[string]string{"name": t.Name, "ip": t.IP}
+		}
+	}
+	out := map[int64]map[string]any{}
+	for nid, s := range agg {
+		item := map[string]any{"avg": nil, "latest": s.latest, "lossPct": lossPct(s.total, s.ok), "samples": s.total}
+		if !s.rtt.empty() {
+			r := s.rtt.result()
+			item["avg"] = int(math.Round(r.Avg))
+			item["min"], item["max"], item["p95"] = r.Min, r.Max, r.P95
+		}
+		if m, ok := tmeta[s.latestTarget]; ok {
+			item["latestTarget"] = map[string]any{"id": s.latestTarget, "name": m["name"], "ip": m["ip"]}
+		}
+		out[nid] = item
+	}
+	return out, true
+}
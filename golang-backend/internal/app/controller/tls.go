@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// panelTLS is set at startup when the server terminates TLS itself (cert files or
+// ACME); URLs handed to agents and install commands then use https/wss.
+var panelTLS atomic.Bool
+
+// SetPanelTLS records whether the panel serves HTTPS.
+func SetPanelTLS(on bool) { panelTLS.Store(on) }
+
+// panelScheme returns the scheme agents should use to reach the panel over HTTP:
+// https when the panel terminates TLS or the configured ip carries https:// (TLS
+// terminated by a reverse proxy), otherwise http.
+func panelScheme() string {
+	if panelTLS.Load() || strings.HasPrefix(strings.TrimSpace(getCfg("ip")), "https://") {
+		return "https"
+	}
+	return "http"
+}
+
+// panelWSScheme is the websocket counterpart of panelScheme.
+func panelWSScheme() string {
+	if panelScheme() == "https" {
+		return "wss"
+	}
+	return "ws"
+}
@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"encoding/json"
+	"strings"
+
+	"network-panel/golang-backend/internal/app/model"
+)
+
+// Tunnel transports for tunnel-forward (Tunnel.Protocol): the exit relay listens
+// with this gost listener and the entry chain dials with the matching dialer.
+// Empty means grpc, the transport used before the field was honored.
+const defaultTransport = "grpc"
+
+var tunnelTransports = map[string]bool{
+	"grpc": true, "tls": true, "mtls": true, "wss": true, "mwss": true,
+	"ws": true, "mws": true, "kcp": true, "quic": true, "tcp": true, "mtcp": true,
+}
+
+// transportOptions are the per-protocol settings stored as JSON in
+// Tunnel.TransportOpts.
+type transportOptions struct {
+	// TLS based transports (grpc, tls, mtls, wss, mwss, quic). Without cert files
+	// the exit uses gost's self-signed certificate (paths are on the exit node);
+	// Secure makes the entry verify the certificate against SNI, using CAFile (a
+	// path on the entry node) when given.
+	SNI      string `json:"sni,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	CAFile   string `json:"caFile,omitempty"`
+	// websocket transports (ws, wss, mws, mwss)
+	Path string `json:"path,omitempty"`
+	Host string `json:"host,omitempty"`
+	// gRPC: custom service path instead of the default one
+	GRPCPath string `json:"grpcPath,omitempty"`
+	// KCP: gost kcp config, e.g. {"mode":"fast","mtu":1350,"crypt":"aes","key":"..."}
+	KCP map[string]any `json:"kcp,omitempty"`
+}
+
+type tunnelTransport struct {
+	Protocol string
+	Opts     transportOptions
+}
+
+var kcpModes = map[string]bool{"normal": true, "fast": true, "fast2": true, "fast3": true, "manual": true}
+
+// normalizeTransport validates a protocol and its options from a tunnel request;
+// it returns the values to store and an error message ("" when valid).
+func normalizeTransport(protocol, opts *string) (*string, *string, string) {
+	var p *string
+	if protocol != nil && strings.TrimSpace(*protocol) != "" {
+		v := strings.ToLower(strings.TrimSpace(*protocol))
+		if !tunnelTransports[v] {
+			return nil, nil, "隧道协议无效: " + v
+		}
+		p = &v
+	}
+	if opts == nil || strings.TrimSpace(*opts) == "" {
+		return p, nil, ""
+	}
+	var o transportOptions
+	dec := json.NewDecoder(strings.NewReader(*opts))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&o); err != nil {
+		return nil, nil, "传输参数需为合法的 JSON: " + err.Error()
+	}
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return nil, nil, "传输参数 certFile 与 keyFile 需同时设置"
+	}
+	if m, ok := o.KCP["mode"].(string); ok && !kcpModes[m] {
+		return nil, nil, "KCP mode 可选值 normal, fast, fast2, fast3, manual"
+	}
+	b, _ := json.Marshal(o)
+	s := string(b)
+	if s == "{}" {
+		return p, nil, ""
+	}
+	return p, &s, ""
+}
+
+// transportOf returns a tunnel's transport; bad stored options are ignored.
+func transportOf(t model.Tunnel) tunnelTransport {
+	tr := tunnelTransport{Protocol: defaultTransport}
+	if t.Protocol != nil && tunnelTransports[*t.Protocol] {
+		tr.Protocol = *t.Protocol
+	}
+	if t.TransportOpts != nil && *t.TransportOpts != "" {
+		_ = json.Unmarshal([]byte(*t.TransportOpts), &tr.Opts)
+	}
+	return tr
+}
+
+func (tr tunnelTransport) usesTLS() bool {
+	switch tr.Protocol {
+	case "grpc", "tls", "mtls", "wss", "mwss", "quic":
+		return true
+	}
+	return false
+}
+
+func (tr tunnelTransport) usesWS() bool {
+	switch tr.Protocol {
+	case "ws", "wss", "mws", "mwss":
+		return true
+	}
+	return false
+}
+
+// overUDP reports whether the transport runs over UDP, in which case relays on
+// intermediate hops must forward UDP.
+func (tr tunnelTransport) overUDP() bool { return tr.Protocol == "kcp" || tr.Protocol == "quic" }
+
+// listener is the exit relay's gost listener.
+func (tr tunnelTransport) listener() map[string]any {
+	l := map[string]any{"type": tr.Protocol}
+	md := map[string]any{}
+	o := tr.Opts
+	if tr.usesTLS() && o.CertFile != "" {
+		l["tls"] = map[string]any{"certFile": o.CertFile, "keyFile": o.KeyFile}
+	}
+	if tr.usesWS() && o.Path != "" {
+		md["path"] = o.Path
+	}
+	if tr.Protocol == "grpc" && o.GRPCPath != "" {
+		md["path"] = o.GRPCPath
+	}
+	if tr.Protocol == "kcp" && len(o.KCP) > 0 {
+		md["config"] = o.KCP
+	}
+	if len(md) > 0 {
+		l["metadata"] = md
+	}
+	return l
+}
+
+// dialer is the entry chain's gost dialer towards the exit relay.
+func (tr tunnelTransport) dialer() map[string]any {
+	d := map[string]any{"type": tr.Protocol}
+	md := map[string]any{}
+	o := tr.Opts
+	if tr.usesTLS() && (o.SNI != "" || o.Secure || o.CAFile != "") {
+		tc := map[string]any{"secure": o.Secure}
+		if o.SNI != "" {
+			tc["serverName"] = o.SNI
+		}
+		if o.CAFile != "" {
+			tc["caFile"] = o.CAFile
+		}
+		d["tls"] = tc
+	}
+	if tr.usesWS() {
+		if o.Path != "" {
+			md["path"] = o.Path
+		}
+		if o.Host != "" {
+			md["host"] = o.Host
+		}
+	}
+	if tr.Protocol == "grpc" && o.GRPCPath != "" {
+		md["path"] = o.GRPCPath
+	}
+	if tr.Protocol == "kcp" && len(o.KCP) > 0 {
+		md["config"] = o.KCP
+	}
+	if len(md) > 0 {
+		d["metadata"] = md
+	}
+	return d
+}
+
+// midListener is the listener of the plain forwarders on intermediate hops that
+// carry the transport to the exit.
+func (tr tunnelTransport) midListener() map[string]any {
+	if tr.overUDP() {
+		return map[string]any{"type": "udp", "metadata": map[string]any{"keepalive": true, "ttl": "60s"}}
+	}
+	return map[string]any{"type": "tcp"}
+}
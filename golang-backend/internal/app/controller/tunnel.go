@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"reflect"
 	"strings"
 	"time"
 
@@ -23,6 +24,16 @@ func TunnelCreate(c *gin.Context) {
 		c.JSON(http.StatusOK, response.ErrMsg("参数错误"))
 		return
 	}
+	if !validBillingMode(req.Flow) {
+		c.JSON(http.StatusOK, response.ErrMsg("流量计算方式无效"))
+		return
+	}
+	proto, opts, msg := normalizeTransport(req.Protocol, req.TransportOpts)
+	if msg != "" {
+		c.JSON(http.StatusOK, response.ErrMsg(msg))
+		return
+	}
+	req.Protocol, req.TransportOpts = proto, opts
 	// unique name
 	var cnt int64
 	db.DB.Model(&model.Tunnel{}).Where("name = ?", req.Name).Count(&cnt)
@@ -41,7 +52,7 @@ func TunnelCreate(c *gin.Context) {
 	status := 1
 	t := model.Tunnel{BaseEntity: model.BaseEntity{CreatedTime: now, UpdatedTime: now, Status: &status},
 		Name: req.Name, InNodeID: req.InNodeID, InIP: in.IP, Type: req.Type, Flow: req.Flow,
-		Protocol: req.Protocol, TrafficRatio: req.TrafficRatio, TCPListenAddr: req.TCPListenAddr, UDPListenAddr: req.UDPListenAddr, InterfaceName: req.InterfaceName,
+		Protocol: req.Protocol, TransportOpts: req.TransportOpts, TrafficRatio: req.TrafficRatio, TCPListenAddr: req.TCPListenAddr, UDPListenAddr: req.UDPListenAddr, InterfaceName: req.InterfaceName,
 	}
 	if req.OutNodeID != nil {
 		var out model.Node
@@ -76,6 +87,15 @@ func TunnelUpdate(c *gin.Context) {
 		c.JSON(http.StatusOK, response.ErrMsg("隧道不存在"))
 		return
 	}
+	if !validBillingMode(int(req.Flow)) {
+		c.JSON(http.StatusOK, response.ErrMsg("流量计算方式无效"))
+		return
+	}
+	proto, opts, msg := normalizeTransport(req.Protocol, req.TransportOpts)
+	if msg != "" {
+		c.JSON(http.StatusOK, response.ErrMsg(msg))
+		return
+	}
 	// name unique
 	var cnt int64
 	db.DB.Model(&model.Tunnel{}).Where("name = ? AND id <> ?", req.Name, req.ID).Count(&cnt)
@@ -83,14 +103,21 @@ func TunnelUpdate(c *gin.Context) {
 		c.JSON(http.StatusOK, response.ErrMsg("隧道名称已存在"))
 		return
 	}
+	oldDeploy := tunnelDeployFields(t)
 	t.Name = req.Name
 	t.Flow = int(req.Flow)
-	t.TCPListenAddr, t.UDPListenAddr, t.Protocol, t.InterfaceName, t.TrafficRatio = req.TCPListenAddr, req.UDPListenAddr, req.Protocol, req.InterfaceName, req.TrafficRatio
+	t.TCPListenAddr, t.UDPListenAddr, t.Protocol, t.InterfaceName, t.TrafficRatio = req.TCPListenAddr, req.UDPListenAddr, proto, req.InterfaceName, req.TrafficRatio
+	t.TransportOpts = opts
 	t.UpdatedTime = time.Now().UnixMilli()
 	if err := db.DB.Save(&t).Error; err != nil {
 		c.JSON(http.StatusOK, response.ErrMsg("隧道更新失败"))
 		return
 	}
+	// services already deployed for the tunnel's forwards are re-rendered when a field they use changed
+	if !reflect.DeepEqual(oldDeploy, tunnelDeployFields(t)) {
+		c.JSON(http.StatusOK, response.Ok(map[string]any{"jobId": scheduleTunnelRedeploy(t.ID, "tunnel_update", nil)}))
+		return
+	}
 	c.JSON(http.StatusOK, response.OkMsg("隧道更新成功"))
 }
 
@@ -197,6 +224,7 @@ func TunnelUserRemove(c *gin.Context) {
 		c.JSON(http.StatusOK, response.ErrMsg("未找到对应的用户隧道权限记录"))
 		return
 	}
+	db.DB.Where("forward_id IN (?)", db.DB.Model(&model.Forward{}).Select("id").Where("user_id = ? and tunnel_id = ?", ut.UserID, ut.TunnelID)).Delete(&model.ForwardHop{})
 	db.DB.Where("user_id = ? and tunnel_id = ?", ut.UserID, ut.TunnelID).Delete(&model.Forward{})
 	db.DB.Delete(&ut)
 	c.JSON(http.StatusOK, response.OkMsg("用户隧道权限删除成功"))
@@ -3,6 +3,7 @@ package controller
 import (
     "encoding/json"
     "net/http"
+    "reflect"
     "strconv"
 
     "github.com/gin-gonic/gin"
@@ -31,6 +32,7 @@ func TunnelBindSet(c *gin.Context) {
     if err := c.ShouldBindJSON(&p); err != nil { c.JSON(http.StatusOK, response.ErrMsg("参数错误")); return }
     m := map[int64]string{}
     for _, it := range p.Binds { if it.NodeID > 0 { m[it.NodeID] = it.IP } }
+    changed := !reflect.DeepEqual(getTunnelBindMap(p.TunnelID), m)
     b, _ := json.Marshal(m)
     key := tunnelBindKey(p.TunnelID)
     var cfg model.ViteConfig
@@ -40,6 +42,10 @@ func TunnelBindSet(c *gin.Context) {
     } else {
         _ = dbpkg.DB.Create(&model.ViteConfig{Name: key, Value: string(b)}).Error
     }
+    if changed {
+        c.JSON(http.StatusOK, response.Ok(map[string]any{"jobId": scheduleTunnelRedeploy(p.TunnelID, "tunnel_bind", nil)}))
+        return
+    }
     c.JSON(http.StatusOK, response.OkMsg("已保存"))
 }
 
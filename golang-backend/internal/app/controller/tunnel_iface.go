@@ -3,6 +3,7 @@ package controller
 import (
     "encoding/json"
     "net/http"
+    "reflect"
     "strconv"
 
     "github.com/gin-gonic/gin"
@@ -32,6 +33,7 @@ func TunnelIfaceSet(c *gin.Context) {
         if it.NodeID <= 0 { continue }
         m[it.NodeID] = it.IP // empty allowed (means unset)
     }
+    changed := !reflect.DeepEqual(getTunnelIfaceMap(p.TunnelID), m)
     b, _ := json.Marshal(m)
     key := tunnelIfaceKey(p.TunnelID)
     var cfg model.ViteConfig
@@ -41,6 +43,10 @@ func TunnelIfaceSet(c *gin.Context) {
     } else {
         _ = dbpkg.DB.Create(&model.ViteConfig{Name: key, Value: string(b)}).Error
     }
+    if changed {
+        c.JSON(http.StatusOK, response.Ok(map[string]any{"jobId": scheduleTunnelRedeploy(p.TunnelID, "tunnel_iface", nil)}))
+        return
+    }
     c.JSON(http.StatusOK, response.OkMsg("已保存"))
 }
 
@@ -49,6 +49,7 @@ func TunnelPathSet(c *gin.Context) {
             seen[id] = struct{}{}
         }
     }
+    oldPath := getTunnelPathNodes(p.TunnelID)
     // persist to ViteConfig
     b, _ := json.Marshal(uniq)
     key := tunnelPathKey(p.TunnelID)
@@ -61,19 +62,20 @@ func TunnelPathSet(c *gin.Context) {
     } else {
         _ = dbpkg.DB.Create(&model.ViteConfig{Name: key, Value: string(b), Time: now}).Error
     }
-    // After saving path, best-effort restart gost on affected nodes so配置立即生效（入口+中间+出口）
-    var t model.Tunnel
-    _ = dbpkg.DB.First(&t, p.TunnelID).Error
-    nodes := make([]int64, 0, 2+len(uniq))
-    nodes = append(nodes, t.InNodeID)
-    nodes = append(nodes, uniq...)
-    if t.OutNodeID != nil { nodes = append(nodes, *t.OutNodeID) }
-    restarted := 0
-    for _, nid := range nodes {
-        if nid <= 0 { continue }
-        if err := sendWSCommand(nid, "RestartGost", map[string]any{"reason":"path_set"}); err == nil { restarted++ }
+    // push the forwards again so entry chains, mid relays and exits follow the new path
+    jobID := ""
+    if !equalPath(oldPath, uniq) {
+        jobID = scheduleTunnelRedeploy(p.TunnelID, "tunnel_path", oldPath)
+    }
+    c.JSON(http.StatusOK, response.Ok(map[string]any{"saved": len(uniq), "jobId": jobID}))
+}
+
+func equalPath(a, b []int64) bool {
+    if len(a) != len(b) { return false }
+    for i := range a {
+        if a[i] != b[i] { return false }
     }
-    c.JSON(http.StatusOK, response.Ok(map[string]any{"saved": len(uniq), "restarted": restarted}))
+    return true
 }
 
 func tunnelPathKey(tid int64) string { return "tunnel_path_" + strconv.FormatInt(tid, 10) }
@@ -115,7 +117,7 @@ func TunnelPathCheck(c *gin.Context) {
         if !online { allOK = false }
         // collect relay presence via QueryServices
         services := queryNodeServicesRaw(nid)
-        relayGrpc := false
+        relayGrpc := false // relay listening with the tunnel transport (field name kept for the UI)
         usedPorts := map[int]bool{}
         for _, s := range services {
             if v, ok := s["addr"].(string); ok {
@@ -124,7 +126,7 @@ func TunnelPathCheck(c *gin.Context) {
             if h, ok := s["handler"].(map[string]any); ok {
                 if typ, _ := h["type"].(string); typ == "relay" {
                     if lst, ok2 := s["listener"].(map[string]any); ok2 {
-                        if lt, _ := lst["type"].(string); lt == "grpc" { relayGrpc = true }
+                        if lt, _ := lst["type"].(string); lt == transportOf(t).Protocol { relayGrpc = true }
                     }
                 }
             }
@@ -86,15 +86,9 @@ func UserCreate(c *gin.Context) {
 func UserList(c *gin.Context) {
     var users []model.User
     dbpkg.DB.Where("role_id <> ?", 0).Find(&users)
-    // compute usedBilled per user: sum over forwards with tunnel.flow rule (single uses max(in,out), double uses in+out)
-    type agg struct{ UserID int64; Used int64 }
-    var aggs []agg
-    dbpkg.DB.Table("forward f").
-        Select("f.user_id as user_id, SUM(CASE WHEN t.flow = 1 THEN (CASE WHEN f.in_flow > f.out_flow THEN f.in_flow ELSE f.out_flow END) ELSE (f.in_flow + f.out_flow) END) as used").
-        Joins("left join tunnel t on t.id = f.tunnel_id").
-        Group("f.user_id").Scan(&aggs)
+    // usedBilled: user counters are charged in billed bytes (see accounting.go)
     usedMap := map[int64]int64{}
-    for _, a := range aggs { usedMap[a.UserID] = a.Used }
+    for _, u := range users { usedMap[u.ID] = billedUsed(u.InFlow, u.OutFlow) }
 
     // normalize to camelCase for frontend consistency
     out := make([]map[string]any, 0, len(users))
@@ -186,6 +180,7 @@ func UserDelete(c *gin.Context) {
 		return
 	}
 	// cascade deletions: forward, user_tunnel, statistics_flow (best-effort)
+	dbpkg.DB.Where("forward_id IN (?)", dbpkg.DB.Model(&model.Forward{}).Select("id").Where("user_id = ?", p.ID)).Delete(&model.ForwardHop{})
 	dbpkg.DB.Where("user_id = ?", p.ID).Delete(&model.Forward{})
 	dbpkg.DB.Where("user_id = ?", p.ID).Delete(&model.UserTunnel{})
 	dbpkg.DB.Where("user_id = ?", p.ID).Delete(&model.StatisticsFlow{})
@@ -213,14 +208,6 @@ func UserPackage(c *gin.Context) {
 	}
 
     // build userInfo payload (camelCase)
-    // compute billed used (sum over forwards by tunnel.flow rule)
-    type agg struct{ Used int64 }
-    var a agg
-    dbpkg.DB.Table("forward f").
-        Select("SUM(CASE WHEN t.flow = 1 THEN (CASE WHEN f.in_flow > f.out_flow THEN f.in_flow ELSE f.out_flow END) ELSE (f.in_flow + f.out_flow) END) as used").
-        Joins("left join tunnel t on t.id = f.tunnel_id").
-        Where("f.user_id = ?", uid).Scan(&a)
-
     userInfo := gin.H{
         "flow":          user.Flow,
         "inFlow":        user.InFlow,
@@ -228,7 +215,7 @@ func UserPackage(c *gin.Context) {
         "num":           user.Num,
         "expTime":       user.ExpTime,
         "flowResetTime": user.FlowResetTime,
-        "usedBilled":    a.Used,
+        "usedBilled":    billedUsed(user.InFlow, user.OutFlow),
     }
 
 	// tunnel permissions with names and tunnelFlow
@@ -1,10 +1,13 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
 	"log"
+	"log/slog"
 	"net/http"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -20,10 +23,35 @@ import (
 	"gorm.io/gorm/clause"
 )
 
-// jlog emits structured JSON logs for easier tracing
+// jlog emits a structured log record; m["event"] is the message. Per-message
+// traffic (ws_send, op_send, ...) is logged at debug level, *_err / *_failed
+// events as warnings. Sensitive fields are redacted by the logging handler.
 func jlog(m map[string]interface{}) {
-	b, _ := json.Marshal(m)
-	log.Print(string(b))
+	event, _ := m["event"].(string)
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if k != "event" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, slog.Any(k, m[k]))
+	}
+	slog.LogAttrs(context.Background(), jlogLevel(event), event, attrs...)
+}
+
+var jlogDebugEvents = map[string]bool{"ws_send": true, "op_send": true, "op_recv": true}
+
+func jlogLevel(event string) slog.Level {
+	switch {
+	case jlogDebugEvents[event]:
+		return slog.LevelDebug
+	case strings.HasSuffix(event, "_err"), strings.HasSuffix(event, "_error"), strings.HasSuffix(event, "_failed"):
+		return slog.LevelWarn
+	}
+	return slog.LevelInfo
 }
 
 var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
@@ -53,6 +81,10 @@ func SystemInfoWS(c *gin.Context) {
     version := c.Query("version")
     role := c.Query("role") // agent1 or agent2 (optional)
 
+	if shuttingDown.Load() {
+		c.String(http.StatusServiceUnavailable, "server shutting down")
+		return
+	}
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("websocket upgrade error: %v", err)
@@ -81,6 +113,24 @@ func SystemInfoWS(c *gin.Context) {
                         "bytes_transmitted": s.BytesTx,
                         "cpu_usage": s.CPU,
                         "memory_usage": s.Mem,
+                        "load1": s.Load1,
+                        "load5": s.Load5,
+                        "load15": s.Load15,
+                        "tcp_established": s.TCPEstab,
+                        "tcp_time_wait": s.TCPTimeWait,
+                        "conntrack_count": s.ConntrackCount,
+                        "conntrack_max": s.ConntrackMax,
+                        "gost_restarts": s.GostRestarts,
+                    }
+                    if s.GostRunning != nil {
+                        payload["gost_running"] = *s.GostRunning == 1
+                    }
+                    var rt model.NodeRuntime
+                    if err := dbpkg.DB.Where("node_id = ?", n.ID).First(&rt).Error; err == nil && rt.Disks != nil {
+                        var disks []interface{}
+                        if json.Unmarshal([]byte(*rt.Disks), &disks) == nil {
+                            payload["disks"] = disks
+                        }
                     }
                     b2, _ := json.Marshal(map[string]interface{}{"id": n.ID, "type": "info", "data": payload})
                     _ = c.WriteMessage(websocket.TextMessage, b2)
@@ -120,8 +170,11 @@ func SystemInfoWS(c *gin.Context) {
 			// alert online with downtime info
 			name := node.Name
 			nid := node.ID
-			_ = dbpkg.DB.Create(&model.Alert{TimeMs: now, Type: "online", NodeID: &nid, NodeName: &name, Message: "节点恢复上线，时长(s): " + fmt.Sprintf("%d", dur)}).Error
+			auto := "auto"
+			_ = dbpkg.DB.Create(&model.Alert{TimeMs: now, Type: "online", NodeID: &nid, NodeName: &name, Message: "节点恢复上线，时长(s): " + fmt.Sprintf("%d", dur), State: "resolved", ResolvedAtMs: &now, ResolvedBy: &auto}).Error
 		}
+		// coming back online ends the node's offline alerts
+		autoResolveAlerts("node_id = ? AND type = ?", node.ID, "offline")
 
 		nodeConnMu.Lock()
 		nodeConns[node.ID] = append(nodeConns[node.ID], &nodeConn{c: conn, ver: version})
@@ -139,22 +192,29 @@ func SystemInfoWS(c *gin.Context) {
             _ = sendWSCommand(node.ID, "UpgradeAgent", map[string]any{"to": expected})
         }
 
-        // On reconnect: re-apply desired entry services (port-forward) with unified observer in case of drift
-        if svcs := desiredServices(node.ID); len(svcs) > 0 {
-            _ = sendWSCommand(node.ID, "AddService", svcs)
-            jlog(map[string]interface{}{"event": "reapply_desired_services", "nodeId": node.ID, "count": len(svcs)})
-        }
-        if patches := BuildTunnelEntryObserverPatches(node.ID); len(patches) > 0 {
-            _ = sendWSCommand(node.ID, "UpdateService", patches)
-            jlog(map[string]interface{}{"event": "tunnel_entry_observer_patched", "nodeId": node.ID, "count": len(patches)})
-        }
-        // Restart gost after applying changes to ensure effect
-        _ = sendWSCommand(node.ID, "RestartGost", map[string]any{"reason": "agent_reconnect_apply"})
+        // On reconnect: re-apply everything the node should run (entries, mid hops, exit relays, exit SS; paused stay paused) in case of drift.
+        // Runs beside the read loop: backfilling hop ports may query this node's services.
+        go func(nodeID int64) {
+            BackfillForwardHops()
+            if svcs, _ := desiredServices(nodeID); len(svcs) > 0 {
+                _ = sendWSCommand(nodeID, "AddService", svcs)
+                jlog(map[string]interface{}{"event": "reapply_desired_services", "nodeId": nodeID, "count": len(svcs)})
+            }
+            // Restart gost after applying changes to ensure effect
+            _ = sendWSCommand(nodeID, "RestartGost", map[string]any{"reason": "agent_reconnect_apply"})
+            // then deliver what was queued while offline (pauses, deletes), which the desired state above may not reflect yet
+            replayNodeQueue(nodeID)
+        }(node.ID)
 
 		// read messages and forward system info
 		for {
 			mt, msg, err := conn.ReadMessage()
 			if err != nil {
+				if shuttingDown.Load() {
+					// closed by CloseConnections; the node is not really going offline
+					conn.Close()
+					return
+				}
 				// connection closed; update connection set
 				jlog(map[string]interface{}{"event": "node_disconnected", "nodeId": node.ID, "name": node.Name})
 				nodeConnMu.Lock()
@@ -184,7 +244,7 @@ func SystemInfoWS(c *gin.Context) {
 					// alert record
 					name := node.Name
 					nid := node.ID
-					_ = dbpkg.DB.Create(&model.Alert{TimeMs: now, Type: "offline", NodeID: &nid, NodeName: &name, Message: "节点离线"}).Error
+					_ = dbpkg.DB.Create(&model.Alert{TimeMs: now, Type: "offline", NodeID: &nid, NodeName: &name, Message: "节点离线", State: "open"}).Error
 				}
 				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
 					log.Printf("ws closed: %v", err)
@@ -241,6 +301,12 @@ func SystemInfoWS(c *gin.Context) {
                             continue
                         }
                     }
+                } else if ok && t == "ServiceResult" {
+                    reqID, _ := generic["requestId"].(string)
+                    if !deliverServiceAck(reqID, generic["data"]) {
+                        jlog(map[string]interface{}{"event": "service_ack_late", "nodeId": node.ID, "requestId": reqID, "cmd": generic["cmd"]})
+                    }
+                    continue
                 } else if ok && t == "OpLog" {
                     // Generic operation progress log from agent; persist for UI visibility
                     var nid int64 = node.ID
@@ -261,6 +327,10 @@ func SystemInfoWS(c *gin.Context) {
 			if payload != nil {
 				// store into DB for long-term charts
 				storeSysInfoSample(node.ID, payload)
+				if rates := recordNetIfCounters(node.ID, payload); len(rates) > 0 {
+					payload["net_rates"] = rates
+				}
+				delete(payload, "net_interfaces")
 				broadcastToAdmins(map[string]interface{}{"id": node.ID, "type": "info", "data": payload})
 			} else {
 				jlog(map[string]interface{}{"event": "node_non_json", "nodeId": node.ID, "len": len(msg)})
@@ -274,22 +344,33 @@ func SystemInfoWS(c *gin.Context) {
 }
 
 // sendWSCommand sends a command to a node by ID: {type: ..., data: ...}
-func sendWSCommand(nodeID int64, cmdType string, data interface{}) error {
+func sendWSCommand(nodeID int64, cmdType string, data interface{}) (err error) {
+	if servicePushCmds[cmdType] {
+		defer func() { recordServicePush(nodeID, cmdType, data, err) }()
+	}
+	return writeWSCommand(nodeID, cmdType, data, "")
+}
+
+// writeWSCommand writes a command to the node's connections; a non-empty reqID
+// is sent as the top-level requestId for the agent to reply with.
+func writeWSCommand(nodeID int64, cmdType string, data interface{}, reqID string) error {
 	nodeConnMu.RLock()
 	list := append([]*nodeConn(nil), nodeConns[nodeID]...)
 	nodeConnMu.RUnlock()
 	if len(list) == 0 {
-		return fmt.Errorf("node %d not connected", nodeID)
+		return fmt.Errorf("node %d %w", nodeID, errNodeOffline)
 	}
 	msg := make(map[string]interface{})
 	kind := reflect.TypeOf(data).Kind()
 	if kind != reflect.String && kind != reflect.Slice {
 		dataBytes, _ := json.Marshal(data)
 		msg = map[string]interface{}{"type": cmdType, "data": json.RawMessage(dataBytes)}
-		jlog(map[string]interface{}{"event": "测试输出", "msg": msg})
 	} else {
 		msg = map[string]interface{}{"type": cmdType, "data": data}
 	}
+	if reqID != "" {
+		msg["requestId"] = reqID
+	}
 	b, _ := json.Marshal(msg)
 
 	// Diagnose: target only agent (or any single fallback)
@@ -330,8 +411,14 @@ func sendWSCommand(nodeID int64, cmdType string, data interface{}) error {
 	return nil
 }
 
-// notifyCallback sends a simple callback to configured URL on events (GET or POST)
+// notifyCallback delivers an event to the notification channels and sends a simple
+// callback to the legacy callback_url (GET or POST) if configured
 func notifyCallback(event string, node model.Node, extra map[string]any) {
+	payload := map[string]any{"event": event, "nodeId": node.ID, "name": node.Name, "time": time.Now().UnixMilli()}
+	for k, v := range extra {
+		payload[k] = v
+	}
+	dispatchNotification(event, payload)
 	// read from vite_config
 	var urlC, methodC, hdrC, bodyTpl model.ViteConfig
 	dbpkg.DB.Where("name = ?", "callback_url").First(&urlC)
@@ -353,10 +440,6 @@ func notifyCallback(event string, node model.Node, extra map[string]any) {
 			headers = m
 		}
 	}
-	payload := map[string]any{"event": event, "nodeId": node.ID, "name": node.Name, "time": time.Now().UnixMilli()}
-	for k, v := range extra {
-		payload[k] = v
-	}
 	b, _ := json.Marshal(payload)
 
 	// apply template helpers
@@ -555,6 +638,25 @@ func convertSysInfoJSON(b []byte) map[string]interface{} {
     } else if v, ok := in["memory_usage"]; ok {
         out["memory_usage"] = v
     }
+    // load, TCP states, conntrack, disks and gost health
+    for _, k := range [][2]string{
+        {"Load1", "load1"}, {"Load5", "load5"}, {"Load15", "load15"},
+        {"TCPEstablished", "tcp_established"}, {"TCPTimeWait", "tcp_time_wait"},
+        {"ConntrackCount", "conntrack_count"}, {"ConntrackMax", "conntrack_max"},
+        {"Disks", "disks"}, {"GostRunning", "gost_running"}, {"GostRestarts", "gost_restarts"},
+    } {
+        if v, ok := in[k[0]]; ok {
+            out[k[1]] = v
+        } else if v, ok := in[k[1]]; ok {
+            out[k[1]] = v
+        }
+    }
+    // per-interface cumulative counters [{name, rx, tx}]
+    if v, ok := in["NetInterfaces"]; ok {
+        out["net_interfaces"] = v
+    } else if v, ok := in["net_interfaces"]; ok {
+        out["net_interfaces"] = v
+    }
     // interfaces list (array of IP strings)
     if v, ok := in["Interfaces"]; ok {
         out["interfaces"] = v
@@ -613,8 +715,41 @@ func storeSysInfoSample(nodeID int64, m map[string]interface{}) {
 		BytesTx: toInt64(m["bytes_transmitted"]),
 		CPU:     toFloat(m["cpu_usage"]),
 		Mem:     toFloat(m["memory_usage"]),
+		Load1:   toFloat(m["load1"]),
+		Load5:   toFloat(m["load5"]),
+		Load15:  toFloat(m["load15"]),
+
+		TCPEstab:       toInt64(m["tcp_established"]),
+		TCPTimeWait:    toInt64(m["tcp_time_wait"]),
+		ConntrackCount: toInt64(m["conntrack_count"]),
+		ConntrackMax:   toInt64(m["conntrack_max"]),
+		GostRestarts:   toInt64(m["gost_restarts"]),
+	}
+	if v, ok := m["gost_running"].(bool); ok {
+		r := 0
+		if v {
+			r = 1
+		}
+		s.GostRunning = &r
+	}
+	// keep the fullest mount in the series; the per-mount list goes to node_runtime
+	disks, _ := m["disks"].([]interface{})
+	for _, d := range disks {
+		if dm, ok := d.(map[string]interface{}); ok {
+			s.DiskUsage = max(s.DiskUsage, toFloat(dm["usage"]))
+		}
 	}
 	_ = dbpkg.DB.Create(&s).Error
+	if len(disks) > 0 {
+		if b, err := json.Marshal(disks); err == nil {
+			ds := string(b)
+			rec := model.NodeRuntime{NodeID: nodeID, Disks: &ds, UpdatedTime: now}
+			_ = dbpkg.DB.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "node_id"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{"disks": ds, "updated_time": now}),
+			}).Create(&rec).Error
+		}
+	}
 	// persist interfaces snapshot if provided
 	if ifs, ok := m["interfaces"]; ok && ifs != nil {
 		if b, err := json.Marshal(ifs); err == nil {
@@ -76,6 +76,7 @@ type TunnelDto struct {
     Type          int    `json:"type" binding:"required"`
     Flow          int    `json:"flow"`
     Protocol      *string `json:"protocol"`
+    TransportOpts *string `json:"transportOpts"`
     TrafficRatio  *float64 `json:"trafficRatio"`
     TCPListenAddr *string `json:"tcpListenAddr"`
     UDPListenAddr *string `json:"udpListenAddr"`
@@ -89,17 +90,28 @@ type TunnelUpdateDto struct {
     TCPListenAddr *string `json:"tcpListenAddr"`
     UDPListenAddr *string `json:"udpListenAddr"`
     Protocol      *string `json:"protocol"`
+    TransportOpts *string `json:"transportOpts"`
     InterfaceName *string `json:"interfaceName"`
     TrafficRatio  *float64 `json:"trafficRatio"`
 }
 
 // Forward
+// ForwardTargetDto is one remote target; weight defaults to 1
+type ForwardTargetDto struct {
+    Addr   string `json:"addr"`
+    Weight *int   `json:"weight"`
+}
+
+// RemoteAddr: comma separated host:port, each optionally suffixed with #weight;
+// Targets takes precedence when given. Strategy: fifo | round | rand | hash
 type ForwardDto struct {
     Name       string  `json:"name" binding:"required"`
     TunnelID   int64   `json:"tunnelId" binding:"required"`
     InPort     *int    `json:"inPort"`
-    RemoteAddr string  `json:"remoteAddr" binding:"required"`
+    RemoteAddr string  `json:"remoteAddr"`
+    Targets    []ForwardTargetDto `json:"targets"`
     Strategy   *string `json:"strategy"`
+    Protocol   *string `json:"protocol"` // tcp | udp | both
     InterfaceName *string `json:"interfaceName"`
     // SS 参数移除：统一在节点“出口服务”设置
 }
@@ -110,7 +122,9 @@ type ForwardUpdateDto struct {
     TunnelID   int64   `json:"tunnelId"`
     InPort     *int    `json:"inPort"`
     RemoteAddr string  `json:"remoteAddr"`
+    Targets    []ForwardTargetDto `json:"targets"`
     Strategy   *string `json:"strategy"`
+    Protocol   *string `json:"protocol"` // tcp | udp | both
     InterfaceName *string `json:"interfaceName"`
     // SS 参数移除：统一在节点“出口服务”设置
 }
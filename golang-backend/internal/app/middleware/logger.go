@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// high-frequency agent endpoints are only logged at debug level
+var quietPaths = map[string]bool{"/flow/upload": true, "/flow/config": true, "/api/v1/agent/report-probe": true, "/health": true}
+
+// RequestLogger logs one structured record per request (query strings are redacted
+// by the logging handler).
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		path := c.Request.URL.Path
+		status := c.Writer.Status()
+		lv := slog.LevelInfo
+		switch {
+		case status >= 500:
+			lv = slog.LevelError
+		case quietPaths[path] && status < 400:
+			lv = slog.LevelDebug
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.Int("status", status),
+			slog.Int64("latencyMs", time.Since(start).Milliseconds()),
+			slog.String("ip", c.ClientIP()),
+			slog.Int("size", c.Writer.Size()),
+		}
+		if q := c.Request.URL.RawQuery; q != "" {
+			attrs = append(attrs, slog.String("query", q))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		slog.LogAttrs(context.Background(), lv, "http_request", attrs...)
+	}
+}
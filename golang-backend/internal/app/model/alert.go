@@ -3,11 +3,65 @@ package model
 type Alert struct {
     ID          int64  `gorm:"primaryKey;column:id" json:"id"`
     TimeMs      int64  `gorm:"column:time_ms" json:"timeMs"`
-    Type        string `gorm:"column:type" json:"type"` // offline, online, due
+    Type        string `gorm:"column:type" json:"type"` // offline, online, due, rule, rule_resolved
     NodeID      *int64 `gorm:"column:node_id" json:"nodeId,omitempty"`
     NodeName    *string `gorm:"column:node_name" json:"nodeName,omitempty"`
     Message     string `gorm:"column:message" json:"message"`
+    Severity    string `gorm:"column:severity" json:"severity,omitempty"` // info, warning, critical (rule alerts)
+    RuleID      *int64 `gorm:"column:rule_id" json:"ruleId,omitempty"`
+    // State: open, acked, resolved. Informational records (online, rule_resolved) are created resolved.
+    State          string  `gorm:"column:state;size:16;index:idx_alert_state" json:"state"`
+    AckBy          *string `gorm:"column:ack_by" json:"ackBy,omitempty"`
+    AckComment     *string `gorm:"column:ack_comment" json:"ackComment,omitempty"`
+    AckAtMs        *int64  `gorm:"column:ack_at_ms" json:"ackAtMs,omitempty"`
+    ResolvedAtMs   *int64  `gorm:"column:resolved_at_ms" json:"resolvedAtMs,omitempty"`
+    ResolvedBy     *string `gorm:"column:resolved_by" json:"resolvedBy,omitempty"` // "auto" or the user name
+    ResolveComment *string `gorm:"column:resolve_comment" json:"resolveComment,omitempty"`
 }
 
 func (Alert) TableName() string { return "alert" }
 
+// AlertRule is a threshold rule evaluated periodically by the scheduler.
+// Metric: cpu, mem, disk, load1, conntrack (percent of max), probe_loss (percent),
+// offline (minutes), forward_idle (hours without traffic), user_quota (percent used).
+type AlertRule struct {
+    ID           int64    `gorm:"primaryKey;column:id" json:"id"`
+    CreatedTime  int64    `gorm:"column:created_time" json:"createdTime"`
+    UpdatedTime  int64    `gorm:"column:updated_time" json:"updatedTime"`
+    Status       int      `gorm:"column:status" json:"status"` // 1 enabled, 0 disabled
+    Name         string   `gorm:"column:name" json:"name"`
+    Metric       string   `gorm:"column:metric;size:32" json:"metric"`
+    Op           string   `gorm:"column:op;size:4" json:"op"` // > or <
+    Threshold    float64  `gorm:"column:threshold" json:"threshold"`
+    Recover      *float64 `gorm:"column:recover" json:"recover,omitempty"` // clear level (hysteresis), default threshold
+    ForS         int      `gorm:"column:for_s" json:"forS"` // condition must hold this long before firing
+    Severity     string   `gorm:"column:severity;size:16" json:"severity"`
+    NodeIDs      *string  `gorm:"column:node_ids" json:"nodeIds,omitempty"` // JSON array, empty = all nodes
+    TargetID     *int64   `gorm:"column:target_id" json:"targetId,omitempty"` // probe_loss only, empty = any target
+    SilenceFrom  string   `gorm:"column:silence_from;size:5" json:"silenceFrom"` // daily window HH:MM, may wrap midnight
+    SilenceTo    string   `gorm:"column:silence_to;size:5" json:"silenceTo"`
+    MutedUntilMs *int64   `gorm:"column:muted_until_ms" json:"mutedUntilMs,omitempty"`
+    RepeatS      int      `gorm:"column:repeat_s" json:"repeatS"` // re-notify interval while firing, 0 = once
+}
+
+func (AlertRule) TableName() string { return "alert_rule" }
+
+// AlertRuleState tracks one rule against one subject (node:1, node:1/target:2,
+// forward:3, user:4) so that an episode fires and notifies once.
+type AlertRuleState struct {
+    ID             int64   `gorm:"primaryKey;column:id" json:"id"`
+    RuleID         int64   `gorm:"column:rule_id;uniqueIndex:idx_alert_rule_state_subject,priority:1" json:"ruleId"`
+    Subject        string  `gorm:"column:subject;size:64;uniqueIndex:idx_alert_rule_state_subject,priority:2" json:"subject"`
+    NodeID         *int64  `gorm:"column:node_id" json:"nodeId,omitempty"`
+    Firing         int     `gorm:"column:firing" json:"firing"`
+    PendingSinceMs int64   `gorm:"column:pending_since_ms" json:"pendingSinceMs"`
+    FiredAtMs      int64   `gorm:"column:fired_at_ms" json:"firedAtMs"`
+    LastNotifyMs   int64   `gorm:"column:last_notify_ms" json:"lastNotifyMs"` // 0 = firing notification still owed (silenced)
+    AlertID        int64   `gorm:"column:alert_id" json:"alertId"`
+    LastValue      float64 `gorm:"column:last_value" json:"lastValue"`
+    Counter        int64   `gorm:"column:counter" json:"counter"` // forward_idle: last seen in+out bytes
+    CounterSinceMs int64   `gorm:"column:counter_since_ms" json:"counterSinceMs"`
+    UpdatedTime    int64   `gorm:"column:updated_time" json:"updatedTime"`
+}
+
+func (AlertRuleState) TableName() string { return "alert_rule_state" }
@@ -0,0 +1,79 @@
+package model
+
+// NodeSysInfoRollup is a downsampled NodeSysInfo bucket (ResS = 60 or 3600).
+// Counters (uptime, bytes) keep the bucket maximum, gauges are averaged.
+type NodeSysInfoRollup struct {
+    ID             int64   `gorm:"primaryKey;column:id" json:"id"`
+    NodeID         int64   `gorm:"column:node_id;index:idx_node_sysinfo_rollup_node,priority:1" json:"nodeId"`
+    ResS           int     `gorm:"column:res_s;index:idx_node_sysinfo_rollup_node,priority:2;index:idx_node_sysinfo_rollup_time,priority:1" json:"resS"`
+    TimeMs         int64   `gorm:"column:time_ms;index:idx_node_sysinfo_rollup_node,priority:3;index:idx_node_sysinfo_rollup_time,priority:2" json:"timeMs"` // bucket start
+    Samples        int     `gorm:"column:samples" json:"samples"`
+    Uptime         int64   `gorm:"column:uptime" json:"uptime"`
+    BytesRx        int64   `gorm:"column:bytes_rx" json:"bytesRx"`
+    BytesTx        int64   `gorm:"column:bytes_tx" json:"bytesTx"`
+    CPU            float64 `gorm:"column:cpu" json:"cpu"`
+    CPUMax         float64 `gorm:"column:cpu_max" json:"cpuMax"`
+    Mem            float64 `gorm:"column:mem" json:"mem"`
+    MemMax         float64 `gorm:"column:mem_max" json:"memMax"`
+    Load1          float64 `gorm:"column:load1" json:"load1"`
+    Load5          float64 `gorm:"column:load5" json:"load5"`
+    Load15         float64 `gorm:"column:load15" json:"load15"`
+    TCPEstab       float64 `gorm:"column:tcp_estab" json:"tcpEstab"`
+    TCPTimeWait    float64 `gorm:"column:tcp_time_wait" json:"tcpTimeWait"`
+    ConntrackCount float64 `gorm:"column:conntrack_count" json:"conntrackCount"`
+    ConntrackMax   int64   `gorm:"column:conntrack_max" json:"conntrackMax"`
+    DiskUsage      float64 `gorm:"column:disk_usage" json:"diskUsage"` // bucket maximum
+    GostRestarts   int64   `gorm:"column:gost_restarts" json:"gostRestarts"`
+}
+
+func (NodeSysInfoRollup) TableName() string { return "node_sysinfo_rollup" }
+
+// NodeProbeRollup is a downsampled NodeProbeResult bucket per node and target.
+// RTT fields only cover successful probes and are nil when none succeeded.
+type NodeProbeRollup struct {
+    ID       int64    `gorm:"primaryKey;column:id" json:"id"`
+    NodeID   int64    `gorm:"column:node_id;index:idx_node_probe_rollup_node,priority:1" json:"nodeId"`
+    TargetID int64    `gorm:"column:target_id" json:"targetId"`
+    ResS     int      `gorm:"column:res_s;index:idx_node_probe_rollup_node,priority:2;index:idx_node_probe_rollup_time,priority:1" json:"resS"`
+    TimeMs   int64    `gorm:"column:time_ms;index:idx_node_probe_rollup_node,priority:3;index:idx_node_probe_rollup_time,priority:2" json:"timeMs"` // bucket start
+    Samples  int      `gorm:"column:samples" json:"samples"`
+    OKCount  int      `gorm:"column:ok_count" json:"okCount"`
+    RTTAvg   *float64 `gorm:"column:rtt_avg" json:"rttAvg,omitempty"`
+    RTTMin   *int     `gorm:"column:rtt_min" json:"rttMin,omitempty"`
+    RTTMax   *int     `gorm:"column:rtt_max" json:"rttMax,omitempty"`
+}
+
+func (NodeProbeRollup) TableName() string { return "node_probe_rollup" }
+
+// NodeNetIfSample is the traffic of one network interface between two consecutive
+// agent reports. Byte fields are deltas (counter resets already handled), rates in bytes/s.
+type NodeNetIfSample struct {
+    ID         int64   `gorm:"primaryKey;column:id" json:"id"`
+    NodeID     int64   `gorm:"column:node_id;index:idx_node_netif_node_time,priority:1" json:"nodeId"`
+    Iface      string  `gorm:"column:iface;size:64" json:"iface"`
+    TimeMs     int64   `gorm:"column:time_ms;index:idx_node_netif_node_time,priority:2;index:idx_node_netif_time" json:"timeMs"`
+    IntervalMs int64   `gorm:"column:interval_ms" json:"intervalMs"`
+    RxBytes    int64   `gorm:"column:rx_bytes" json:"rxBytes"`
+    TxBytes    int64   `gorm:"column:tx_bytes" json:"txBytes"`
+    RxBps      float64 `gorm:"column:rx_bps" json:"rxBps"`
+    TxBps      float64 `gorm:"column:tx_bps" json:"txBps"`
+}
+
+func (NodeNetIfSample) TableName() string { return "node_netif_sample" }
+
+// NodeNetIfRollup is a downsampled NodeNetIfSample bucket; average rates are
+// bytes * 1000 / interval_ms, peaks are the highest per-report rate.
+type NodeNetIfRollup struct {
+    ID         int64   `gorm:"primaryKey;column:id" json:"id"`
+    NodeID     int64   `gorm:"column:node_id;index:idx_node_netif_rollup_node,priority:1" json:"nodeId"`
+    Iface      string  `gorm:"column:iface;size:64" json:"iface"`
+    ResS       int     `gorm:"column:res_s;index:idx_node_netif_rollup_node,priority:2;index:idx_node_netif_rollup_time,priority:1" json:"resS"`
+    TimeMs     int64   `gorm:"column:time_ms;index:idx_node_netif_rollup_node,priority:3;index:idx_node_netif_rollup_time,priority:2" json:"timeMs"` // bucket start
+    IntervalMs int64   `gorm:"column:interval_ms" json:"intervalMs"`
+    RxBytes    int64   `gorm:"column:rx_bytes" json:"rxBytes"`
+    TxBytes    int64   `gorm:"column:tx_bytes" json:"txBytes"`
+    RxBpsMax   float64 `gorm:"column:rx_bps_max" json:"rxBpsMax"`
+    TxBpsMax   float64 `gorm:"column:tx_bps_max" json:"txBpsMax"`
+}
+
+func (NodeNetIfRollup) TableName() string { return "node_netif_rollup" }
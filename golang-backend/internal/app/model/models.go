@@ -50,6 +50,7 @@ type Tunnel struct {
     Type          int      `gorm:"column:type" json:"type"`
     Flow          int      `gorm:"column:flow" json:"flow"`
     Protocol      *string  `gorm:"column:protocol" json:"protocol,omitempty"`
+    TransportOpts *string  `gorm:"column:transport_opts;type:text" json:"transportOpts,omitempty"` // JSON, see controller/transport.go
     TrafficRatio  *float64 `gorm:"column:traffic_ratio" json:"trafficRatio,omitempty"`
     TCPListenAddr *string  `gorm:"column:tcp_listen_addr" json:"tcpListenAddr,omitempty"`
     UDPListenAddr *string  `gorm:"column:udp_listen_addr" json:"udpListenAddr,omitempty"`
@@ -68,12 +69,25 @@ type Forward struct {
     RemoteAddr    string  `gorm:"column:remote_addr" json:"remoteAddr"`
     InterfaceName *string `gorm:"column:interface_name" json:"interfaceName,omitempty"`
     Strategy      *string `gorm:"column:strategy" json:"strategy,omitempty"`
+    Protocol      *string `gorm:"column:protocol" json:"protocol,omitempty"` // tcp | udp | both, NULL = tcp
     InFlow        int64   `gorm:"column:in_flow" json:"inFlow"`
     OutFlow       int64   `gorm:"column:out_flow" json:"outFlow"`
     Inx           *int    `gorm:"column:inx" json:"inx,omitempty"`
 }
 func (Forward) TableName() string { return "forward" }
 
+// ForwardHop is the listen port a forward's service uses on one relay path
+// node (tunnel_path order), saved at deployment so the desired state can
+// render the mid hops again.
+type ForwardHop struct {
+    ID        int64 `gorm:"primaryKey;column:id" json:"id"`
+    ForwardID int64 `gorm:"column:forward_id;index:idx_forward_hop_forward" json:"forwardId"`
+    Idx       int   `gorm:"column:idx" json:"idx"`
+    NodeID    int64 `gorm:"column:node_id" json:"nodeId"`
+    Port      int   `gorm:"column:port" json:"port"`
+}
+func (ForwardHop) TableName() string { return "forward_hop" }
+
 type UserTunnel struct {
     ID            int64  `gorm:"primaryKey;column:id" json:"id"`
     UserID        int64  `gorm:"column:user_id" json:"userId"`
@@ -114,6 +128,8 @@ type StatisticsFlow struct {
     UserID      int64  `gorm:"column:user_id" json:"userId"`
     Flow        int64  `gorm:"column:flow" json:"flow"`
     TotalFlow   int64  `gorm:"column:total_flow" json:"totalFlow"`
+    // RawFlow is the transferred bytes before the tunnel traffic ratio; Flow is billed
+    RawFlow     int64  `gorm:"column:raw_flow;not null;default:0" json:"rawFlow"`
     Time        string `gorm:"column:time" json:"time"`
     CreatedTime int64  `gorm:"column:created_time" json:"createdTime"`
 }
@@ -136,6 +152,53 @@ type NodeOpLog struct {
 }
 func (NodeOpLog) TableName() string { return "node_op_log" }
 
+// NodeServicePush records a service mutation command (AddService, UpdateService,
+// DeleteService, PauseService, ResumeService) sent to a node.
+type NodeServicePush struct {
+    ID       int64  `gorm:"primaryKey;column:id" json:"id"`
+    TimeMs   int64  `gorm:"column:time_ms;index:idx_node_service_push_node_time,priority:2" json:"timeMs"`
+    NodeID   int64  `gorm:"column:node_id;index:idx_node_service_push_node_time,priority:1" json:"nodeId"`
+    Cmd      string `gorm:"column:cmd;size:32" json:"cmd"`
+    Services string `gorm:"column:services" json:"services"` // comma separated service names
+    Success  int    `gorm:"column:success" json:"success"`   // 1 sent to at least one connection, 0 failed
+    Error    string `gorm:"column:error" json:"error,omitempty"`
+    Payload  string `gorm:"column:payload" json:"payload"` // truncated command JSON
+}
+func (NodeServicePush) TableName() string { return "node_service_push" }
+
+// NodeCommand is a service command waiting for a node that was offline (or had
+// earlier commands waiting). Rows are replayed in id order and removed once the
+// node acknowledged them; a command the node rejected stays as failed.
+type NodeCommand struct {
+    ID          int64  `gorm:"primaryKey;column:id" json:"id"`
+    NodeID      int64  `gorm:"column:node_id;index:idx_node_command_node" json:"nodeId"`
+    Cmd         string `gorm:"column:cmd;size:32" json:"cmd"`
+    Services    string `gorm:"column:services" json:"services"` // comma separated service names
+    Payload     string `gorm:"column:payload" json:"payload"`   // command data JSON
+    Status      string `gorm:"column:status;size:16" json:"status"` // pending, failed
+    Attempts    int    `gorm:"column:attempts" json:"attempts"`
+    LastError   string `gorm:"column:last_error" json:"lastError,omitempty"`
+    CreatedTime int64  `gorm:"column:created_time" json:"createdTime"`
+    UpdatedTime int64  `gorm:"column:updated_time" json:"updatedTime"`
+}
+func (NodeCommand) TableName() string { return "node_command" }
+
+// NodeDrift is the latest drift report of a node: panel-managed services whose
+// gost.json entry is missing, differs from the desired config or is not desired.
+type NodeDrift struct {
+    ID           int64  `gorm:"primaryKey;column:id" json:"id"`
+    NodeID       int64  `gorm:"column:node_id;uniqueIndex:idx_node_drift_node" json:"nodeId"`
+    Missing      string `gorm:"column:missing" json:"missing"` // comma separated service names
+    Changed      string `gorm:"column:changed" json:"changed"`
+    Extra        string `gorm:"column:extra" json:"extra"`
+    Strict       bool   `gorm:"column:strict" json:"strict"` // agent runs with STRICT_RECONCILE
+    DesiredCount int    `gorm:"column:desired_count" json:"desiredCount"`
+    CheckedTime  int64  `gorm:"column:checked_time" json:"checkedTime"`
+    FixedTime    int64  `gorm:"column:fixed_time" json:"fixedTime"`
+    FixResult    string `gorm:"column:fix_result" json:"fixResult"`
+}
+func (NodeDrift) TableName() string { return "node_drift" }
+
 // ExitSetting persists the last configured SS exit settings per node
 type ExitSetting struct {
     BaseEntity
@@ -186,14 +249,24 @@ func (NodeDisconnectLog) TableName() string { return "node_disconnect_log" }
 
 // NodeSysInfo stores periodic system info reported by agent for timeseries
 type NodeSysInfo struct {
-    ID        int64   `gorm:"primaryKey;column:id" json:"id"`
-    NodeID    int64   `gorm:"column:node_id" json:"nodeId"`
-    TimeMs    int64   `gorm:"column:time_ms" json:"timeMs"`
-    Uptime    int64   `gorm:"column:uptime" json:"uptime"`
-    BytesRx   int64   `gorm:"column:bytes_rx" json:"bytesRx"`
-    BytesTx   int64   `gorm:"column:bytes_tx" json:"bytesTx"`
-    CPU       float64 `gorm:"column:cpu" json:"cpu"`
-    Mem       float64 `gorm:"column:mem" json:"mem"`
+    ID             int64   `gorm:"primaryKey;column:id" json:"id"`
+    NodeID         int64   `gorm:"column:node_id" json:"nodeId"`
+    TimeMs         int64   `gorm:"column:time_ms" json:"timeMs"`
+    Uptime         int64   `gorm:"column:uptime" json:"uptime"`
+    BytesRx        int64   `gorm:"column:bytes_rx" json:"bytesRx"`
+    BytesTx        int64   `gorm:"column:bytes_tx" json:"bytesTx"`
+    CPU            float64 `gorm:"column:cpu" json:"cpu"`
+    Mem            float64 `gorm:"column:mem" json:"mem"`
+    Load1          float64 `gorm:"column:load1" json:"load1"`
+    Load5          float64 `gorm:"column:load5" json:"load5"`
+    Load15         float64 `gorm:"column:load15" json:"load15"`
+    TCPEstab       int64   `gorm:"column:tcp_estab" json:"tcpEstab"`
+    TCPTimeWait    int64   `gorm:"column:tcp_time_wait" json:"tcpTimeWait"`
+    ConntrackCount int64   `gorm:"column:conntrack_count" json:"conntrackCount"`
+    ConntrackMax   int64   `gorm:"column:conntrack_max" json:"conntrackMax"`
+    DiskUsage      float64 `gorm:"column:disk_usage" json:"diskUsage"` // highest usage percent across mounts
+    GostRunning    *int    `gorm:"column:gost_running" json:"gostRunning,omitempty"` // 1 running, 0 stopped, nil unknown
+    GostRestarts   int64   `gorm:"column:gost_restarts" json:"gostRestarts"`
 }
 func (NodeSysInfo) TableName() string { return "node_sysinfo" }
 
@@ -201,6 +274,7 @@ func (NodeSysInfo) TableName() string { return "node_sysinfo" }
 type NodeRuntime struct {
     NodeID      int64   `gorm:"primaryKey;column:node_id" json:"nodeId"`
     Interfaces  *string `gorm:"column:interfaces" json:"interfaces,omitempty"` // JSON array string
+    Disks       *string `gorm:"column:disks" json:"disks,omitempty"` // JSON array of {mount,fs,total,used,usage}
     UpdatedTime int64   `gorm:"column:updated_time" json:"updatedTime"`
 }
 func (NodeRuntime) TableName() string { return "node_runtime" }
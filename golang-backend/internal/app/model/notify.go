@@ -0,0 +1,35 @@
+package model
+
+// NotifyChannel is a named notification target. Type: webhook, telegram, smtp,
+// dingtalk, wecom, feishu; Config holds the type specific settings as JSON.
+type NotifyChannel struct {
+    ID          int64   `gorm:"primaryKey;column:id" json:"id"`
+    CreatedTime int64   `gorm:"column:created_time" json:"createdTime"`
+    UpdatedTime int64   `gorm:"column:updated_time" json:"updatedTime"`
+    Status      int     `gorm:"column:status" json:"status"` // 1 enabled, 0 disabled
+    Name        string  `gorm:"column:name" json:"name"`
+    Type        string  `gorm:"column:type;size:16" json:"type"`
+    Config      string  `gorm:"column:config" json:"config"`
+    Template    string  `gorm:"column:template" json:"template"` // empty = per-type default
+    Events      *string `gorm:"column:events" json:"events,omitempty"`         // JSON array of event names, "prefix*" allowed; empty = all
+    Severities  *string `gorm:"column:severities" json:"severities,omitempty"` // JSON array; only filters events carrying a severity
+    MaxRetries  int     `gorm:"column:max_retries" json:"maxRetries"`
+}
+
+func (NotifyChannel) TableName() string { return "notify_channel" }
+
+// NotifyDelivery records one notification sent (or given up) on a channel.
+type NotifyDelivery struct {
+    ID          int64  `gorm:"primaryKey;column:id" json:"id"`
+    TimeMs      int64  `gorm:"column:time_ms;index:idx_notify_delivery_time" json:"timeMs"`
+    ChannelID   int64  `gorm:"column:channel_id;index:idx_notify_delivery_channel" json:"channelId"`
+    ChannelName string `gorm:"column:channel_name" json:"channelName"`
+    Event       string `gorm:"column:event;size:64" json:"event"`
+    Success     int    `gorm:"column:success" json:"success"` // 1 delivered, 0 failed after all attempts
+    Attempts    int    `gorm:"column:attempts" json:"attempts"`
+    Error       string `gorm:"column:error" json:"error"`
+    Content     string `gorm:"column:content" json:"content"`
+    DurationMs  int64  `gorm:"column:duration_ms" json:"durationMs"`
+}
+
+func (NotifyDelivery) TableName() string { return "notify_delivery" }
@@ -41,11 +41,12 @@ func RegisterRoutes(r *gin.Engine) {
 		captcha.POST("/verify", controller.CaptchaVerify)
 	}
 
-	// public config
+	// config: public settings for anyone, everything for admins
 	conf := api.Group("/config")
 	{
-		conf.POST("/list", controller.ConfigList)
-		conf.POST("/get", controller.ConfigGet)
+		conf.POST("/list", middleware.AuthOptional(), controller.ConfigList)
+		conf.POST("/get", middleware.AuthOptional(), controller.ConfigGet)
+		conf.POST("/schema", middleware.RequireRole(), controller.ConfigSchema)
 		conf.POST("/update", middleware.RequireRole(), controller.ConfigUpdate)
 		conf.POST("/update-single", middleware.RequireRole(), controller.ConfigUpdateSingle)
 	}
@@ -88,9 +89,18 @@ func RegisterRoutes(r *gin.Engine) {
 		node.POST("/network-stats", controller.NodeNetworkStats)
 		node.POST("/network-stats-batch", controller.NodeNetworkStatsBatch)
 		node.POST("/sysinfo", controller.NodeSysinfo)
+		node.POST("/bandwidth", controller.NodeBandwidth)
 		node.POST("/interfaces", controller.NodeInterfaces)
         node.POST("/ops", controller.NodeOps)
+        node.POST("/timeline", controller.NodeTimeline)
         node.POST("/restart-gost", controller.NodeRestartGost)
+        // service commands queued for offline nodes
+        node.POST("/queue/list", controller.NodeQueueList)
+        node.POST("/queue/retry", controller.NodeQueueRetry)
+        node.POST("/queue/delete", controller.NodeQueueDelete)
+        // service drift reported by agents
+        node.POST("/drift/list", controller.NodeDriftList)
+        node.POST("/drift/fix", controller.NodeDriftFix)
 	}
 
 	// tunnel
@@ -119,6 +129,8 @@ func RegisterRoutes(r *gin.Engine) {
 			adm.POST("/bind/get", controller.TunnelBindGet)
 			adm.POST("/bind/set", controller.TunnelBindSet)
 			adm.POST("/cleanup-temp", controller.TunnelCleanupTemp)
+			adm.POST("/redeploy", controller.TunnelRedeploy)
+			adm.POST("/redeploy/status", controller.TunnelRedeployStatus)
 		}
 	}
 
@@ -178,6 +190,29 @@ func RegisterRoutes(r *gin.Engine) {
 	r.Any("/flow/upload", controller.FlowUpload)
 	// alerts
 	api.POST("/alerts/recent", middleware.RequireRole(), controller.AlertsRecent)
+	api.POST("/alerts/list", middleware.RequireRole(), controller.AlertList)
+	api.POST("/alerts/ack", middleware.RequireRole(), controller.AlertAck)
+	api.POST("/alerts/resolve", middleware.RequireRole(), controller.AlertResolve)
+	api.POST("/alerts/summary", middleware.RequireRole(), controller.AlertSummary)
+	alertRule := api.Group("/alert-rule")
+	alertRule.Use(middleware.RequireRole())
+	{
+		alertRule.POST("/list", controller.AlertRuleList)
+		alertRule.POST("/create", controller.AlertRuleCreate)
+		alertRule.POST("/update", controller.AlertRuleUpdate)
+		alertRule.POST("/delete", controller.AlertRuleDelete)
+		alertRule.POST("/states", controller.AlertRuleStates)
+	}
+	notify := api.Group("/notify-channel")
+	notify.Use(middleware.RequireRole())
+	{
+		notify.POST("/list", controller.NotifyChannelList)
+		notify.POST("/create", controller.NotifyChannelCreate)
+		notify.POST("/update", controller.NotifyChannelUpdate)
+		notify.POST("/delete", controller.NotifyChannelDelete)
+		notify.POST("/test", controller.NotifyChannelTest)
+		notify.POST("/deliveries", controller.NotifyDeliveryList)
+	}
 
 	// probe targets (admin)
 	probe := api.Group("/probe")
@@ -214,6 +249,7 @@ func RegisterRoutes(r *gin.Engine) {
 		agent.POST("/reconcile", controller.AgentReconcile)
 		agent.POST("/remove-services", controller.AgentRemoveServices)
 		agent.POST("/reconcile-node", controller.AgentReconcileNode)
+		agent.POST("/report-drift", controller.AgentReportDrift)
 		agent.POST("/probe-targets", controller.AgentProbeTargets)
 		agent.POST("/report-probe", controller.AgentReportProbe)
 	}
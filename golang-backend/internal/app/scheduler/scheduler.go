@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"network-panel/golang-backend/internal/app/controller"
@@ -9,17 +10,51 @@ import (
 	dbpkg "network-panel/golang-backend/internal/db"
 )
 
+var (
+	stop = make(chan struct{})
+	wg   sync.WaitGroup
+)
+
 func Start() {
-    go billingChecker()
+	every(6*time.Hour, true, checkOnce)
+	// downsample sysinfo/probe series and prune old rows (including the
+	// notification delivery log and service push history)
+	every(5*time.Minute, true, func() {
+		controller.DownsampleMetrics()
+		controller.PruneNotifyDeliveries()
+		controller.PruneServicePushes()
+	})
+	every(30*time.Second, false, controller.EvaluateAlertRules)
+	every(time.Minute, false, controller.ReplayNodeQueues)
+	// store hop ports of forwards deployed before they were saved, off the request path
+	every(time.Minute, false, controller.BackfillForwardHops)
 }
 
-func billingChecker() {
-	ticker := time.NewTicker(6 * time.Hour)
-	defer ticker.Stop()
-	for {
-		checkOnce()
-		<-ticker.C
-	}
+// Stop signals all jobs to exit and waits for running ones to finish.
+func Stop() {
+	close(stop)
+	wg.Wait()
+}
+
+// every runs fn on a ticker until Stop; immediate also runs it once at start.
+func every(d time.Duration, immediate bool, fn func()) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+		if immediate {
+			fn()
+		}
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				fn()
+			}
+		}
+	}()
 }
 
 func checkOnce() {
@@ -47,7 +82,7 @@ func checkOnce() {
 			// alert record
 			name := n.Name
 			nid := n.ID
-			a := model.Alert{TimeMs: now, Type: "due", NodeID: &nid, NodeName: &name, Message: msg}
+			a := model.Alert{TimeMs: now, Type: "due", NodeID: &nid, NodeName: &name, Message: msg, State: "open"}
 			_ = dbpkg.DB.Create(&a).Error
 			// callback
 			controller.TriggerCallback("node_due", n, map[string]any{"remainMs": rem})
@@ -0,0 +1,363 @@
+// Package config loads the server configuration: built-in defaults, then an
+// optional JSON file, then environment variables (including .env), later sources
+// winning. The result is validated as a whole so a bad setting stops the server at
+// startup with every problem listed, instead of surfacing later at runtime.
+//
+// Packages that read their settings from the environment (db, migrations) see the
+// merged values through Export.
+package config
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"network-panel/golang-backend/internal/logging"
+)
+
+// Config is the server configuration. Each field names its env variable and
+// default in struct tags; fields tagged secret are masked by Redacted.
+type Config struct {
+	Port          string `json:"port" env:"PORT" default:"6365"`
+	GinMode       string `json:"ginMode" env:"GIN_MODE" default:"release"`
+	JWTSecret     string `json:"jwtSecret" env:"JWT_SECRET" secret:"true"`
+	JWTSecretFile string `json:"jwtSecretFile" env:"JWT_SECRET_FILE" default:"jwt_secret"`
+
+	HTTP HTTPConfig `json:"http"`
+	TLS  TLSConfig  `json:"tls"`
+	DB   DBConfig   `json:"db"`
+	Log  LogConfig  `json:"log"`
+}
+
+// HTTPConfig holds the HTTP server timeouts, in seconds.
+type HTTPConfig struct {
+	ReadTimeout     int `json:"readTimeout" env:"HTTP_READ_TIMEOUT" default:"60"`
+	WriteTimeout    int `json:"writeTimeout" env:"HTTP_WRITE_TIMEOUT" default:"180"`
+	IdleTimeout     int `json:"idleTimeout" env:"HTTP_IDLE_TIMEOUT" default:"120"`
+	ShutdownTimeout int `json:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT" default:"20"`
+}
+
+// TLSConfig selects how the panel terminates TLS: cert files, ACME, or neither.
+type TLSConfig struct {
+	CertFile         string `json:"certFile" env:"TLS_CERT_FILE"`
+	KeyFile          string `json:"keyFile" env:"TLS_KEY_FILE"`
+	ACMEDomains      string `json:"acmeDomains" env:"ACME_DOMAINS"`
+	ACMEEmail        string `json:"acmeEmail" env:"ACME_EMAIL"`
+	ACMECacheDir     string `json:"acmeCacheDir" env:"ACME_CACHE_DIR" default:"acme-cache"`
+	ACMEDirectoryURL string `json:"acmeDirectoryUrl" env:"ACME_DIRECTORY_URL"`
+	ACMEHTTPPort     string `json:"acmeHttpPort" env:"ACME_HTTP_PORT" default:"80"`
+	HTTPPort         string `json:"httpPort" env:"HTTP_PORT"`
+}
+
+// Enabled reports whether TLS is configured.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != "" || len(t.Domains()) > 0
+}
+
+// Domains returns the ACME domains.
+func (t TLSConfig) Domains() []string {
+	var out []string
+	for _, d := range strings.Split(t.ACMEDomains, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// DBConfig is the database connection.
+type DBConfig struct {
+	Dialect        string `json:"dialect" env:"DB_DIALECT" default:"mysql"`
+	Host           string `json:"host" env:"DB_HOST"`
+	Port           string `json:"port" env:"DB_PORT" default:"3306"`
+	Name           string `json:"name" env:"DB_NAME"`
+	User           string `json:"user" env:"DB_USER"`
+	Password       string `json:"password" env:"DB_PASSWORD" secret:"true"`
+	SQLitePath     string `json:"sqlitePath" env:"DB_SQLITE_PATH" default:"./flux.db"`
+	MigrateOnStart bool   `json:"migrateOnStart" env:"DB_MIGRATE_ON_START" default:"true"`
+}
+
+// LogConfig mirrors logging.Config; see the logging package for the meaning.
+type LogConfig struct {
+	Level       string `json:"level" env:"LOG_LEVEL" default:"info"`
+	Format      string `json:"format" env:"LOG_FORMAT" default:"json"`
+	File        string `json:"file" env:"LOG_FILE"`
+	Stdout      bool   `json:"stdout" env:"LOG_STDOUT"`
+	MaxSizeMB   int    `json:"maxSizeMb" env:"LOG_MAX_SIZE_MB" default:"100"`
+	RotateHours int    `json:"rotateHours" env:"LOG_ROTATE_HOURS" default:"24"`
+	MaxAgeDays  int    `json:"maxAgeDays" env:"LOG_MAX_AGE_DAYS" default:"14"`
+	MaxBackups  int    `json:"maxBackups" env:"LOG_MAX_BACKUPS" default:"10"`
+	RedactKeys  string `json:"redactKeys" env:"LOG_REDACT_KEYS"`
+}
+
+// Logging converts the section for logging.Init.
+func (l LogConfig) Logging() logging.Config {
+	cfg := logging.Config{Level: l.Level, Format: l.Format, File: l.File, Stdout: l.Stdout,
+		MaxSizeMB: l.MaxSizeMB, RotateHours: l.RotateHours, MaxAgeDays: l.MaxAgeDays, MaxBackups: l.MaxBackups}
+	for _, k := range strings.Split(l.RedactKeys, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			cfg.RedactKeys = append(cfg.RedactKeys, k)
+		}
+	}
+	return cfg
+}
+
+// Seconds converts a timeout setting.
+func Seconds(n int) time.Duration { return time.Duration(n) * time.Second }
+
+// Load builds the configuration with Parse, then validates it.
+func Load(path string, required bool) (*Config, error) {
+	cfg, err := Parse(path, required)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Parse builds the configuration from defaults, the JSON file at path and the
+// environment without validating it. A missing file is only an error when
+// required (the path was given explicitly); unknown keys in the file are rejected.
+func Parse(path string, required bool) (*Config, error) {
+	cfg := &Config{}
+	if err := walk(reflect.ValueOf(cfg).Elem(), func(f reflect.Value, sf reflect.StructField) error {
+		if d, ok := sf.Tag.Lookup("default"); ok {
+			return setField(f, d)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if path != "" {
+		b, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			dec := json.NewDecoder(bytes.NewReader(b))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(cfg); err != nil {
+				return nil, fmt.Errorf("config file %s: %w", path, err)
+			}
+		case !os.IsNotExist(err) || required:
+			return nil, fmt.Errorf("config file: %w", err)
+		}
+	}
+	if err := walk(reflect.ValueOf(cfg).Elem(), func(f reflect.Value, sf reflect.StructField) error {
+		// an empty variable (e.g. "LOG_FILE=" in .env) keeps the file or default value
+		name := sf.Tag.Get("env")
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" && name != "" {
+			if err := setField(f, v); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	bad := func(format string, args ...any) { errs = append(errs, fmt.Errorf(format, args...)) }
+
+	checkPort := func(name, v string, optional bool) {
+		if v == "" && optional {
+			return
+		}
+		if n, err := strconv.Atoi(v); err != nil || n < 1 || n > 65535 {
+			bad("%s: invalid port %q", name, v)
+		}
+	}
+	checkPort("PORT", c.Port, false)
+	switch c.GinMode {
+	case "debug", "test", "release":
+	default:
+		bad("GIN_MODE: must be debug, test or release, got %q", c.GinMode)
+	}
+	if c.JWTSecret != "" && len(c.JWTSecret) < 16 {
+		bad("JWT_SECRET: must be at least 16 characters (leave empty to generate one)")
+	}
+	for _, kv := range []struct {
+		name string
+		v    int
+	}{{"HTTP_READ_TIMEOUT", c.HTTP.ReadTimeout}, {"HTTP_WRITE_TIMEOUT", c.HTTP.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", c.HTTP.IdleTimeout}, {"SHUTDOWN_TIMEOUT", c.HTTP.ShutdownTimeout}} {
+		if kv.v <= 0 {
+			bad("%s: must be a positive number of seconds", kv.name)
+		}
+	}
+
+	t := c.TLS
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		bad("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	for _, f := range []string{t.CertFile, t.KeyFile} {
+		if f != "" {
+			if _, err := os.Stat(f); err != nil {
+				bad("TLS: %v", err)
+			}
+		}
+	}
+	if len(t.Domains()) > 0 {
+		if t.CertFile != "" {
+			bad("TLS_CERT_FILE and ACME_DOMAINS are mutually exclusive")
+		}
+		checkPort("ACME_HTTP_PORT", t.ACMEHTTPPort, false)
+		if t.ACMEHTTPPort == c.Port {
+			bad("ACME_HTTP_PORT must differ from PORT")
+		}
+	}
+	checkPort("HTTP_PORT", t.HTTPPort, true)
+	if t.HTTPPort != "" && t.HTTPPort == c.Port {
+		bad("HTTP_PORT must differ from PORT")
+	}
+	if t.HTTPPort != "" && !t.Enabled() {
+		bad("HTTP_PORT is only used together with TLS")
+	}
+
+	d := c.DB
+	switch d.Dialect {
+	case "sqlite":
+		if d.SQLitePath == "" {
+			bad("DB_SQLITE_PATH: must not be empty")
+		}
+	case "mysql", "":
+		if d.Host == "" || d.Name == "" || d.User == "" {
+			bad("DB_HOST, DB_NAME and DB_USER are required for mysql (or set DB_DIALECT=sqlite)")
+		}
+		checkPort("DB_PORT", d.Port, false)
+	default:
+		bad("DB_DIALECT: must be mysql or sqlite, got %q", d.Dialect)
+	}
+
+	l := c.Log
+	switch strings.ToLower(l.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		bad("LOG_LEVEL: must be debug, info, warn or error, got %q", l.Level)
+	}
+	if l.Format != "json" && l.Format != "console" {
+		bad("LOG_FORMAT: must be json or console, got %q", l.Format)
+	}
+	if l.MaxSizeMB < 0 || l.RotateHours < 0 || l.MaxAgeDays < 0 || l.MaxBackups < 0 {
+		bad("LOG_MAX_SIZE_MB, LOG_ROTATE_HOURS, LOG_MAX_AGE_DAYS, LOG_MAX_BACKUPS: must not be negative")
+	}
+	return errors.Join(errs...)
+}
+
+// EnsureJWTSecret fills in a missing JWT secret: it is read from JWTSecretFile,
+// or generated and written there on first run so tokens survive restarts.
+func (c *Config) EnsureJWTSecret() error {
+	if c.JWTSecret != "" {
+		return nil
+	}
+	if b, err := os.ReadFile(c.JWTSecretFile); err == nil {
+		if s := strings.TrimSpace(string(b)); len(s) >= 16 {
+			c.JWTSecret = s
+			return nil
+		}
+		return fmt.Errorf("JWT_SECRET_FILE %s: secret too short", c.JWTSecretFile)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("JWT_SECRET_FILE: %w", err)
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	secret := hex.EncodeToString(buf)
+	if dir := filepath.Dir(c.JWTSecretFile); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("JWT_SECRET_FILE: %w", err)
+		}
+	}
+	if err := os.WriteFile(c.JWTSecretFile, []byte(secret+"\n"), 0o600); err != nil {
+		return fmt.Errorf("JWT_SECRET_FILE: %w", err)
+	}
+	log.Printf("JWT_SECRET not set, generated a random secret in %s", c.JWTSecretFile)
+	c.JWTSecret = secret
+	return nil
+}
+
+// Export writes the merged values to the environment.
+func (c *Config) Export() {
+	_ = walk(reflect.ValueOf(c).Elem(), func(f reflect.Value, sf reflect.StructField) error {
+		if name := sf.Tag.Get("env"); name != "" {
+			_ = os.Setenv(name, fieldString(f))
+		}
+		return nil
+	})
+}
+
+// Redacted returns the configuration as indented JSON with secrets masked.
+func (c *Config) Redacted() string {
+	cp := *c
+	_ = walk(reflect.ValueOf(&cp).Elem(), func(f reflect.Value, sf reflect.StructField) error {
+		if sf.Tag.Get("secret") == "true" && f.String() != "" {
+			f.SetString("***")
+		}
+		return nil
+	})
+	b, _ := json.MarshalIndent(&cp, "", "  ")
+	return string(b)
+}
+
+// walk calls fn for every leaf field, descending into nested sections.
+func walk(v reflect.Value, fn func(reflect.Value, reflect.StructField) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, sf := v.Field(i), t.Field(i)
+		if f.Kind() == reflect.Struct {
+			if err := walk(f, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(f, sf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setField(f reflect.Value, s string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		f.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		f.SetBool(b)
+	}
+	return nil
+}
+
+func fieldString(f reflect.Value) string {
+	switch f.Kind() {
+	case reflect.Int:
+		return strconv.FormatInt(f.Int(), 10)
+	case reflect.Bool:
+		return strconv.FormatBool(f.Bool())
+	}
+	return f.String()
+}
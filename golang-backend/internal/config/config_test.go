@@ -0,0 +1,168 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// clearEnv empties every configuration variable for the test; an empty
+// variable keeps the file or default value.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	_ = walk(reflect.ValueOf(&Config{}).Elem(), func(f reflect.Value, sf reflect.StructField) error {
+		if name := sf.Tag.Get("env"); name != "" {
+			t.Setenv(name, "")
+		}
+		return nil
+	})
+}
+
+func writeFile(t *testing.T, body string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "panel.json")
+	if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDefaults(t *testing.T) {
+	clearEnv(t)
+	cfg, err := Parse("", false)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if cfg.Port != "6365" || cfg.GinMode != "release" || cfg.JWTSecretFile != "jwt_secret" {
+		t.Errorf("top level = %q/%q/%q", cfg.Port, cfg.GinMode, cfg.JWTSecretFile)
+	}
+	if cfg.HTTP != (HTTPConfig{ReadTimeout: 60, WriteTimeout: 180, IdleTimeout: 120, ShutdownTimeout: 20}) {
+		t.Errorf("http = %+v", cfg.HTTP)
+	}
+	if cfg.DB.Dialect != "mysql" || cfg.DB.Port != "3306" || cfg.DB.SQLitePath != "./flux.db" || !cfg.DB.MigrateOnStart {
+		t.Errorf("db = %+v", cfg.DB)
+	}
+	if cfg.Log.Level != "info" || cfg.Log.Format != "json" || cfg.Log.MaxSizeMB != 100 || cfg.Log.MaxBackups != 10 {
+		t.Errorf("log = %+v", cfg.Log)
+	}
+	if cfg.TLS.Enabled() {
+		t.Error("tls enabled by default")
+	}
+}
+
+func TestMissingFile(t *testing.T) {
+	clearEnv(t)
+	missing := filepath.Join(t.TempDir(), "none.json")
+	if _, err := Parse(missing, false); err != nil {
+		t.Errorf("optional missing file: %v", err)
+	}
+	if _, err := Parse(missing, true); err == nil {
+		t.Error("required missing file: want error")
+	}
+}
+
+func TestFileAndEnvOverrides(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, `{"port":"7000","http":{"readTimeout":30},"db":{"dialect":"sqlite","sqlitePath":"/data/a.db"},"log":{"level":"debug"}}`)
+	t.Setenv("PORT", "8000")
+	t.Setenv("LOG_STDOUT", "true")
+	t.Setenv("DB_MIGRATE_ON_START", "false")
+	t.Setenv("LOG_LEVEL", " ") // blank keeps the file value
+
+	cfg, err := Load(path, true)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Port != "8000" {
+		t.Errorf("port = %q, want env value 8000", cfg.Port)
+	}
+	if cfg.HTTP.ReadTimeout != 30 || cfg.HTTP.WriteTimeout != 180 {
+		t.Errorf("http = %+v, want file readTimeout and default writeTimeout", cfg.HTTP)
+	}
+	if cfg.DB.Dialect != "sqlite" || cfg.DB.SQLitePath != "/data/a.db" || cfg.DB.MigrateOnStart {
+		t.Errorf("db = %+v", cfg.DB)
+	}
+	if cfg.Log.Level != "debug" || !cfg.Log.Stdout {
+		t.Errorf("log = %+v", cfg.Log)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	clearEnv(t)
+	if _, err := Parse(writeFile(t, `{"prot":"1"}`), true); err == nil {
+		t.Error("unknown key: want error")
+	}
+	t.Setenv("HTTP_READ_TIMEOUT", "soon")
+	_, err := Parse("", false)
+	if err == nil || !strings.Contains(err.Error(), "HTTP_READ_TIMEOUT") {
+		t.Errorf("bad integer: err = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DB_DIALECT", "sqlite")
+	if _, err := Load("", false); err != nil {
+		t.Fatalf("valid sqlite config: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{"mysql without host", map[string]string{"DB_DIALECT": "mysql"}, []string{"DB_HOST, DB_NAME and DB_USER"}},
+		{"bad dialect", map[string]string{"DB_DIALECT": "pg"}, []string{"DB_DIALECT"}},
+		{"bad port", map[string]string{"PORT": "70000"}, []string{"PORT: invalid port"}},
+		{"short secret", map[string]string{"JWT_SECRET": "short"}, []string{"JWT_SECRET"}},
+		{"timeout", map[string]string{"SHUTDOWN_TIMEOUT": "0"}, []string{"SHUTDOWN_TIMEOUT"}},
+		{"cert without key", map[string]string{"TLS_CERT_FILE": "/nonexistent.pem"}, []string{"must be set together"}},
+		{"acme port clash", map[string]string{"ACME_DOMAINS": "a.example.com", "ACME_HTTP_PORT": "6365"}, []string{"ACME_HTTP_PORT must differ"}},
+		{"http port without tls", map[string]string{"HTTP_PORT": "8080"}, []string{"only used together with TLS"}},
+		{"log", map[string]string{"LOG_LEVEL": "trace", "LOG_FORMAT": "xml"}, []string{"LOG_LEVEL", "LOG_FORMAT"}},
+		// every problem is reported at once
+		{"several", map[string]string{"GIN_MODE": "prod", "PORT": "x", "LOG_MAX_BACKUPS": "-1"}, []string{"GIN_MODE", "PORT: invalid port", "LOG_MAX_BACKUPS"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Parse("", false)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			err = cfg.Validate()
+			if err == nil {
+				t.Fatal("Validate: want error")
+			}
+			for _, w := range tc.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("error %q does not mention %q", err, w)
+				}
+			}
+			if _, err := Load("", false); err == nil {
+				t.Error("Load: want validation error")
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("JWT_SECRET", "0123456789abcdef-secret")
+	t.Setenv("DB_PASSWORD", "hunter2")
+	cfg, err := Parse("", false)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	out := cfg.Redacted()
+	if strings.Contains(out, "hunter2") || strings.Contains(out, "0123456789abcdef-secret") {
+		t.Errorf("secret leaked: %s", out)
+	}
+	if cfg.DB.Password != "hunter2" {
+		t.Error("Redacted modified the config")
+	}
+}
@@ -2,11 +2,14 @@ package db
 
 import (
 	"fmt"
+	"log"
+	"log/slog"
 	"os"
 	"time"
 
 	"network-panel/golang-backend/internal/app/model"
 	"network-panel/golang-backend/internal/app/util"
+	"network-panel/golang-backend/internal/logging"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -62,39 +65,59 @@ func ensureDatabase() error {
 	return nil
 }
 
+// OpenSQLite opens an additional SQLite database file (e.g. a migration source).
+func OpenSQLite(path string, cfg *gorm.Config) (*gorm.DB, error) {
+	if cfg == nil {
+		cfg = &gorm.Config{Logger: logger.Default.LogMode(logger.Warn)}
+	}
+	return openSQLiteGorm(path, cfg)
+}
+
+// Init connects to the database, brings the schema up to date and seeds the admin user.
+// Versioned migrations run at startup unless DB_MIGRATE_ON_START=false.
 func Init() error {
-	if err := ensureDatabase(); err != nil {
+	if err := Open(); err != nil {
 		return err
 	}
-	cfg := &gorm.Config{Logger: logger.Default.LogMode(logger.Info)}
-	var db *gorm.DB
-	var err error
-	if os.Getenv("DB_DIALECT") == "sqlite" {
-		path := os.Getenv("DB_SQLITE_PATH")
-		if path == "" {
-			path = "./flux.db"
+	if os.Getenv("DB_MIGRATE_ON_START") != "false" {
+		if err := Migrate(); err != nil {
+			return err
 		}
-		db, err = openSQLiteGorm(path, cfg)
-	} else {
-		db, err = gorm.Open(mysql.Open(dsn()), cfg)
 	}
-	if err != nil {
+	// Seed admin user
+	if err := seedAdmin(); err != nil {
 		return err
 	}
-	sqlDB, err := db.DB()
+	return nil
+}
+
+// Close closes the database connection pool.
+func Close() error {
+	if DB == nil {
+		return nil
+	}
+	sqlDB, err := DB.DB()
 	if err != nil {
 		return err
 	}
-	sqlDB.SetMaxIdleConns(5)
-	sqlDB.SetMaxOpenConns(20)
-	sqlDB.SetConnMaxLifetime(30 * time.Minute)
-	DB = db
-	// Auto-migrate tables
-	if err := DB.AutoMigrate(
+	return sqlDB.Close()
+}
+
+// Open creates the database if needed, connects to it and auto-migrates model tables.
+func Open() error {
+	if err := ensureDatabase(); err != nil {
+		return err
+	}
+	if err := Connect(); err != nil {
+		return err
+	}
+	// Auto-migrate tables (adds tables/columns only; see migrations.go for everything else)
+	return DB.AutoMigrate(
 		&model.User{},
 		&model.Node{},
 		&model.Tunnel{},
 		&model.Forward{},
+		&model.ForwardHop{},
 		&model.UserTunnel{},
 		&model.SpeedLimit{},
 		&model.ViteConfig{},
@@ -104,16 +127,58 @@ func Init() error {
 		&model.NodeProbeResult{},
 		&model.NodeDisconnectLog{},
 		&model.Alert{},
+		&model.AlertRule{},
+		&model.AlertRuleState{},
+		&model.NotifyChannel{},
+		&model.NotifyDelivery{},
 		&model.NodeSysInfo{},
+		&model.NodeSysInfoRollup{},
+		&model.NodeProbeRollup{},
+		&model.NodeNetIfSample{},
+		&model.NodeNetIfRollup{},
 		&model.NodeRuntime{},
 		&model.NodeOpLog{},
-	); err != nil {
+		&model.NodeServicePush{},
+		&model.NodeCommand{},
+		&model.NodeDrift{},
+	)
+}
+
+// Connect opens the configured database without creating or changing anything.
+func Connect() error {
+	// SQL is only traced at LOG_LEVEL=debug, without bound values
+	lv := logger.Warn
+	if logging.Enabled(slog.LevelDebug) {
+		lv = logger.Info
+	}
+	cfg := &gorm.Config{Logger: logger.New(log.Default(), logger.Config{
+		SlowThreshold:             time.Second,
+		LogLevel:                  lv,
+		IgnoreRecordNotFoundError: true,
+		ParameterizedQueries:      true,
+	})}
+	var db *gorm.DB
+	var err error
+	if os.Getenv("DB_DIALECT") == "sqlite" {
+		path := os.Getenv("DB_SQLITE_PATH")
+		if path == "" {
+			path = "./flux.db"
+		}
+		db, err = openSQLiteGorm(path, cfg)
+	} else {
+		db, err = gorm.Open(mysql.Open(dsn()), cfg)
+	}
+	if err != nil {
 		return err
 	}
-	// Seed admin user
-	if err := seedAdmin(); err != nil {
+	sqlDB, err := db.DB()
+	if err != nil {
 		return err
 	}
+	sqlDB.SetMaxIdleConns(5)
+	sqlDB.SetMaxOpenConns(20)
+	sqlDB.SetConnMaxLifetime(30 * time.Minute)
+	DB = db
 	return nil
 }
 
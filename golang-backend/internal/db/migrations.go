@@ -0,0 +1,283 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// schemaMigration records an applied versioned migration.
+type schemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false;column:version"`
+	Name      string `gorm:"column:name"`
+	AppliedAt int64  `gorm:"column:applied_at"`
+}
+
+func (schemaMigration) TableName() string { return "schema_migration" }
+
+// migration is one ordered schema/data change. AutoMigrate only adds tables and
+// columns; anything else (indexes, renames, drops, backfills) goes here.
+// Versions must be unique and never reused once released.
+type migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+}
+
+var migrations = []migration{
+	{Version: 1, Name: "hot_query_indexes", Up: func(tx *gorm.DB) error {
+		idx := []struct {
+			table, name string
+			cols        []string
+		}{
+			{"user", "idx_user_user", []string{"user:191"}},
+			{"node", "idx_node_secret", []string{"secret:191"}},
+			{"forward", "idx_forward_user_id", []string{"user_id"}},
+			{"forward", "idx_forward_tunnel_id", []string{"tunnel_id"}},
+			{"user_tunnel", "idx_user_tunnel_user_tunnel", []string{"user_id", "tunnel_id"}},
+			{"user_tunnel", "idx_user_tunnel_tunnel_id", []string{"tunnel_id"}},
+			{"vite_config", "idx_vite_config_name", []string{"name:191"}},
+			{"statistics_flow", "idx_statistics_flow_user_time", []string{"user_id", "time:32"}},
+			{"node_probe_result", "idx_node_probe_result_node_time", []string{"node_id", "time_ms"}},
+			{"node_probe_result", "idx_node_probe_result_time", []string{"time_ms"}},
+			{"node_sysinfo", "idx_node_sysinfo_node_time", []string{"node_id", "time_ms"}},
+			{"node_disconnect_log", "idx_node_disconnect_log_node_down", []string{"node_id", "down_at_ms"}},
+			{"alert", "idx_alert_time", []string{"time_ms"}},
+			{"node_op_log", "idx_node_op_log_node_time", []string{"node_id", "time_ms"}},
+			{"node_op_log", "idx_node_op_log_request_id", []string{"request_id:64"}},
+		}
+		for _, i := range idx {
+			if err := ensureIndex(tx, i.table, i.name, i.cols...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{Version: 2, Name: "backfill_forward_status", Up: func(tx *gorm.DB) error {
+		// forwards created before status was tracked are active
+		return tx.Exec("UPDATE `forward` SET `status` = 1 WHERE `status` IS NULL").Error
+	}},
+	{Version: 3, Name: "backfill_alert_state", Up: func(tx *gorm.DB) error {
+		// informational records are resolved by nature
+		if err := tx.Exec("UPDATE `alert` SET `state` = 'resolved', `resolved_at_ms` = `time_ms`, `resolved_by` = 'auto' WHERE (`state` IS NULL OR `state` = '') AND `type` IN ('online', 'rule_resolved')").Error; err != nil {
+			return err
+		}
+		// an offline alert followed by an online alert of the same node is over
+		var rows []struct {
+			ID     int64
+			NodeID int64
+			TimeMs int64
+		}
+		if err := tx.Table("alert").Select("id, node_id, time_ms").Where("(state IS NULL OR state = '') AND type = 'offline' AND node_id IS NOT NULL").Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, r := range rows {
+			var up struct{ TimeMs int64 }
+			if tx.Table("alert").Select("time_ms").Where("node_id = ? AND type = 'online' AND time_ms >= ?", r.NodeID, r.TimeMs).Order("time_ms asc").Limit(1).Scan(&up).Error == nil && up.TimeMs > 0 {
+				if err := tx.Exec("UPDATE `alert` SET `state` = 'resolved', `resolved_at_ms` = ?, `resolved_by` = 'auto' WHERE `id` = ?", up.TimeMs, r.ID).Error; err != nil {
+					return err
+				}
+			}
+		}
+		// rule alerts whose episode is no longer firing
+		if err := tx.Exec("UPDATE `alert` SET `state` = 'resolved', `resolved_by` = 'auto' WHERE (`state` IS NULL OR `state` = '') AND `type` = 'rule' AND `id` NOT IN (SELECT `alert_id` FROM `alert_rule_state` WHERE `firing` = 1)").Error; err != nil {
+			return err
+		}
+		return tx.Exec("UPDATE `alert` SET `state` = 'open' WHERE (`state` IS NULL OR `state` = '')").Error
+	}},
+	{Version: 4, Name: "backfill_statistics_raw_flow", Up: func(tx *gorm.DB) error {
+		// buckets recorded before the traffic ratio was applied hold raw bytes
+		return tx.Exec("UPDATE `statistics_flow` SET `raw_flow` = `flow` WHERE `raw_flow` = 0 OR `raw_flow` IS NULL").Error
+	}},
+	{Version: 5, Name: "recompute_billed_user_flow", Up: recomputeBilledFlow},
+	{Version: 6, Name: "reset_legacy_tunnel_protocol", Up: func(tx *gorm.DB) error {
+		// tunnel.protocol used to be stored but ignored (relays always ran grpc);
+		// clear it so existing tunnels keep grpc instead of switching transport.
+		// Rows with transport options were written after the field was honored.
+		return tx.Exec("UPDATE `tunnel` SET `protocol` = NULL WHERE `transport_opts` IS NULL OR `transport_opts` = ''").Error
+	}},
+}
+
+// recomputeBilledFlow converts user and user_tunnel in_flow/out_flow from raw
+// bytes to billed bytes, which is how they are counted from this version on.
+// Each user_tunnel is charged with its tunnel's billing mode and traffic ratio
+// (same rules as controller/accounting.go); single-direction tunnels can only be
+// charged on the totals, not per report. The user counters change by the same
+// amount as their user_tunnel rows, so traffic outside a user_tunnel stays raw.
+func recomputeBilledFlow(tx *gorm.DB) error {
+	var tunnels []struct {
+		ID           int64
+		Flow         int
+		TrafficRatio *float64
+	}
+	if err := tx.Table("tunnel").Select("id, flow, traffic_ratio").Scan(&tunnels).Error; err != nil {
+		return err
+	}
+	type billing struct {
+		mode  int
+		ratio float64
+	}
+	bill := make(map[int64]billing, len(tunnels))
+	for _, t := range tunnels {
+		b := billing{mode: t.Flow, ratio: 1}
+		if b.mode < 1 || b.mode > 4 {
+			b.mode = 2
+		}
+		if t.TrafficRatio != nil && *t.TrafficRatio >= 0 {
+			b.ratio = *t.TrafficRatio
+		}
+		bill[t.ID] = b
+	}
+	scale := func(n int64, ratio float64) int64 { return int64(math.Round(float64(n) * ratio)) }
+
+	var uts []struct {
+		ID       int64
+		UserID   int64
+		TunnelID int64
+		InFlow   int64
+		OutFlow  int64
+	}
+	if err := tx.Table("user_tunnel").Select("id, user_id, tunnel_id, in_flow, out_flow").Scan(&uts).Error; err != nil {
+		return err
+	}
+	type delta struct{ in, out int64 }
+	users := map[int64]delta{}
+	for _, ut := range uts {
+		b, ok := bill[ut.TunnelID]
+		if !ok {
+			continue
+		}
+		in, out := ut.InFlow, ut.OutFlow
+		switch b.mode {
+		case 1: // the larger direction
+			if in >= out {
+				out = 0
+			} else {
+				in = 0
+			}
+		case 3: // upload only
+			out = 0
+		case 4: // download only
+			in = 0
+		}
+		in, out = scale(in, b.ratio), scale(out, b.ratio)
+		if in == ut.InFlow && out == ut.OutFlow {
+			continue
+		}
+		if err := tx.Table("user_tunnel").Where("id = ?", ut.ID).Updates(map[string]any{"in_flow": in, "out_flow": out}).Error; err != nil {
+			return err
+		}
+		d := users[ut.UserID]
+		d.in += in - ut.InFlow
+		d.out += out - ut.OutFlow
+		users[ut.UserID] = d
+	}
+	for id, d := range users {
+		var u struct{ InFlow, OutFlow int64 }
+		if err := tx.Table("user").Select("in_flow, out_flow").Where("id = ?", id).Scan(&u).Error; err != nil {
+			return err
+		}
+		// user counters may have been reset separately; never go below zero
+		in, out := max(u.InFlow+d.in, 0), max(u.OutFlow+d.out, 0)
+		if err := tx.Table("user").Where("id = ?", id).Updates(map[string]any{"in_flow": in, "out_flow": out}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureIndex creates a named index if it does not exist yet. The backtick quoting
+// is accepted by both MySQL and SQLite. A column written as "col:N" is indexed with
+// prefix length N on MySQL, where string fields are TEXT columns.
+func ensureIndex(tx *gorm.DB, table, name string, cols ...string) error {
+	if tx.Migrator().HasIndex(table, name) {
+		return nil
+	}
+	mysql := tx.Dialector.Name() == "mysql"
+	quoted := make([]string, 0, len(cols))
+	for _, c := range cols {
+		col, prefix, _ := strings.Cut(c, ":")
+		if mysql && prefix != "" {
+			quoted = append(quoted, "`"+col+"`("+prefix+")")
+		} else {
+			quoted = append(quoted, "`"+col+"`")
+		}
+	}
+	return tx.Exec(fmt.Sprintf("CREATE INDEX `%s` ON `%s` (%s)", name, table, strings.Join(quoted, ", "))).Error
+}
+
+// MigrationState describes a known migration and whether it has been applied.
+type MigrationState struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	AppliedAt int64  `json:"appliedAt,omitempty"`
+}
+
+// Migrate applies pending versioned migrations in order. Each step runs in its own
+// transaction and is recorded in schema_migration on success.
+func Migrate() error {
+	if err := DB.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+	applied, err := appliedMigrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range sortedMigrations() {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		start := time.Now()
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UnixMilli()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+		}
+		log.Printf("schema migration %d %s applied in %s", m.Version, m.Name, time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// MigrationStatus lists all known migrations with their applied time (0 if
+// pending). It only reads: without schema_migration nothing is applied yet.
+func MigrationStatus() ([]MigrationState, error) {
+	applied := map[int]int64{}
+	if DB.Migrator().HasTable(&schemaMigration{}) {
+		var err error
+		if applied, err = appliedMigrations(); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]MigrationState, 0, len(migrations))
+	for _, m := range sortedMigrations() {
+		out = append(out, MigrationState{Version: m.Version, Name: m.Name, AppliedAt: applied[m.Version]})
+	}
+	return out, nil
+}
+
+func appliedMigrations() (map[int]int64, error) {
+	var rows []schemaMigration
+	if err := DB.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[int]int64, len(rows))
+	for _, r := range rows {
+		out[r.Version] = r.AppliedAt
+	}
+	return out, nil
+}
+
+func sortedMigrations() []migration {
+	list := append([]migration(nil), migrations...)
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
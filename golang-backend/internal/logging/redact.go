@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+const redacted = "***"
+
+// sensitive key fragments, matched on the lower-cased key without '_' and '-'
+var (
+	redactExact     = map[string]bool{"pwd": true, "auth": true, "authorization": true, "apikey": true, "cookie": true}
+	redactFragments = []string{"password", "passwd", "secret", "token", "privatekey", "accesskey"}
+	extraRedactKeys = map[string]bool{}
+)
+
+var (
+	reQuerySecret = regexp.MustCompile(`(?i)((?:password|passwd|pwd|secret|token|key)=)[^&\s"']+`)
+	reURLUserinfo = regexp.MustCompile(`(://[^:/@\s"]+:)[^@\s"]+@`)
+)
+
+func normalizeKey(k string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(k))
+}
+
+func sensitiveKey(k string) bool {
+	n := normalizeKey(k)
+	if redactExact[n] || extraRedactKeys[n] {
+		return true
+	}
+	for _, f := range redactFragments {
+		if strings.Contains(n, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns a copy of v with sensitive map entries replaced and secrets in
+// strings (query parameters, URL credentials, embedded JSON) masked.
+func Redact(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, val := range t {
+			if sensitiveKey(k) {
+				out[k] = redacted
+			} else {
+				out[k] = Redact(val)
+			}
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]string, len(t))
+		for k, val := range t {
+			if sensitiveKey(k) {
+				out[k] = redacted
+			} else {
+				out[k] = redactString(val)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i := range t {
+			out[i] = Redact(t[i])
+		}
+		return out
+	case []map[string]any:
+		out := make([]any, len(t))
+		for i := range t {
+			out[i] = Redact(t[i])
+		}
+		return out
+	case string:
+		return redactString(t)
+	case json.RawMessage:
+		return json.RawMessage(redactJSON(t))
+	case []byte:
+		return redactString(string(t))
+	case nil, bool, int, int64, float64, error:
+		return v
+	}
+	// structs and other typed values: redact their JSON form
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var generic any
+	if json.Unmarshal(b, &generic) != nil {
+		return v
+	}
+	return Redact(generic)
+}
+
+func redactString(s string) string {
+	trimmed := strings.TrimSpace(s)
+	if len(trimmed) > 1 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		if b := redactJSON([]byte(trimmed)); b != nil {
+			return string(b)
+		}
+	}
+	s = reQuerySecret.ReplaceAllString(s, "${1}"+redacted)
+	return reURLUserinfo.ReplaceAllString(s, "${1}"+redacted+"@")
+}
+
+// redactJSON redacts a JSON document; invalid JSON is returned with only the
+// string-level patterns applied.
+func redactJSON(b []byte) []byte {
+	var generic any
+	if json.Unmarshal(b, &generic) != nil {
+		s := reQuerySecret.ReplaceAllString(string(b), "${1}"+redacted)
+		return []byte(reURLUserinfo.ReplaceAllString(s, "${1}"+redacted+"@"))
+	}
+	out, err := json.Marshal(Redact(generic))
+	if err != nil {
+		return b
+	}
+	return out
+}
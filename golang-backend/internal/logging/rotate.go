@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatingFile is an io.Writer that renames the current file to
+// <name>-<timestamp><ext> once it exceeds maxSize or has been open for interval,
+// and prunes rotated files by age and count.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	maxAge     time.Duration
+	maxBackups int
+
+	f        *os.File
+	size     int64
+	openedAt time.Time
+}
+
+func newRotatingFile(path string, maxSize int64, interval, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, maxSize: maxSize, interval: interval, maxAge: maxAge, maxBackups: maxBackups}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(rf.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	rf.f = f
+	rf.size = 0
+	rf.openedAt = time.Now()
+	if st, err := f.Stat(); err == nil {
+		rf.size = st.Size()
+		// an existing file keeps its age across restarts
+		if rf.size > 0 {
+			rf.openedAt = st.ModTime()
+		}
+	}
+	return nil
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.size > 0 && ((rf.maxSize > 0 && rf.size+int64(len(p)) > rf.maxSize) ||
+		(rf.interval > 0 && time.Since(rf.openedAt) >= rf.interval)) {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *rotatingFile) rotate() error {
+	_ = rf.f.Close()
+	ext := filepath.Ext(rf.path)
+	base := strings.TrimSuffix(rf.path, ext)
+	_ = os.Rename(rf.path, base+"-"+time.Now().Format("20060102T150405.000")+ext)
+	if err := rf.open(); err != nil {
+		return err
+	}
+	go rf.prune()
+	return nil
+}
+
+// prune deletes rotated files beyond maxBackups or older than maxAge.
+func (rf *rotatingFile) prune() {
+	ext := filepath.Ext(rf.path)
+	base := strings.TrimSuffix(rf.path, ext)
+	files, _ := filepath.Glob(base + "-*" + ext)
+	// timestamps sort lexically; newest first
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	cutoff := time.Now().Add(-rf.maxAge)
+	for i, f := range files {
+		if rf.maxBackups > 0 && i >= rf.maxBackups {
+			_ = os.Remove(f)
+			continue
+		}
+		if rf.maxAge > 0 {
+			if st, err := os.Stat(f); err == nil && st.ModTime().Before(cutoff) {
+				_ = os.Remove(f)
+			}
+		}
+	}
+}
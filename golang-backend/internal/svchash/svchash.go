@@ -0,0 +1,93 @@
+// Package svchash computes the content hash of a gost service that the panel
+// and the agent compare to find services whose gost.json entry drifted from the
+// desired config. It only depends on the standard library so the agent can use
+// it as well.
+package svchash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Canonical returns the service as plain JSON values with null and empty
+// object members removed, so a config built by the panel and the same config
+// read back from gost.json compare equal. Numbers become float64 and object
+// keys are sorted when marshalled.
+func Canonical(svc map[string]any) any {
+	b, err := json.Marshal(svc)
+	if err != nil {
+		return nil
+	}
+	var v any
+	if json.Unmarshal(b, &v) != nil {
+		return nil
+	}
+	return prune(v)
+}
+
+func prune(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, x := range t {
+			x = prune(x)
+			if m, ok := x.(map[string]any); x == nil || (ok && len(m) == 0) {
+				delete(t, k)
+				continue
+			}
+			t[k] = x
+		}
+		return t
+	case []any:
+		for i := range t {
+			t[i] = prune(t[i])
+		}
+		return t
+	}
+	return v
+}
+
+// Hash returns the hex SHA-256 of the canonical service JSON. The panel-only
+// members _chains and _observers take part: the agent stores them in the
+// top-level chains/observers of gost.json and attaches them back (see
+// WithRefs) before hashing its local entry.
+func Hash(svc map[string]any) string {
+	b, _ := json.Marshal(Canonical(svc))
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// WithRefs returns a copy of a gost.json service with the chain named by
+// handler.chain and the observer named by observer attached as _chains and
+// _observers, the shape the panel sends them in.
+func WithRefs(svc map[string]any, cfg map[string]any) map[string]any {
+	out := make(map[string]any, len(svc)+2)
+	for k, v := range svc {
+		out[k] = v
+	}
+	if h, _ := svc["handler"].(map[string]any); h != nil {
+		if name, _ := h["chain"].(string); name != "" {
+			if c := findNamed(cfg["chains"], name); c != nil {
+				out["_chains"] = []any{c}
+			}
+		}
+	}
+	if name, _ := svc["observer"].(string); name != "" {
+		if o := findNamed(cfg["observers"], name); o != nil {
+			out["_observers"] = []any{o}
+		}
+	}
+	return out
+}
+
+func findNamed(list any, name string) map[string]any {
+	arr, _ := list.([]any)
+	for _, it := range arr {
+		if m, ok := it.(map[string]any); ok {
+			if n, _ := m["name"].(string); n == name {
+				return m
+			}
+		}
+	}
+	return nil
+}
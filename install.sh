@@ -245,10 +245,10 @@ install_flux_agent_go_bin() {
   esac
   local target="$INSTALL_DIR/flux-agent"
   # 优先从面板下载（后端容器已内置 /flux-agent 路由）
-  if curl -fsSL "http://$SERVER_ADDR/flux-agent/$file" -o "$target"; then
+  if curl -fsSL "$HTTP_PROTO://$SERVER_ADDR/flux-agent/$file" -o "$target"; then
     chmod +x "$target"; return 0
   fi
-  echo "http://$SERVER_ADDR/flux-agent/$file"
+  echo "$HTTP_PROTO://$SERVER_ADDR/flux-agent/$file"
   return 1
 }
 
@@ -267,8 +267,8 @@ install_flux_agent() {
   local tmpfile
   local AGENT_FILE="$INSTALL_DIR/flux-agent"
   tmpfile=$(mktemp -p /tmp flux-agent.XXXX || echo "/tmp/flux-agent.tmp")
-  echo "http://$SERVER_ADDR/flux-agent/$file"
-  if curl -fSL --retry 3 --retry-delay 1 "http://$SERVER_ADDR/flux-agent/$file" -o "$tmpfile"; then
+  echo "$HTTP_PROTO://$SERVER_ADDR/flux-agent/$file"
+  if curl -fSL --retry 3 --retry-delay 1 "$HTTP_PROTO://$SERVER_ADDR/flux-agent/$file" -o "$tmpfile"; then
     install -m 0755 "$tmpfile" "$AGENT_FILE" && rm -f "$tmpfile"
   else
     echo "❌ 无法下载 flux-agent 二进制"
@@ -285,8 +285,14 @@ ADDR=
 # 节点密钥，为空则默认读取 /etc/gost/config.json 的 secret
 SECRET=
 # WebSocket 协议：ws 或 wss
-SCHEME=ws
+SCHEME=${SCHEME:-ws}
 EOF
+  elif [[ -n "$SCHEME" ]]; then
+    if grep -q '^SCHEME=' "$AGENT_ENV"; then
+      sed -i "s/^SCHEME=.*/SCHEME=$SCHEME/" "$AGENT_ENV"
+    else
+      echo "SCHEME=$SCHEME" >> "$AGENT_ENV"
+    fi
   fi
 
   # 写入 systemd 服务
@@ -318,15 +324,23 @@ EOF
 # 解析命令行参数
 PROXY_MODE=""
 PROXY_PREFIX=""
-while getopts "a:s:p:" opt; do
+SCHEME=""
+while getopts "a:s:p:S:" opt; do
   case $opt in
     a) SERVER_ADDR="$OPTARG" ;;
     s) SECRET="$OPTARG" ;;
     p) PROXY_MODE="$OPTARG" ;;
+    S) SCHEME="$OPTARG" ;;
     *) echo "❌ 无效参数"; exit 1 ;;
   esac
 done
 
+# 面板启用 TLS 时（-S wss）通过 https 下载 Agent
+HTTP_PROTO="http"
+if [[ "$SCHEME" == "wss" ]]; then
+  HTTP_PROTO="https"
+fi
+
 # 设置代理前缀（用于 GitHub 下载加速）
 if [[ "$PROXY_MODE" == "4" ]]; then
   PROXY_PREFIX="https://proxy.529851.xyz/"
@@ -59,6 +59,8 @@ export const setTunnelIface = (tunnelId: number, ifaces: Array<{nodeId:number, i
 // 隧道每个节点入站绑定IP（listener 绑定 IP）
 export const getTunnelBind = (tunnelId: number) => Network.post("/tunnel/bind/get", { tunnelId });
 export const setTunnelBind = (tunnelId: number, binds: Array<{nodeId:number, ip:string}>) => Network.post("/tunnel/bind/set", { tunnelId, binds });
+export const redeployTunnel = (tunnelId: number) => Network.post("/tunnel/redeploy", { tunnelId });
+export const getTunnelRedeployStatus = (jobId: string) => Network.post("/tunnel/redeploy/status", { jobId });
 
 // EasyTier组网
 export const etStatus = () => Network.get("/easytier/status");
@@ -71,6 +73,11 @@ export const etChangePeer = (data:{ nodeId:number; peerNodeId:number; }) => Netw
 export const etAutoAssign = (mode:string = 'chain') => Network.post("/easytier/auto-assign", { mode });
 export const etRedeployMaster = () => Network.post("/easytier/redeploy-master", {});
 export const listNodeOps = (params: { nodeId?: number; limit?: number; requestId?: string }) => Network.post("/node/ops", params);
+export const listNodeQueue = (nodeId?: number) => Network.post("/node/queue/list", nodeId ? { nodeId } : {});
+export const retryNodeQueue = (nodeId: number) => Network.post("/node/queue/retry", { nodeId });
+export const deleteNodeQueue = (params: { id?: number; nodeId?: number }) => Network.post("/node/queue/delete", params);
+export const listNodeDrift = (nodeId?: number) => Network.post("/node/drift/list", nodeId ? { nodeId } : {});
+export const fixNodeDrift = (nodeId: number, removeExtras = false) => Network.post("/node/drift/fix", { nodeId, removeExtras });
 
 // 用户隧道权限管理操作 - 全部使用POST请求
 export const assignUserTunnel = (data: any) => Network.post("/tunnel/user/assign", data);
@@ -145,6 +152,7 @@ export const upgradeToLatest = (proxyPrefix?: string) => Network.post("/version/
 export const getNodeInterfaces = (nodeId: number) => Network.post("/node/interfaces", { nodeId });
 // 节点系统信息（时间序列）
 export const getNodeSysinfo = (nodeId: number, range: string = '1h', limit?: number) => Network.post("/node/sysinfo", { nodeId, range, limit });
+export const getNodeBandwidth = (nodeId: number, range: string = '1h', iface?: string) => Network.post("/node/bandwidth", { nodeId, range, iface });
 
 // Share (public, read-only)
 export const shareNetworkList = (range: string = '1h') => Network.post("/share/network-list", { range });
@@ -0,0 +1,79 @@
+import { useEffect, useState } from 'react';
+import { Modal, ModalBody, ModalContent, ModalFooter, ModalHeader } from "@heroui/modal";
+import { Button } from "@heroui/button";
+import { Chip } from "@heroui/chip";
+import toast from 'react-hot-toast';
+import { listNodeQueue, retryNodeQueue, deleteNodeQueue } from '@/api';
+
+interface QueueNode { nodeId:number; nodeName:string; online:boolean; pending:number; failed:number }
+interface QueueCommand { id:number; nodeId:number; cmd:string; services:string; status:string; attempts:number; lastError?:string; createdTime:number }
+
+export default function CommandQueueModal({ isOpen, onOpenChange }:{ isOpen:boolean; onOpenChange:(open:boolean)=>void }){
+  const [nodes, setNodes] = useState<QueueNode[]>([]);
+  const [commands, setCommands] = useState<QueueCommand[]>([]);
+  const [loading, setLoading] = useState(false);
+
+  const load = async()=>{
+    setLoading(true);
+    try{
+      const r:any = await listNodeQueue();
+      setNodes(r.code===0 ? (r.data?.nodes||[]) : []);
+      setCommands(r.code===0 ? (r.data?.commands||[]) : []);
+    }catch{ setNodes([]); setCommands([]); } finally{ setLoading(false); }
+  };
+
+  useEffect(()=>{ if (isOpen) load(); }, [isOpen]);
+
+  const retry = async(nodeId:number)=>{
+    const r:any = await retryNodeQueue(nodeId);
+    if (r.code===0) toast.success(r.msg||'已重新排队'); else toast.error(r.msg||'操作失败');
+    load();
+  };
+  const remove = async(params:{ id?:number; nodeId?:number })=>{
+    const r:any = await deleteNodeQueue(params);
+    if (r.code===0) toast.success(r.msg||'已删除'); else toast.error(r.msg||'删除失败');
+    load();
+  };
+
+  return (
+    <Modal isOpen={isOpen} onOpenChange={onOpenChange} scrollBehavior="outside">
+      <ModalContent className="w-[80vw] max-w-[80vw] h-[80vh]">
+        {(onClose)=> (
+          <>
+            <ModalHeader className="flex items-center justify-between">
+              <div>待下发命令</div>
+              <Button size="sm" variant="flat" onPress={load} isDisabled={loading}>{loading? '刷新中...':'刷新'}</Button>
+            </ModalHeader>
+            <ModalBody className="overflow-auto">
+              {nodes.length===0 ? <div className="text-sm text-default-500">暂无待下发命令</div> : nodes.map(n => (
+                <div key={n.nodeId} className="border border-divider rounded p-3 space-y-2">
+                  <div className="flex items-center justify-between">
+                    <div className="flex items-center gap-2 text-sm">
+                      <span className="font-medium">{n.nodeName || `#${n.nodeId}`}</span>
+                      <Chip size="sm" variant="flat" color={n.online? 'success':'default'}>{n.online? '在线':'离线'}</Chip>
+                      <span className="text-default-500">待下发 {n.pending}{n.failed>0? ` · 失败 ${n.failed}`:''}</span>
+                    </div>
+                    <div className="flex gap-2">
+                      {n.failed>0 && <Button size="sm" variant="flat" color="primary" onPress={()=>retry(n.nodeId)}>重试失败</Button>}
+                      <Button size="sm" variant="flat" color="danger" onPress={()=>remove({ nodeId: n.nodeId })}>清空</Button>
+                    </div>
+                  </div>
+                  <pre className="max-h-[30vh] overflow-auto whitespace-pre-wrap text-2xs bg-default-100 p-2 rounded">
+{commands.filter(c => c.nodeId===n.nodeId).map(c => {
+  const t = new Date(c.createdTime).toLocaleString();
+  const st = c.status==='failed' ? `失败(${c.attempts}次)` : (c.attempts>0 ? `待重试(${c.attempts}次)` : '待下发');
+  return `#${c.id} [${t}] ${c.cmd} ${c.services}  ${st}${c.lastError? '  '+c.lastError:''}`;
+}).join('\n')}
+                  </pre>
+                </div>
+              ))}
+            </ModalBody>
+            <ModalFooter>
+              <Button variant="light" onPress={onClose}>关闭</Button>
+            </ModalFooter>
+          </>
+        )}
+      </ModalContent>
+    </Modal>
+  );
+}
@@ -0,0 +1,82 @@
+import { useEffect, useState } from 'react';
+import { Modal, ModalBody, ModalContent, ModalFooter, ModalHeader } from "@heroui/modal";
+import { Button } from "@heroui/button";
+import { Chip } from "@heroui/chip";
+import toast from 'react-hot-toast';
+import { listNodeDrift, fixNodeDrift } from '@/api';
+
+interface DriftReport { nodeId:number; nodeName:string; online:boolean; missing:string; changed:string; extra:string; strict:boolean; desiredCount:number; checkedTime:number; fixedTime:number; fixResult:string }
+
+const names = (s:string) => s ? s.split(',') : [];
+
+export default function DriftModal({ isOpen, onOpenChange }:{ isOpen:boolean; onOpenChange:(open:boolean)=>void }){
+  const [reports, setReports] = useState<DriftReport[]>([]);
+  const [loading, setLoading] = useState(false);
+  const [fixing, setFixing] = useState<number|null>(null);
+
+  const load = async()=>{
+    setLoading(true);
+    try{
+      const r:any = await listNodeDrift();
+      setReports(r.code===0 && Array.isArray(r.data) ? r.data : []);
+    }catch{ setReports([]); } finally{ setLoading(false); }
+  };
+
+  useEffect(()=>{ if (isOpen) load(); }, [isOpen]);
+
+  const fix = async(nodeId:number, removeExtras:boolean)=>{
+    setFixing(nodeId);
+    try{
+      const r:any = await fixNodeDrift(nodeId, removeExtras);
+      if (r.code===0) toast.success(r.msg||'已修复'); else toast.error(r.msg||'修复失败');
+    }catch{ toast.error('网络错误，请重试'); } finally{ setFixing(null); load(); }
+  };
+
+  return (
+    <Modal isOpen={isOpen} onOpenChange={onOpenChange} scrollBehavior="outside">
+      <ModalContent className="w-[80vw] max-w-[80vw] h-[80vh]">
+        {(onClose)=> (
+          <>
+            <ModalHeader className="flex items-center justify-between">
+              <div>服务漂移</div>
+              <Button size="sm" variant="flat" onPress={load} isDisabled={loading}>{loading? '刷新中...':'刷新'}</Button>
+            </ModalHeader>
+            <ModalBody className="overflow-auto">
+              {reports.length===0 ? <div className="text-sm text-default-500">暂无漂移报告</div> : reports.map(d => {
+                const missing = names(d.missing), changed = names(d.changed), extra = names(d.extra);
+                const drifted = missing.length + changed.length + extra.length > 0;
+                return (
+                  <div key={d.nodeId} className="border border-divider rounded p-3 space-y-2">
+                    <div className="flex items-center justify-between">
+                      <div className="flex items-center gap-2 text-sm">
+                        <span className="font-medium">{d.nodeName || `#${d.nodeId}`}</span>
+                        <Chip size="sm" variant="flat" color={d.online? 'success':'default'}>{d.online? '在线':'离线'}</Chip>
+                        <Chip size="sm" variant="flat" color={drifted? 'warning':'success'}>{drifted? '有漂移':'一致'}</Chip>
+                        <span className="text-default-500">期望 {d.desiredCount} · 检查于 {new Date(d.checkedTime).toLocaleString()}</span>
+                      </div>
+                      {drifted && (
+                        <div className="flex gap-2">
+                          <Button size="sm" variant="flat" color="primary" isLoading={fixing===d.nodeId} isDisabled={!d.online} onPress={()=>fix(d.nodeId, false)}>修复</Button>
+                          {extra.length>0 && <Button size="sm" variant="flat" color="danger" isDisabled={!d.online || fixing===d.nodeId} onPress={()=>fix(d.nodeId, true)}>修复并删除多余</Button>}
+                        </div>
+                      )}
+                    </div>
+                    {drifted && (
+                      <pre className="max-h-[30vh] overflow-auto whitespace-pre-wrap text-2xs bg-default-100 p-2 rounded">
+{[missing.length>0 ? `缺失: ${missing.join(', ')}` : '', changed.length>0 ? `不一致: ${changed.join(', ')}` : '', extra.length>0 ? `多余: ${extra.join(', ')}` : ''].filter(Boolean).join('\n')}
+                      </pre>
+                    )}
+                    {d.fixedTime>0 && <div className="text-xs text-default-500">上次修复 {new Date(d.fixedTime).toLocaleString()}：{d.fixResult}</div>}
+                  </div>
+                );
+              })}
+            </ModalBody>
+            <ModalFooter>
+              <Button variant="light" onPress={onClose}>关闭</Button>
+            </ModalFooter>
+          </>
+        )}
+      </ModalContent>
+    </Modal>
+  );
+}
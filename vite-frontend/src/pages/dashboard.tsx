@@ -13,6 +13,7 @@ interface UserInfo {
   flow: number;
   inFlow: number;
   outFlow: number;
+  usedBilled?: number;
   num: number;
   expTime?: string;
   flowResetTime?: number;
@@ -78,22 +79,10 @@ export default function DashboardPage() {
   const animStartRef = useRef<number>(0);
   const animDurRef = useRef<number>(800); // 动画时长(ms)
 
-  // 计算总流量：
-  // - 双向隧道：sum(inFlow + outFlow)
-  // - 单向隧道：sum(max(inFlow, outFlow))
-  const computeTotalFlowBytes = () => {
-    const tfMap = new Map<number, number>();
-    userTunnels.forEach(t => tfMap.set(t.tunnelId, t.tunnelFlow || 2));
-    let sum = 0;
-    forwardList.forEach(f => {
-      const tf = tfMap.get(f.tunnelId) || 2;
-      const inB = Number(f.inFlow || 0);
-      const outB = Number(f.outFlow || 0);
-      if (tf === 1) sum += Math.max(inB, outB); else sum += (inB + outB);
-    });
-    return sum;
-  };
-  const totalFlowBytes = computeTotalFlowBytes();
+  // 总流量取后端的计费口径（usedBilled，已按隧道计费方式与流量倍率计算）
+  const totalFlowBytes = typeof userInfo.usedBilled === 'number'
+    ? userInfo.usedBilled
+    : Number(userInfo.inFlow || 0) + Number(userInfo.outFlow || 0);
   const totalFlowText = (() => {
     const v = totalFlowBytes;
     if (v < 1024) return v + ' B';
@@ -424,12 +413,7 @@ export default function DashboardPage() {
     }
   };
 
-  const calculateUserTotalUsedFlow = (): number => {
-    // 优先使用后端给的计费口径（usedBilled）；否则使用前端按单/双向规则汇总（totalFlowBytes）
-    const billed = (userInfo as any).usedBilled;
-    if (typeof billed === 'number' && billed >= 0) return billed;
-    return totalFlowBytes;
-  };
+  const calculateUserTotalUsedFlow = (): number => totalFlowBytes;
 
   const calculateUsagePercentage = (type: 'flow' | 'forwards'): number => {
     if (type === 'flow') {
@@ -729,7 +713,7 @@ export default function DashboardPage() {
                      </svg>
                    </div>
                  </div>
-                 {/* 显示基于前端汇总规则的总流量 */}
+                 {/* 后端计费口径的总流量 */}
                  <p className="text-base lg:text-xl font-bold text-foreground truncate">{totalFlowText}</p>
                </div>
            </CardBody>
@@ -939,7 +923,7 @@ export default function DashboardPage() {
                            <h3 className="font-semibold text-foreground">{tunnel.tunnelName} ID: {tunnel.id}</h3>
                            <div className="flex flex-wrap items-center gap-2 mt-1">
                              <span className={`px-2 py-1 rounded-md text-xs font-medium ${tunnel.tunnelFlow === 1 ? 'bg-blue-100 dark:bg-blue-500/20 text-blue-700 dark:text-blue-300' : 'bg-orange-100 dark:bg-orange-500/20 text-orange-700 dark:text-orange-300'}`}>
-                               {tunnel.tunnelFlow === 1 ? '单向计费' : '双向计费'}
+                               {({ 1: '单向计费', 3: '仅上传计费', 4: '仅下载计费' } as Record<number, string>)[tunnel.tunnelFlow || 2] || '双向计费'}
                              </span>
                              <span className={`px-2 py-1 rounded-md text-xs font-medium border ${tunnelExpStatus.bg} ${tunnelExpStatus.color}`}>
                                {tunnelExpStatus.text}
@@ -65,6 +65,7 @@ interface Forward {
   remoteAddr: string;
   interfaceName?: string;
   strategy: string;
+  protocol?: string; // tcp | udp | both，缺省为 tcp
   status: number;
   inFlow: number;
   outFlow: number;
@@ -95,6 +96,7 @@ interface ForwardForm {
   remoteAddr: string;
   interfaceName?: string;
   strategy: string;
+  protocol: string;
   // SS 参数移除，统一在节点信息“出口服务”里设置
 }
 
@@ -212,7 +214,7 @@ export default function ForwardPage() {
     remoteAddr: '',
     interfaceName: '',
     strategy: 'fifo',
-    
+    protocol: 'tcp',
   });
   
   // 表单验证错误
@@ -531,11 +533,16 @@ export default function ForwardPage() {
       const domainPattern = /^[a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?)*:\d+$/;
       
       for (let i = 0; i < addresses.length; i++) {
-        const addr = addresses[i];
+        // 可选权重后缀：地址#权重（1-100）
+        const [addr, weight] = addresses[i].split('#').map(v => v.trim());
         if (!ipv4Pattern.test(addr) && !ipv6FullPattern.test(addr) && !domainPattern.test(addr)) {
           newErrors.remoteAddr = `第${i + 1}行地址格式错误`;
           break;
         }
+        if (weight !== undefined && !(/^\d+$/.test(weight) && Number(weight) >= 1 && Number(weight) <= 100)) {
+          newErrors.remoteAddr = `第${i + 1}行权重需为 1-100 的整数`;
+          break;
+        }
       }
     }
     
@@ -562,7 +569,8 @@ export default function ForwardPage() {
       inPort: null,
       remoteAddr: '',
       interfaceName: '',
-      strategy: 'fifo'
+      strategy: 'fifo',
+      protocol: 'tcp'
     });
     setSelectedTunnel(null);
     setErrors({});
@@ -580,7 +588,8 @@ export default function ForwardPage() {
       inPort: forward.inPort,
       remoteAddr: forward.remoteAddr.split(',').join('\n'),
       interfaceName: forward.interfaceName || '',
-      strategy: forward.strategy || 'fifo'
+      strategy: forward.strategy || 'fifo',
+      protocol: forward.protocol || 'tcp'
     });
     const tunnel = tunnels.find(t => t.id === forward.tunnelId);
     setSelectedTunnel(tunnel || null);
@@ -704,6 +713,7 @@ export default function ForwardPage() {
           remoteAddr: processedRemoteAddr,
           interfaceName: form.interfaceName,
           strategy: addressCount > 1 ? form.strategy : 'fifo',
+          protocol: form.protocol,
           
         };
         res = await updateForward(updateData);
@@ -716,6 +726,7 @@ export default function ForwardPage() {
           remoteAddr: processedRemoteAddr,
           interfaceName: form.interfaceName,
           strategy: addressCount > 1 ? form.strategy : 'fifo',
+          protocol: form.protocol,
           
         };
         res = await createForward(createData);
@@ -1190,7 +1201,7 @@ export default function ForwardPage() {
 
         // 验证远程地址格式 - 支持单个地址或多个地址用逗号分隔
         const addresses = remoteAddr.trim().split(',');
-        const addressPattern = /^[^:]+:\d+$/;
+        const addressPattern = /^[^:]+:\d+(#\d+)?$/;
         const isValidFormat = addresses.every(addr => addressPattern.test(addr.trim()));
         
         if (!isValidFormat) {
@@ -1287,6 +1298,8 @@ export default function ForwardPage() {
         return { color: 'success', text: '轮询' };
       case 'rand':
         return { color: 'warning', text: '随机' };
+      case 'hash':
+        return { color: 'secondary', text: '哈希' };
       default:
         return { color: 'default', text: '未知' };
     }
@@ -1890,17 +1903,34 @@ export default function ForwardPage() {
                     
                     <Textarea
                       label="远程地址"
-                      placeholder="请输入远程地址，多个地址用换行分隔&#10;例如:&#10;192.168.1.100:8080&#10;example.com:3000"
+                      placeholder="请输入远程地址，多个地址用换行分隔&#10;例如:&#10;192.168.1.100:8080&#10;example.com:3000#2"
                       value={form.remoteAddr}
                       onChange={(e) => setForm(prev => ({ ...prev, remoteAddr: e.target.value }))}
                       isInvalid={!!errors.remoteAddr}
                       errorMessage={errors.remoteAddr}
                       variant="bordered"
-                      description="格式: IP:端口 或 域名:端口，支持多个地址（每行一个）"
+                      description="格式: IP:端口 或 域名:端口，支持多个地址（每行一个），可用 #权重 设置负载权重"
                       minRows={3}
                       maxRows={6}
                     />
                     
+                    <Select
+                      label="转发协议"
+                      selectedKeys={[form.protocol]}
+                      onSelectionChange={(keys) => {
+                        const selectedKey = Array.from(keys)[0] as string;
+                        if (selectedKey) {
+                          setForm(prev => ({ ...prev, protocol: selectedKey }));
+                        }
+                      }}
+                      variant="bordered"
+                      description="UDP 适用于游戏、DNS 等场景；隧道转发的 UDP 经隧道中继"
+                    >
+                      <SelectItem key="tcp">TCP</SelectItem>
+                      <SelectItem key="udp">UDP</SelectItem>
+                      <SelectItem key="both">TCP + UDP</SelectItem>
+                    </Select>
+
                     <ForwardIfacePicker active={modalOpen} selectedTunnel={selectedTunnel} onSelect={(ip)=>setForm(prev=>({...prev, interfaceName: ip}))} />
 
                     {/* 只读预览：当前隧道的多级路径与每节点 IP 设置（在“隧道管理”维护） */}
@@ -1963,7 +1993,7 @@ export default function ForwardPage() {
                           setForm(prev => ({ ...prev, strategy: selectedKey }));
                         }}
                         variant="bordered"
-                        description="多个目标地址的负载均衡策略"
+                        description="多个目标地址的负载均衡策略；目标连续失败后会被暂时剔除"
                       >
                         <SelectItem key="fifo" >主备模式 - 自上而下</SelectItem>
                         <SelectItem key="round" >轮询模式 - 依次轮换</SelectItem>
@@ -114,7 +114,7 @@ export default function NetworkPage() {
           name: `${label} 丢包%`,
           showSymbol: false,
           yAxisIndex: 1,
-          data: arr.map((it:any)=>[it.timeMs, it.ok? 0 : 100])
+          data: arr.map((it:any)=>[it.timeMs, it.lossPct ?? (it.ok? 0 : 100)])
         });
       });
       chartInstanceRef.current.setOption({
@@ -11,6 +11,8 @@ import { Spinner } from "@heroui/spinner";
 import { Alert } from "@heroui/alert";
 import { Progress } from "@heroui/progress";
 import OpsLogModal from '@/components/OpsLogModal';
+import CommandQueueModal from '@/components/CommandQueueModal';
+import DriftModal from '@/components/DriftModal';
 import { Divider } from "@heroui/divider";
 import { queryNodeServices, getNodeNetworkStatsBatch, getVersionInfo } from "@/api";
 import toast from 'react-hot-toast';
@@ -120,6 +122,8 @@ export default function NodePage() {
   const [serverVersion, setServerVersion] = useState<string>('');
   const [agentVersion, setAgentVersion] = useState<string>('');
   const [opsOpen, setOpsOpen] = useState(false);
+  const [queueOpen, setQueueOpen] = useState(false);
+  const [driftOpen, setDriftOpen] = useState(false);
 
   useEffect(() => {
     loadNodes();
@@ -846,8 +850,10 @@ export default function NodePage() {
           </Card>
         ) : (
           <>
-          <div className="flex justify-end mb-2">
+          <div className="flex justify-end gap-2 mb-2">
             <Button size="sm" variant="flat" onPress={()=> setOpsOpen(true)}>操作日志</Button>
+            <Button size="sm" variant="flat" onPress={()=> setQueueOpen(true)}>待下发命令</Button>
+            <Button size="sm" variant="flat" onPress={()=> setDriftOpen(true)}>服务漂移</Button>
           </div>
           <div className="grid grid-cols-1 sm:grid-cols-2 lg:grid-cols-3 xl:grid-cols-2 2xl:grid-cols-3 gap-4">
             {nodeList.map((node) => (
@@ -1077,6 +1083,8 @@ export default function NodePage() {
             ))}
           </div>
           <OpsLogModal isOpen={opsOpen} onOpenChange={setOpsOpen} />
+          <CommandQueueModal isOpen={queueOpen} onOpenChange={setQueueOpen} />
+          <DriftModal isOpen={driftOpen} onOpenChange={setDriftOpen} />
           </>
         )}
 
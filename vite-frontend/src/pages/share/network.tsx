@@ -68,7 +68,7 @@ export default function ShareNetworkPage() {
       Object.keys(grouped).forEach((tid)=>{
         const arr = grouped[tid]; const label = detail.targets?.[tid]?.name || `目标${tid}`;
         series.push({ type:'line', sampling:'lttb', name:`${label} RTT`, showSymbol:false, yAxisIndex:0, data: arr.map((it:any)=>[it.timeMs, it.ok? it.rttMs : null])});
-        series.push({ type:'line', sampling:'lttb', name:`${label} 丢包%`, showSymbol:false, yAxisIndex:1, data: arr.map((it:any)=>[it.timeMs, it.ok? 0 : 100])});
+        series.push({ type:'line', sampling:'lttb', name:`${label} 丢包%`, showSymbol:false, yAxisIndex:1, data: arr.map((it:any)=>[it.timeMs, it.lossPct ?? (it.ok? 0 : 100)])});
       });
       chartInstanceRef.current.setOption({ tooltip:{trigger:'axis'}, legend:{type:'scroll'}, dataZoom:[{type:'inside', throttle:50},{type:'slider', height:20}], xAxis:{type:'time'}, yAxis:[{type:'value', name:'RTT (ms)'},{type:'value', name:'丢包(%)', min:0, max:100, axisLabel:{formatter:'{value}%'}}], series, grid:{left:40,right:20,top:40,bottom:30} });
     };
@@ -1,7 +1,7 @@
 import { useState, useEffect } from "react";
 import { Card, CardBody, CardHeader } from "@heroui/card";
 import { Button } from "@heroui/button";
-import { Input } from "@heroui/input";
+import { Input, Textarea } from "@heroui/input";
 import { Select, SelectItem } from "@heroui/select";
 import { Modal, ModalContent, ModalHeader, ModalBody, ModalFooter } from "@heroui/modal";
 import { Chip } from "@heroui/chip";
@@ -32,10 +32,11 @@ interface Tunnel {
   inIp: string;
   outIp?: string;
   protocol?: string;
+  transportOpts?: string; // 传输参数 JSON，如 {"sni":"example.com"}
   tcpListenAddr: string;
   udpListenAddr: string;
   interfaceName?: string;
-  flow: number; // 1: 单向, 2: 双向
+  flow: number; // 1: 单向(取较大者), 2: 双向, 3: 仅上传, 4: 仅下载
   trafficRatio: number;
   status: number;
   createdTime: string;
@@ -54,6 +55,7 @@ interface TunnelForm {
   inNodeId: number | null;
   outNodeId?: number | null;
   protocol: string;
+  transportOpts: string;
   tcpListenAddr: string;
   udpListenAddr: string;
   interfaceName?: string;
@@ -119,7 +121,8 @@ export default function TunnelPage() {
     type: 1,
     inNodeId: null,
     outNodeId: null,
-    protocol: 'tls',
+    protocol: 'grpc',
+    transportOpts: '',
     tcpListenAddr: '[::]',
     udpListenAddr: '[::]',
     interfaceName: '',
@@ -221,6 +224,14 @@ export default function TunnelPage() {
       if (!form.protocol) {
         newErrors.protocol = '请选择协议类型';
       }
+      if (form.transportOpts.trim()) {
+        try {
+          const v = JSON.parse(form.transportOpts);
+          if (typeof v !== 'object' || v === null || Array.isArray(v)) throw new Error();
+        } catch {
+          newErrors.transportOpts = '传输参数需为 JSON 对象';
+        }
+      }
     }
     
     setErrors(newErrors);
@@ -235,7 +246,8 @@ export default function TunnelPage() {
       type: 1,
       inNodeId: null,
       outNodeId: null,
-      protocol: 'tls',
+      protocol: 'grpc',
+      transportOpts: '',
       tcpListenAddr: '[::]',
       udpListenAddr: '[::]',
       interfaceName: '',
@@ -260,7 +272,8 @@ export default function TunnelPage() {
       type: tunnel.type,
       inNodeId: tunnel.inNodeId,
       outNodeId: tunnel.outNodeId || null,
-      protocol: tunnel.protocol || 'tls',
+      protocol: tunnel.protocol || 'grpc',
+      transportOpts: tunnel.transportOpts || '',
       tcpListenAddr: tunnel.tcpListenAddr || '[::]',
       udpListenAddr: tunnel.udpListenAddr || '[::]',
       interfaceName: tunnel.interfaceName || '',
@@ -327,11 +340,29 @@ export default function TunnelPage() {
       ...prev,
       type,
       outNodeId: type === 1 ? null : prev.outNodeId,
-      protocol: type === 1 ? 'tls' : prev.protocol
+      protocol: type === 1 ? 'grpc' : prev.protocol
     }));
     setExitDeployed("");
   };
 
+  // 跟踪隧道修改触发的转发重新下发任务
+  const watchRedeploy = async (jobId: string) => {
+    toast('正在重新下发该隧道的转发...');
+    const { getTunnelRedeployStatus } = await import('@/api');
+    for (let i = 0; i < 60; i++) {
+      await new Promise(r => setTimeout(r, 2000));
+      const r: any = await getTunnelRedeployStatus(jobId).catch(() => null);
+      if (!r || r.code !== 0) return;
+      const job = r.data || {};
+      if (job.status === 'done') { toast.success(`转发重新下发完成（${job.total} 条）`); return; }
+      if (job.status === 'error') {
+        const nodes = (job.nodes || []).filter((n: any) => n.failed > 0).map((n: any) => n.nodeName || n.nodeId);
+        toast.error(`${job.error || '重新下发失败'}${nodes.length ? '：' + nodes.join(', ') : ''}`);
+        return;
+      }
+    }
+  };
+
   // 提交表单
   const handleSubmit = async () => {
     if (!validateForm()) return;
@@ -345,6 +376,7 @@ export default function TunnelPage() {
         : await createTunnel(data);
         
       if (response.code === 0) {
+        let jobId: string = (response.data as any)?.jobId || '';
         // 保存多级路径、每节点出站接口IP、以及每节点监听IP（仅隧道转发）
         try {
           const { setTunnelPath, getTunnelList, setTunnelIface, setTunnelBind } = await import('@/api');
@@ -357,20 +389,21 @@ export default function TunnelPage() {
             }
           }
           if (tid) {
-            if (midPath.length>0) await setTunnelPath(tid as number, midPath);
+            if (midPath.length>0) { const r:any = await setTunnelPath(tid as number, midPath); jobId = r?.data?.jobId || jobId; }
             // 出站接口IP（入口及中间）
             const ifaces: Array<{nodeId:number, ip:string}> = [];
             if (form.inNodeId) ifaces.push({ nodeId: form.inNodeId, ip: entryIface||'' });
             midPath.forEach(nid=>{ ifaces.push({ nodeId: nid, ip: midIfaces[nid]||'' }); });
-            if (ifaces.length>0) await setTunnelIface(tid as number, ifaces);
+            if (ifaces.length>0) { const r:any = await setTunnelIface(tid as number, ifaces); jobId = r?.data?.jobId || jobId; }
             // 入站绑定IP（中间与出口）——仅隧道转发需要出口绑定，端口转发忽略出口
             const binds: Array<{nodeId:number, ip:string}> = [];
             midPath.forEach(nid=>{ binds.push({ nodeId: nid, ip: (midBindIps[nid]||'') }); });
             if (form.type===2 && form.outNodeId) binds.push({ nodeId: form.outNodeId, ip: (exitBindIp||'') });
-            if (binds.length>0) await setTunnelBind(tid as number, binds);
+            if (binds.length>0) { const r:any = await setTunnelBind(tid as number, binds); jobId = r?.data?.jobId || jobId; }
           }
         } catch {}
         toast.success(isEdit ? '更新成功' : '创建成功');
+        if (jobId) watchRedeploy(jobId);
         // 入口/出口服务由转发创建时一并配置（forward.create/update 负责下发），此处不再直接创建SS
         setModalOpen(false);
         loadData();
@@ -502,6 +535,10 @@ export default function TunnelPage() {
         return '单向计算';
       case 2:
         return '双向计算';
+      case 3:
+        return '仅上传';
+      case 4:
+        return '仅下载';
       default:
         return '未知';
     }
@@ -801,8 +838,10 @@ export default function TunnelPage() {
                         errorMessage={errors.flow}
                         variant="bordered"
                       >
-                        <SelectItem key="1">单向计算（仅上传）</SelectItem>
+                        <SelectItem key="1">单向计算（取上传/下载较大者）</SelectItem>
                         <SelectItem key="2">双向计算（上传+下载）</SelectItem>
+                        <SelectItem key="3">仅上传</SelectItem>
+                        <SelectItem key="4">仅下载</SelectItem>
                       </Select>
 
                       <Input
@@ -1057,14 +1096,30 @@ export default function TunnelPage() {
                           errorMessage={errors.protocol}
                           variant="bordered"
                         >
+                          <SelectItem key="grpc">gRPC（默认）</SelectItem>
                           <SelectItem key="tls">TLS</SelectItem>
-                          <SelectItem key="wss">WSS</SelectItem>
-                          <SelectItem key="tcp">TCP</SelectItem>
                           <SelectItem key="mtls">MTLS</SelectItem>
+                          <SelectItem key="wss">WSS</SelectItem>
                           <SelectItem key="mwss">MWSS</SelectItem>
+                          <SelectItem key="kcp">KCP</SelectItem>
+                          <SelectItem key="quic">QUIC</SelectItem>
+                          <SelectItem key="tcp">TCP</SelectItem>
                           <SelectItem key="mtcp">MTCP</SelectItem>
                         </Select>
 
+                        <Textarea
+                          label="传输参数（可选）"
+                          placeholder={'JSON，例如 {"sni":"example.com","secure":true} 或 {"path":"/ws"} 或 {"kcp":{"mode":"fast","mtu":1350}}'}
+                          value={form.transportOpts}
+                          onChange={(e) => setForm(prev => ({ ...prev, transportOpts: e.target.value }))}
+                          isInvalid={!!errors.transportOpts}
+                          errorMessage={errors.transportOpts}
+                          variant="bordered"
+                          description="sni / secure / caFile：入口校验证书；certFile / keyFile：出口证书；path / host：WS 路径；grpcPath；kcp：KCP 参数。修改协议或参数会重新下发该隧道的全部转发"
+                          minRows={2}
+                          maxRows={5}
+                        />
+
                         <Select
                           label="出口节点"
                           placeholder="请选择出口节点"
@@ -46,7 +46,7 @@ export interface UserTunnel {
   speedLimitName?: string; // 限速规则名称
   inFlow?: number; // 下载流量(字节)
   outFlow?: number; // 上传流量(字节)
-  tunnelFlow?: number; // 隧道流量计算类型(1-单向, 2-双向)
+  tunnelFlow?: number; // 隧道流量计算类型(1-单向取较大者, 2-双向, 3-仅上传, 4-仅下载)
 }
 
 export interface UserTunnelForm {